@@ -23,6 +23,11 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// ApplicationAnnotation records the namespace/name of the Argo CD
+// Application an AppVersion was discovered from, as owner references can't
+// cross namespaces.
+const ApplicationAnnotation = "kupdater.ops.getais.cloud/application"
+
 // AppVersionSpec defines the desired state of AppVersion
 type AppVersionSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
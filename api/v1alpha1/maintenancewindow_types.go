@@ -0,0 +1,121 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MaintenanceOverrideAnnotation on an Update applies versions automatically
+// regardless of maintenance windows, for emergencies.
+const MaintenanceOverrideAnnotation = "kupdater.ops.getais.cloud/ignore-maintenance-windows"
+
+// MaintenanceWindowSpec defines when automated updates may happen.
+type MaintenanceWindowSpec struct {
+	// Schedule of the window openings in cron format, e.g. "0 2 * * 6"
+	// for Saturdays at 02:00.
+	Schedule string `json:"schedule"`
+	// Duration the window stays open.
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone the schedule is interpreted in, defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// Namespaces whose Updates the window applies to, "*" matches all of
+	// them. Only windows in the admin namespace of the controller may apply
+	// to other namespaces than their own. Defaults to the namespace of the
+	// window.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector restricts the window to Updates with matching labels.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// MaintenanceWindowStatus defines the observed state of MaintenanceWindow
+type MaintenanceWindowStatus struct {
+	// Open reports whether the window is currently open.
+	Open bool `json:"open"`
+	// OpenTime is when the current or next window opens.
+	// +optional
+	OpenTime *metav1.Time `json:"openTime,omitempty"`
+	// CloseTime is when the current or next window closes.
+	// +optional
+	CloseTime *metav1.Time `json:"closeTime,omitempty"`
+	// Conditions report whether the schedule is valid.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Namespaced
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Duration",type=string,JSONPath=`.spec.duration`
+//+kubebuilder:printcolumn:name="Open",type=boolean,JSONPath=`.status.open`
+//+kubebuilder:printcolumn:name="Opens",type=date,JSONPath=`.status.openTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MaintenanceWindow is the Schema for the maintenancewindows API
+type MaintenanceWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MaintenanceWindowSpec   `json:"spec,omitempty"`
+	Status MaintenanceWindowStatus `json:"status,omitempty"`
+}
+
+// Applies reports whether the window restricts automated updates of an
+// Update.
+func (w *MaintenanceWindow) Applies(update *Update) (bool, error) {
+	if !w.watches(update.Namespace) {
+		return false, nil
+	}
+	if w.Spec.Selector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(w.Spec.Selector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(update.Labels)), nil
+}
+
+func (w *MaintenanceWindow) watches(namespace string) bool {
+	if len(w.Spec.Namespaces) == 0 {
+		return namespace == w.Namespace
+	}
+	for _, ns := range w.Spec.Namespaces {
+		if ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+//+kubebuilder:object:root=true
+
+// MaintenanceWindowList contains a list of MaintenanceWindow
+type MaintenanceWindowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MaintenanceWindow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MaintenanceWindow{}, &MaintenanceWindowList{})
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotificationChannelSpec defines where and how Updates are announced.
+type NotificationChannelSpec struct {
+	// Type of the sink: slack and mattermost post to incoming webhooks,
+	// webhook posts the update as JSON.
+	// +kubebuilder:validation:Enum=slack;mattermost;webhook
+	Type string `json:"type"`
+	// URL messages are posted to.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// URLSecretRef selects a key of a Secret holding the URL, for webhooks
+	// which embed a token. Takes precedence over URL.
+	// +optional
+	URLSecretRef *corev1.SecretKeySelector `json:"urlSecretRef,omitempty"`
+	// SigningSecretRef selects a key of a Secret holding the key webhook
+	// payloads are signed with, the HMAC-SHA256 signature is sent in the
+	// X-Kupdater-Signature header.
+	// +optional
+	SigningSecretRef *corev1.SecretKeySelector `json:"signingSecretRef,omitempty"`
+	// Template of the message, a Go text/template rendered with the update.
+	// +optional
+	Template string `json:"template,omitempty"`
+	// Namespaces whose Updates are announced, "*" matches all of them.
+	// Only channels in the admin namespace of the controller may watch
+	// other namespaces than their own. Defaults to the namespace of the
+	// channel.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Digest batches the Updates which became outdated into one summary
+	// sent on a schedule, instead of announcing each of them right away.
+	// +optional
+	Digest *NotificationDigest `json:"digest,omitempty"`
+}
+
+// NotificationDigest schedules summaries of outdated Updates.
+type NotificationDigest struct {
+	// Schedule of the digest in cron format, e.g. "0 9 * * 1" or "@daily".
+	Schedule string `json:"schedule"`
+	// TimeZone the schedule is interpreted in, defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// Template of the digest, a Go text/template rendered with the
+	// outdated updates as .Updates.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// NotificationChannelStatus defines the observed state of NotificationChannel
+type NotificationChannelStatus struct {
+	// Conditions report whether the last notification was delivered.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastDigestTime is when the last digest was due.
+	// +optional
+	LastDigestTime *metav1.Time `json:"lastDigestTime,omitempty"`
+	// NextDigestTime is when the next digest is scheduled.
+	// +optional
+	NextDigestTime *metav1.Time `json:"nextDigestTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Namespaced
+//+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+//+kubebuilder:printcolumn:name="Digest",type=string,JSONPath=`.spec.digest.schedule`
+//+kubebuilder:printcolumn:name="Next Digest",type=date,JSONPath=`.status.nextDigestTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotificationChannel is the Schema for the notificationchannels API
+type NotificationChannel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NotificationChannelSpec   `json:"spec,omitempty"`
+	Status NotificationChannelStatus `json:"status,omitempty"`
+}
+
+// Key identifies the channel in the announcements of Updates.
+func (c *NotificationChannel) Key() string {
+	return c.Namespace + "/" + c.Name
+}
+
+// Watches reports whether Updates in namespace are announced on the channel.
+func (c *NotificationChannel) Watches(namespace string) bool {
+	if len(c.Spec.Namespaces) == 0 {
+		return namespace == c.Namespace
+	}
+	for _, ns := range c.Spec.Namespaces {
+		if ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+//+kubebuilder:object:root=true
+
+// NotificationChannelList contains a list of NotificationChannel
+type NotificationChannelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationChannel `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationChannel{}, &NotificationChannelList{})
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PromotionSpec defines the stages versions are promoted through.
+type PromotionSpec struct {
+	// Source of the Updates whose versions are promoted. Defaults to the
+	// first source of each Update.
+	// +optional
+	Source string `json:"source,omitempty"`
+	// Stages in promotion order, e.g. dev, staging and prod. Versions are
+	// only suggested to a stage once they ran healthy in the previous stage
+	// for its soak time.
+	// +kubebuilder:validation:MinItems=2
+	Stages []PromotionStage `json:"stages"`
+}
+
+// PromotionStage is an environment versions are promoted to.
+type PromotionStage struct {
+	// Name of the stage, e.g. staging.
+	Name string `json:"name"`
+	// Update of the application in this stage.
+	Update UpdateReference `json:"update"`
+	// SoakTime is how long a version has to run healthy in this stage
+	// before it is promoted to the next one.
+	// +optional
+	SoakTime *metav1.Duration `json:"soakTime,omitempty"`
+}
+
+// UpdateReference refers to an Update.
+type UpdateReference struct {
+	Name string `json:"name"`
+	// Namespace of the Update, defaults to the namespace of the referrer.
+	// Only Promotions in the admin namespace of the controller may refer
+	// to Updates of other namespaces.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PromotionStatus defines the observed state of Promotion
+type PromotionStatus struct {
+	// Chain summarises the versions of all stages, e.g.
+	// "dev: 1.3.0 -> staging: 1.3.0 (soaking, 5h left) -> prod: 1.2.0".
+	// +optional
+	Chain string `json:"chain,omitempty"`
+	// Stages report the version running in each stage.
+	// +optional
+	Stages []StageStatus `json:"stages,omitempty"`
+	// Conditions report whether the Updates of all stages were found.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// StageStatus reports the version running in a stage.
+type StageStatus struct {
+	// Name of the stage.
+	Name string `json:"name"`
+	// Version installed in the stage.
+	// +optional
+	Version string `json:"version,omitempty"`
+	// Healthy reports whether the version runs healthy.
+	Healthy bool `json:"healthy"`
+	// Since is when the version started running healthy.
+	// +optional
+	Since *metav1.Time `json:"since,omitempty"`
+	// PromotionTime is when the version completes its soak time and is
+	// promoted to the next stage.
+	// +optional
+	PromotionTime *metav1.Time `json:"promotionTime,omitempty"`
+	// SoakRemaining is how long the version still has to run healthy
+	// before it is promoted, e.g. 4h30m0s.
+	// +optional
+	SoakRemaining string `json:"soakRemaining,omitempty"`
+	// Promoted is the newest version promoted to the stage from the
+	// previous one. Newer versions aren't suggested to the stage.
+	// +optional
+	Promoted string `json:"promoted,omitempty"`
+	// Message explains why the version isn't healthy.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Namespaced
+//+kubebuilder:printcolumn:name="Chain",type=string,JSONPath=`.status.chain`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Promotion is the Schema for the promotions API
+type Promotion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PromotionSpec   `json:"spec,omitempty"`
+	Status PromotionStatus `json:"status,omitempty"`
+}
+
+// UpdateKey returns the namespace and name of the Update of a stage.
+func (p *Promotion) UpdateKey(stage PromotionStage) types.NamespacedName {
+	key := types.NamespacedName{Namespace: stage.Update.Namespace, Name: stage.Update.Name}
+	if key.Namespace == "" {
+		key.Namespace = p.Namespace
+	}
+	return key
+}
+
+// Stage returns the status of a stage, nil if it isn't reported.
+func (s *PromotionStatus) Stage(name string) *StageStatus {
+	for i := range s.Stages {
+		if s.Stages[i].Name == name {
+			return &s.Stages[i]
+		}
+	}
+	return nil
+}
+
+//+kubebuilder:object:root=true
+
+// PromotionList contains a list of Promotion
+type PromotionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Promotion `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Promotion{}, &PromotionList{})
+}
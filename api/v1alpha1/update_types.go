@@ -1,27 +1,369 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type UpdateSpec struct {
-	Versioning UpdateVersioning `json:"versioning"`
+	// CheckInterval is how often sources are checked for new versions, at
+	// least every minute. Defaults to the operator's --update-check-interval
+	// flag.
+	// +optional
+	CheckInterval *metav1.Duration `json:"checkInterval,omitempty"`
+	Versioning    UpdateVersioning `json:"versioning"`
+	// Automation applies new versions to the objects sources were
+	// discovered on, instead of only reporting them.
+	// +optional
+	Automation *UpdateAutomation `json:"automation,omitempty"`
+}
+
+// AutoUpdateAnnotation enables automation of an Update, or of the Updates
+// created for an annotated Deployment, as spec.automation.enabled does.
+const AutoUpdateAnnotation = "kupdater.ops.getais.cloud/auto-update"
+
+// UpdateAutomation configures how new versions are applied.
+type UpdateAutomation struct {
+	// Enabled applies the newest version allowed by policy.
+	Enabled bool `json:"enabled"`
+	// RolloutTimeout is how long the rollout of a new version may take
+	// before it is considered failed. Defaults to 10m.
+	// +optional
+	RolloutTimeout *metav1.Duration `json:"rolloutTimeout,omitempty"`
+	// Rollback restores the previous version when the rollout fails.
+	// +optional
+	Rollback bool `json:"rollback,omitempty"`
+	// RequireApproval only applies versions approved through their
+	// UpdateApproval. Annotating the namespace with
+	// kupdater.ops.getais.cloud/require-approval=true requires it for all
+	// Updates of the namespace.
+	// +optional
+	RequireApproval bool `json:"requireApproval,omitempty"`
+	// Git writes new versions to the Git repository the application is
+	// deployed from, instead of changing the live objects.
+	// +optional
+	Git *GitWriteBack `json:"git,omitempty"`
+}
+
+// GitWriteBack describes where versions are written in a Git repository.
+type GitWriteBack struct {
+	// URL of the repository, e.g. https://github.com/getais/homelab.git or
+	// ssh://git@github.com/getais/homelab.git.
+	URL string `json:"url"`
+	// Branch changes are based on. Defaults to the default branch.
+	// +optional
+	Branch string `json:"branch,omitempty"`
+	// PushBranch is the branch commits are pushed to, recreated from
+	// Branch on every new version. Defaults to Branch.
+	// +optional
+	PushBranch string `json:"pushBranch,omitempty"`
+	// Files the versions of sources are written to.
+	// +kubebuilder:validation:MinItems=1
+	Files []GitFile `json:"files"`
+	// SecretRef is the Secret with the credentials of the repository:
+	// username and password, token, or identity and known_hosts for SSH.
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+	// PullRequest opens a pull request from PushBranch for every new
+	// version instead of pushing to Branch. PushBranch defaults to
+	// kupdater/<update>-<source>.
+	// +optional
+	PullRequest *GitPullRequest `json:"pullRequest,omitempty"`
+}
+
+// GitPullRequest describes the forge pull requests are opened on.
+type GitPullRequest struct {
+	// Forge hosting the repository.
+	// +kubebuilder:validation:Enum=github;gitlab;gitea
+	Forge string `json:"forge"`
+	// URL of the forge, defaults to the host of the repository url.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Repository is the path of the repository on the forge, e.g.
+	// getais/homelab. Defaults to the path of the repository url.
+	// +optional
+	Repository string `json:"repository,omitempty"`
+	// SecretRef is the Secret with the "token" of the forge API. Defaults
+	// to the secretRef of the repository.
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+// GitFile is a file holding the version of a source.
+type GitFile struct {
+	// Source whose version is written.
+	Source string `json:"source"`
+	// Path of the file in the repository.
+	Path string `json:"path"`
+	// Format of the file.
+	// +kubebuilder:validation:Enum=helm-values;argocd;kustomize
+	Format string `json:"format"`
+	// Key locates the version: a dotted path such as image.tag for Helm
+	// values and Argo CD Applications, the image name for kustomizations.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// File returns the file holding the version of a source, or nil.
+func (g *GitWriteBack) File(source string) *GitFile {
+	for i := range g.Files {
+		if g.Files[i].Source == source {
+			return &g.Files[i]
+		}
+	}
+	return nil
 }
 
 type UpdateVersioning struct {
 	Sources []UpdateSource `json:"sources"`
 }
 
+// Source returns the named source, or nil.
+func (v *UpdateVersioning) Source(name string) *UpdateSource {
+	for i := range v.Sources {
+		if v.Sources[i].Name == name {
+			return &v.Sources[i]
+		}
+	}
+	return nil
+}
+
 type UpdateSource struct {
 	Name    string `json:"name"`
 	Type    string `json:"type"`
 	Source  string `json:"source"`
 	Version string `json:"version"`
+	// Constraint limits the versions suggested as updates. It is either a
+	// semantic version range such as "~1.2" or ">=2 <3", or one of
+	// "patch-only" and "minor-only".
+	// +optional
+	Constraint string `json:"constraint,omitempty"`
+	// Channel is the least stable kind of release suggested as update.
+	// Defaults to stable, which ignores all pre-releases.
+	// +kubebuilder:validation:Enum=stable;beta;alpha
+	// +optional
+	Channel string `json:"channel,omitempty"`
+	// IncludePrereleases suggests every pre-release, same as the alpha channel.
+	// +optional
+	IncludePrereleases bool `json:"includePrereleases,omitempty"`
+	// TagFilter selects the published tags considered as versions.
+	// +optional
+	TagFilter *TagFilter `json:"tagFilter,omitempty"`
+	// VersionExtract is a regular expression extracting the version out of
+	// a tag. The capture group named "version" is used if present, otherwise
+	// all named capture groups are joined by dots.
+	// +optional
+	VersionExtract string `json:"versionExtract,omitempty"`
+	// Ordering used to compare versions, semver by default.
+	// +kubebuilder:validation:Enum=semver;calver;numeric-dotted;lexical
+	// +optional
+	Ordering string `json:"ordering,omitempty"`
+	// SecretRef references a Secret in the same namespace holding
+	// credentials for the source.
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+	// Mode selects how github sources are looked up: releases lists every
+	// release, falling back to tags for repositories without releases, tags
+	// lists every tag and latest only considers the release Github marks as
+	// latest. Defaults to releases.
+	// +kubebuilder:validation:Enum=releases;tags;latest
+	// +optional
+	Mode string `json:"mode,omitempty"`
+	// APIURL is the API endpoint of self-hosted forges served under a path,
+	// e.g. https://example.com/gitlab/api/v4. Derived from source otherwise.
+	// +optional
+	APIURL string `json:"apiURL,omitempty"`
+}
+
+// TagFilter selects tags by regular expressions.
+type TagFilter struct {
+	// Include only considers tags matching this expression.
+	// +optional
+	Include string `json:"include,omitempty"`
+	// Exclude ignores tags matching this expression.
+	// +optional
+	Exclude string `json:"exclude,omitempty"`
 }
+
+// UpdatePhase summarises the state of all sources of an Update.
+// +kubebuilder:validation:Enum=UpToDate;Outdated;Failed
+type UpdatePhase string
+
+const (
+	// UpdatePhaseUpToDate means no source has a newer version allowed by policy.
+	UpdatePhaseUpToDate UpdatePhase = "UpToDate"
+	// UpdatePhaseOutdated means at least one source has a newer version allowed by policy.
+	UpdatePhaseOutdated UpdatePhase = "Outdated"
+	// UpdatePhaseFailed means at least one source couldn't be checked.
+	UpdatePhaseFailed UpdatePhase = "Failed"
+)
+
+// SourceStatus is the result of the last check of a single source.
+type SourceStatus struct {
+	// Name of the source, matching spec.versioning.sources[].name.
+	Name string `json:"name"`
+	// Type of the source.
+	// +optional
+	Type string `json:"type,omitempty"`
+	// CurrentVersion is the installed version.
+	// +optional
+	CurrentVersion string `json:"currentVersion,omitempty"`
+	// LatestVersion is the newest published version, regardless of policy.
+	// +optional
+	LatestVersion string `json:"latestVersion,omitempty"`
+	// LatestInPolicy is the newest published version allowed by policy.
+	// +optional
+	LatestInPolicy string `json:"latestInPolicy,omitempty"`
+	// Outdated is true when LatestInPolicy is newer than CurrentVersion.
+	// +optional
+	Outdated bool `json:"outdated,omitempty"`
+	// Behind counts the published versions allowed by policy which are
+	// newer than CurrentVersion.
+	// +optional
+	Behind int `json:"behind,omitempty"`
+	// Promoted is the newest version promoted to the Update by a
+	// Promotion. Newer versions aren't suggested until they are promoted.
+	// +optional
+	Promoted string `json:"promoted,omitempty"`
+	// ReleaseDate is when LatestInPolicy was published, if the source reports it.
+	// +optional
+	ReleaseDate *metav1.Time `json:"releaseDate,omitempty"`
+	// LastChecked is when the source was last checked.
+	// +optional
+	LastChecked *metav1.Time `json:"lastChecked,omitempty"`
+	// Error of the last check, the versions are kept from the last successful one.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// AutomationPhase is the state of an automated update.
+// +kubebuilder:validation:Enum=Pending;Progressing;Proposed;Applied;Failed;RolledBack
+type AutomationPhase string
+
+const (
+	// AutomationPending means a new version waits for approval, or for a
+	// maintenance window or an Argo CD sync window to open.
+	AutomationPending AutomationPhase = "Pending"
+	// AutomationProgressing means a new version was applied and is rolling out.
+	AutomationProgressing AutomationPhase = "Progressing"
+	// AutomationProposed means a pull request proposes the new version,
+	// which is applied once merged.
+	AutomationProposed AutomationPhase = "Proposed"
+	// AutomationApplied means the new version rolled out.
+	AutomationApplied AutomationPhase = "Applied"
+	// AutomationFailed means the new version couldn't be applied or didn't roll out.
+	AutomationFailed AutomationPhase = "Failed"
+	// AutomationRolledBack means the new version didn't roll out and the
+	// previous one was restored.
+	AutomationRolledBack AutomationPhase = "RolledBack"
+)
+
+// AutomationStatus describes the last version applied automatically.
+type AutomationStatus struct {
+	Phase AutomationPhase `json:"phase"`
+	// Source whose version was applied.
+	Source string `json:"source"`
+	// Version applied.
+	Version string `json:"version"`
+	// Target is the object the version was applied to, e.g. Deployment/traefik.
+	// +optional
+	Target string `json:"target,omitempty"`
+	// Previous is what the version replaced, e.g. the previous image, and
+	// is restored on rollback.
+	// +optional
+	Previous string `json:"previous,omitempty"`
+	// Applied is what was set on the target, e.g. the new image.
+	// +optional
+	Applied string `json:"applied,omitempty"`
+	// PullRequest is the url of the pull request proposing the version.
+	// +optional
+	PullRequest string `json:"pullRequest,omitempty"`
+	// StartTime is when the version was applied.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the rollout finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Message describes the last state change.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// Announcement records what was announced about an Update on a
+// NotificationChannel, so it isn't announced again.
+type Announcement struct {
+	// Channel is the namespace/name of the NotificationChannel.
+	Channel string `json:"channel"`
+	// Versions announced as available, one source=version entry per
+	// outdated source.
+	Versions []string `json:"versions"`
+	// Time of the announcement.
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
 type UpdateStatus struct {
-	Phase         string             `json:"phase"`
-	Conditions    []metav1.Condition `json:"conditions"`
-	SyncTimestamp string             `json:"syncTimestamp"`
+	Phase      UpdatePhase        `json:"phase"`
+	Conditions []metav1.Condition `json:"conditions"`
+	// Sources holds the result of the last check of each source.
+	// +optional
+	Sources []SourceStatus `json:"sources,omitempty"`
+	// ObservedGeneration is the spec generation the last check was run against.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastCheckTime is when sources were last checked.
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+	// NextCheckTime is when sources are scheduled to be checked again.
+	// +optional
+	NextCheckTime *metav1.Time `json:"nextCheckTime,omitempty"`
+	// Announcements lists the versions announced on each NotificationChannel.
+	// +optional
+	Announcements []Announcement `json:"announcements,omitempty"`
+	// Automation reports the last version applied automatically.
+	// +optional
+	Automation *AutomationStatus `json:"automation,omitempty"`
+}
+
+// Source returns the status of the named source, or nil if it hasn't been
+// checked yet.
+func (s *UpdateStatus) Source(name string) *SourceStatus {
+	for i := range s.Sources {
+		if s.Sources[i].Name == name {
+			return &s.Sources[i]
+		}
+	}
+	return nil
+}
+
+// Announcement returns what was announced on the channel, or nil.
+func (s *UpdateStatus) Announcement(channel string) *Announcement {
+	for i := range s.Announcements {
+		if s.Announcements[i].Channel == channel {
+			return &s.Announcements[i]
+		}
+	}
+	return nil
+}
+
+// SetAnnouncement records an announcement, replacing the previous one made
+// on the same channel.
+func (s *UpdateStatus) SetAnnouncement(a Announcement) {
+	if existing := s.Announcement(a.Channel); existing != nil {
+		*existing = a
+		return
+	}
+	s.Announcements = append(s.Announcements, a)
+}
+
+// RemoveAnnouncement forgets what was announced on the channel.
+func (s *UpdateStatus) RemoveAnnouncement(channel string) {
+	for i := range s.Announcements {
+		if s.Announcements[i].Channel == channel {
+			s.Announcements = append(s.Announcements[:i], s.Announcements[i+1:]...)
+			return
+		}
+	}
 }
 
 // +kubebuilder:object:root=true
@@ -29,8 +371,9 @@ type UpdateStatus struct {
 // +kubebuilder:resource:scope=Namespaced
 // +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.versioning.sources[0].type`
 // +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.versioning.sources[0].version`
+// +kubebuilder:printcolumn:name="Latest",type=string,JSONPath=`.status.sources[0].latestInPolicy`
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
-// +kubebuilder:printcolumn:name="Synced",type=date,JSONPath=`.status.syncTimestamp`
+// +kubebuilder:printcolumn:name="Synced",type=date,JSONPath=`.status.lastCheckTime`
 
 // Update is the Schema for the updates API
 type Update struct {
@@ -0,0 +1,85 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// RequireApprovalAnnotation on a Namespace requires approval of all
+	// automated updates of its Updates, see UpdateApproval.
+	RequireApprovalAnnotation = "kupdater.ops.getais.cloud/require-approval"
+	// ApproveAnnotation approves the version of an UpdateApproval when set
+	// to "true".
+	ApproveAnnotation = "kupdater.ops.getais.cloud/approve"
+)
+
+// UpdateApprovalSpec defines the version awaiting approval.
+type UpdateApprovalSpec struct {
+	// Update the version is applied to.
+	Update string `json:"update"`
+	// Source of the Update the version belongs to.
+	Source string `json:"source"`
+	// Version to approve. A newer version replaces it and expires the
+	// approval.
+	Version string `json:"version"`
+	// ApprovedBy is the user who approved the version. It is recorded from
+	// the request approving it by the approval webhook, edits are
+	// overwritten. Approvals are refused while the webhook isn't served.
+	// +optional
+	ApprovedBy string `json:"approvedBy,omitempty"`
+	// ApprovedAt is when the version was approved.
+	// +optional
+	ApprovedAt *metav1.Time `json:"approvedAt,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Namespaced
+//+kubebuilder:printcolumn:name="Update",type=string,JSONPath=`.spec.update`
+//+kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.source`
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.version`
+//+kubebuilder:printcolumn:name="Approved By",type=string,JSONPath=`.spec.approvedBy`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// UpdateApproval is the Schema for the updateapprovals API. It is created
+// for versions of Updates requiring approval, and approved by annotating it
+// with kupdater.ops.getais.cloud/approve=true.
+type UpdateApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec UpdateApprovalSpec `json:"spec,omitempty"`
+}
+
+// Approved reports whether version was approved.
+func (a *UpdateApproval) Approved(version string) bool {
+	return a.Spec.Version == version && a.Spec.ApprovedBy != "" && a.Annotations[ApproveAnnotation] == "true"
+}
+
+//+kubebuilder:object:root=true
+
+// UpdateApprovalList contains a list of UpdateApproval
+type UpdateApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UpdateApproval `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UpdateApproval{}, &UpdateApprovalList{})
+}
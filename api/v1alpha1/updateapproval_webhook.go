@@ -0,0 +1,96 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// UpdateApprovalWebhookPath is where the UpdateApproval webhook is served.
+const UpdateApprovalWebhookPath = "/mutate-ops-getais-cloud-v1alpha1-updateapproval"
+
+var updateapprovallog = logf.Log.WithName("updateapproval-resource")
+
+//+kubebuilder:webhook:path=/mutate-ops-getais-cloud-v1alpha1-updateapproval,mutating=true,failurePolicy=fail,sideEffects=None,groups=ops.getais.cloud,resources=updateapprovals,verbs=create;update,versions=v1alpha1,name=mupdateapproval.kb.io,admissionReviewVersions=v1
+
+// ApprovalRecorder records who approved an UpdateApproval from the user info
+// of the admission request, so approvers can't be forged.
+type ApprovalRecorder struct {
+	decoder *admission.Decoder
+}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (a *ApprovalRecorder) InjectDecoder(d *admission.Decoder) error {
+	a.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (a *ApprovalRecorder) Handle(ctx context.Context, req admission.Request) admission.Response {
+	approval := &UpdateApproval{}
+	if err := a.decoder.Decode(req, approval); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var old *UpdateApproval
+	if req.Operation == admissionv1.Update {
+		old = &UpdateApproval{}
+		if err := a.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	recordApproval(approval, old, req.UserInfo, time.Now())
+	if approval.Spec.ApprovedBy != "" && (old == nil || old.Spec.ApprovedBy == "") {
+		updateapprovallog.Info("approved", "namespace", approval.Namespace, "name", approval.Name,
+			"version", approval.Spec.Version, "user", approval.Spec.ApprovedBy)
+	}
+
+	marshaled, err := json.Marshal(approval)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// recordApproval sets who approved the version of an UpdateApproval. An
+// approval of the same version is kept as it was, changing the version
+// expires it and removing the approve annotation clears it.
+func recordApproval(approval, old *UpdateApproval, user authenticationv1.UserInfo, now time.Time) {
+	switch {
+	case old != nil && old.Spec.Version != approval.Spec.Version:
+		delete(approval.Annotations, ApproveAnnotation)
+		fallthrough
+	case approval.Annotations[ApproveAnnotation] != "true":
+		approval.Spec.ApprovedBy = ""
+		approval.Spec.ApprovedAt = nil
+	case old != nil && old.Approved(approval.Spec.Version):
+		approval.Spec.ApprovedBy = old.Spec.ApprovedBy
+		approval.Spec.ApprovedAt = old.Spec.ApprovedAt
+	default:
+		approval.Spec.ApprovedBy = user.Username
+		approval.Spec.ApprovedAt = &metav1.Time{Time: now}
+	}
+}
@@ -0,0 +1,51 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordApproval(t *testing.T) {
+	now := time.Date(2022, 10, 20, 8, 0, 0, 0, time.UTC)
+	earlier := &metav1.Time{Time: now.Add(-time.Hour)}
+	approve := map[string]string{ApproveAnnotation: "true"}
+	approval := func(version string, annotations map[string]string, approvedBy string, approvedAt *metav1.Time) *UpdateApproval {
+		a := &UpdateApproval{Spec: UpdateApprovalSpec{Update: "traefik", Source: "traefik", Version: version, ApprovedBy: approvedBy, ApprovedAt: approvedAt}}
+		a.Annotations = map[string]string{}
+		for k, v := range annotations {
+			a.Annotations[k] = v
+		}
+		return a
+	}
+
+	tests := map[string]struct {
+		approval, old *UpdateApproval
+		approvedBy    string
+		approvedAt    *metav1.Time
+		annotated     bool
+	}{
+		"created":                  {approval: approval("17.1.0", nil, "", nil)},
+		"created approved":         {approval: approval("17.1.0", approve, "", nil), approvedBy: "bob", approvedAt: &metav1.Time{Time: now}, annotated: true},
+		"created forged":           {approval: approval("17.1.0", nil, "alice", earlier)},
+		"created approved forged":  {approval: approval("17.1.0", approve, "alice", earlier), approvedBy: "bob", approvedAt: &metav1.Time{Time: now}, annotated: true},
+		"approved":                 {approval: approval("17.1.0", approve, "", nil), old: approval("17.1.0", nil, "", nil), approvedBy: "bob", approvedAt: &metav1.Time{Time: now}, annotated: true},
+		"forged":                   {approval: approval("17.1.0", nil, "alice", earlier), old: approval("17.1.0", nil, "", nil)},
+		"approver kept":            {approval: approval("17.1.0", approve, "bob", nil), old: approval("17.1.0", approve, "alice", earlier), approvedBy: "alice", approvedAt: earlier, annotated: true},
+		"annotation removed":       {approval: approval("17.1.0", nil, "alice", earlier), old: approval("17.1.0", approve, "alice", earlier)},
+		"version changed":          {approval: approval("17.2.0", approve, "alice", earlier), old: approval("17.1.0", approve, "alice", earlier)},
+		"version changed unsigned": {approval: approval("17.2.0", nil, "", nil), old: approval("17.1.0", approve, "alice", earlier)},
+	}
+	for name, tt := range tests {
+		recordApproval(tt.approval, tt.old, authenticationv1.UserInfo{Username: "bob"}, now)
+		spec := tt.approval.Spec
+		if spec.ApprovedBy != tt.approvedBy || (spec.ApprovedAt == nil) != (tt.approvedAt == nil) || (tt.approvedAt != nil && !spec.ApprovedAt.Equal(tt.approvedAt)) {
+			t.Errorf("%s: approved by %q at %v, want %q at %v", name, spec.ApprovedBy, spec.ApprovedAt, tt.approvedBy, tt.approvedAt)
+		}
+		if annotated := tt.approval.Annotations[ApproveAnnotation] == "true"; annotated != tt.annotated {
+			t.Errorf("%s: approve annotation = %v, want %v", name, annotated, tt.annotated)
+		}
+	}
+}
@@ -19,12 +19,15 @@ package controllers
 import (
 	"context"
 	"net/url"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
@@ -32,12 +35,14 @@ import (
 
 	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/providers"
 )
 
 // AppVersionReconciler reconciles a AppVersion object
 type ApplicationReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;watch
@@ -69,12 +74,6 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		// If Chart is specified in Application.argoproj
 		if app.Spec.Source.Chart != "" {
 
-			// If helm repo url is valid http url
-			_, err := url.ParseRequestURI(app.Spec.Source.RepoURL)
-			if err != nil {
-				log.Info("Skipping. Helm RepoUrl is not http")
-				return ctrl.Result{}, nil
-			}
 			appver = r.NewAppver(app)
 			log.Info("Creating a new AppVersion")
 			err = r.Create(ctx, appver)
@@ -82,6 +81,7 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 				log.Error(err, "Failed to create new AppVersion")
 				return ctrl.Result{RequeueAfter: 2 * time.Minute}, err
 			}
+			r.Recorder.Eventf(app, corev1.EventTypeNormal, ReasonAppVersionCreated, "Created AppVersion %s/%s tracking %s %s", appver.Namespace, appver.Name, appver.Spec.Name, appver.Spec.Version)
 
 		}
 		// AppVersion created successfully - return and requeue
@@ -105,15 +105,26 @@ func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 func (r *ApplicationReconciler) NewAppver(a *argov1alpha1.Application) *opsv1alpha1.AppVersion {
 
+	// Charts in OCI registries are referenced without http scheme, and
+	// looked up by their full repository
+	Type, Source := "helm", a.Spec.Source.RepoURL
+	if u, err := url.ParseRequestURI(a.Spec.Source.RepoURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		Type = "helm-oci"
+		Source = strings.TrimSuffix(a.Spec.Source.RepoURL, "/") + "/" + a.Spec.Source.Chart
+	}
+
 	AppVer := &opsv1alpha1.AppVersion{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      a.Name,
 			Namespace: a.Spec.Destination.Namespace,
+			Annotations: map[string]string{
+				opsv1alpha1.ApplicationAnnotation: a.Namespace + "/" + a.Name,
+			},
 		},
 		Spec: opsv1alpha1.UpdateSource{
 			Name:    a.Name,
-			Type:    "helm",
-			Source:  a.Spec.Source.RepoURL,
+			Type:    Type,
+			Source:  Source,
 			Version: a.Spec.Source.TargetRevision,
 		},
 		Status: opsv1alpha1.AppVersionStatus{},
@@ -123,3 +134,9 @@ func (r *ApplicationReconciler) NewAppver(a *argov1alpha1.Application) *opsv1alp
 	return AppVer
 
 }
+
+// isHelmType reports whether a source type tracks Helm chart versions.
+func isHelmType(Type string) bool {
+	t := providers.Normalize(Type)
+	return t == "helm" || t == "helm-oci"
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// approvalRequired reports whether versions of the Update are only applied
+// once approved, as requested by spec.automation or its namespace.
+func (r *UpdateReconciler) approvalRequired(ctx context.Context, update *opsv1alpha1.Update) (bool, error) {
+	if a := update.Spec.Automation; a != nil && a.RequireApproval {
+		return true, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: update.Namespace}, ns); err != nil {
+		return false, err
+	}
+	return ns.Annotations[opsv1alpha1.RequireApprovalAnnotation] == "true", nil
+}
+
+// approval returns the UpdateApproval of a version of a source, creating it
+// when the source has none yet. An UpdateApproval of another version
+// expires, and is reset to version awaiting approval.
+func (r *UpdateReconciler) approval(ctx context.Context, update *opsv1alpha1.Update, source, version string) (*opsv1alpha1.UpdateApproval, error) {
+	approval := &opsv1alpha1.UpdateApproval{}
+	err := r.Get(ctx, approvalKey(update, source), approval)
+	switch {
+	case errors.IsNotFound(err):
+		approval = &opsv1alpha1.UpdateApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: approvalKey(update, source).Name, Namespace: update.Namespace},
+			Spec:       opsv1alpha1.UpdateApprovalSpec{Update: update.Name, Source: source, Version: version},
+		}
+		if err := ctrl.SetControllerReference(update, approval, r.Scheme); err != nil {
+			return nil, err
+		}
+		return approval, r.Create(ctx, approval)
+	case err != nil:
+		return nil, err
+	case approval.Spec.Version == version:
+		return approval, nil
+	}
+
+	delete(approval.Annotations, opsv1alpha1.ApproveAnnotation)
+	approval.Spec.Version = version
+	approval.Spec.ApprovedBy = ""
+	approval.Spec.ApprovedAt = nil
+	return approval, r.Update(ctx, approval)
+}
+
+// approvalGranted reports whether the version pending approval was
+// approved since the Update was last checked.
+func (r *UpdateReconciler) approvalGranted(ctx context.Context, update *opsv1alpha1.Update) bool {
+	auto := update.Status.Automation
+	if auto == nil || auto.Phase != opsv1alpha1.AutomationPending {
+		return false
+	}
+	approval := &opsv1alpha1.UpdateApproval{}
+	if err := r.Get(ctx, approvalKey(update, auto.Source), approval); err != nil {
+		return false
+	}
+	return approval.Approved(auto.Version)
+}
+
+// approvalKey names the UpdateApproval of a source after the Update and
+// the source.
+func approvalKey(update *opsv1alpha1.Update, source string) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      fmt.Sprintf("%s-%s", update.Name, strings.ToLower(source)),
+		Namespace: update.Namespace,
+	}
+}
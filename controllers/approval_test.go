@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// approvalOf returns the UpdateApproval of the traefik source of update,
+// approved by approvedBy unless empty.
+func approvalOf(update *opsv1alpha1.Update, version, approvedBy string) *opsv1alpha1.UpdateApproval {
+	approval := &opsv1alpha1.UpdateApproval{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik-traefik", Namespace: update.Namespace},
+		Spec:       opsv1alpha1.UpdateApprovalSpec{Update: update.Name, Source: "traefik", Version: version, ApprovedBy: approvedBy},
+	}
+	if approvedBy != "" {
+		approval.Annotations = map[string]string{opsv1alpha1.ApproveAnnotation: "true"}
+		approval.Spec.ApprovedAt = &metav1.Time{}
+	}
+	return approval
+}
+
+func TestApproval(t *testing.T) {
+	update := &opsv1alpha1.Update{ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik", UID: "update-uid"}}
+	tests := map[string]struct {
+		existing *opsv1alpha1.UpdateApproval
+		approved bool
+	}{
+		"created":          {},
+		"awaiting":         {existing: approvalOf(update, "17.1.0", "")},
+		"approved":         {existing: approvalOf(update, "17.1.0", "alice"), approved: true},
+		"version expired":  {existing: approvalOf(update, "17.0.5", "alice")},
+		"version replaced": {existing: approvalOf(update, "17.0.5", "")},
+	}
+	for name, tt := range tests {
+		objs := []client.Object{update}
+		if tt.existing != nil {
+			objs = append(objs, tt.existing)
+		}
+		c := newFakeClient(t, objs...)
+		r := &UpdateReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(10)}
+		ctx := context.Background()
+
+		approval, err := r.approval(ctx, update, "traefik", "17.1.0")
+		if err != nil {
+			t.Fatalf("%s: approval() = %v", name, err)
+		}
+		stored := &opsv1alpha1.UpdateApproval{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(approval), stored); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, a := range []*opsv1alpha1.UpdateApproval{approval, stored} {
+			if a.Spec.Version != "17.1.0" || a.Approved("17.1.0") != tt.approved {
+				t.Errorf("%s: approval = %+v, want 17.1.0 approved %v", name, a.Spec, tt.approved)
+			}
+			if !tt.approved && (a.Spec.ApprovedBy != "" || a.Spec.ApprovedAt != nil || a.Annotations[opsv1alpha1.ApproveAnnotation] != "") {
+				t.Errorf("%s: approval = %+v %v, want the approval cleared", name, a.Spec, a.Annotations)
+			}
+		}
+		if owner := metav1.GetControllerOf(stored); tt.existing == nil && (owner == nil || owner.UID != update.UID) {
+			t.Errorf("%s: owner = %v, want the Update", name, owner)
+		}
+	}
+}
+
+func TestApprovalGranted(t *testing.T) {
+	pending := &opsv1alpha1.AutomationStatus{Phase: opsv1alpha1.AutomationPending, Source: "traefik", Version: "17.1.0"}
+	applied := &opsv1alpha1.AutomationStatus{Phase: opsv1alpha1.AutomationApplied, Source: "traefik", Version: "17.1.0"}
+	tests := map[string]struct {
+		automation *opsv1alpha1.AutomationStatus
+		version    string
+		approvedBy string
+		granted    bool
+	}{
+		"not pending":   {automation: applied, version: "17.1.0", approvedBy: "alice"},
+		"no automation": {version: "17.1.0", approvedBy: "alice"},
+		"awaiting":      {automation: pending, version: "17.1.0"},
+		"approved":      {automation: pending, version: "17.1.0", approvedBy: "alice", granted: true},
+		"other version": {automation: pending, version: "17.0.5", approvedBy: "alice"},
+		"no approval":   {automation: pending},
+	}
+	for name, tt := range tests {
+		update := &opsv1alpha1.Update{
+			ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik"},
+			Status:     opsv1alpha1.UpdateStatus{Automation: tt.automation},
+		}
+		objs := []client.Object{update}
+		if tt.version != "" {
+			objs = append(objs, approvalOf(update, tt.version, tt.approvedBy))
+		}
+		r := &UpdateReconciler{Client: newFakeClient(t, objs...), Recorder: record.NewFakeRecorder(10)}
+
+		if granted := r.approvalGranted(context.Background(), update); granted != tt.granted {
+			t.Errorf("%s: approvalGranted() = %v, want %v", name, granted, tt.granted)
+		}
+	}
+}
+
+func TestAutomationAllowedApproval(t *testing.T) {
+	for _, webhook := range []bool{false, true} {
+		update := &opsv1alpha1.Update{
+			ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik"},
+			Spec:       opsv1alpha1.UpdateSpec{Automation: &opsv1alpha1.UpdateAutomation{Enabled: true, RequireApproval: true}},
+		}
+		// Approved by writing the fields the webhook records
+		c := newFakeClient(t, update, approvalOf(update, "17.1.0", "mallory"))
+		r := &UpdateReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(10), ApprovalWebhook: webhook}
+
+		allowed, _, reason := r.automationAllowed(context.Background(), update, "traefik", "17.1.0", nil, time.Now())
+		if allowed != webhook {
+			t.Errorf("webhook %v: automationAllowed() = %v (%s), want %v", webhook, allowed, reason, webhook)
+		}
+		if unavailable := meta.IsStatusConditionTrue(update.Status.Conditions, "ApprovalUnavailable"); unavailable == webhook {
+			t.Errorf("webhook %v: ApprovalUnavailable = %v, want %v", webhook, unavailable, !webhook)
+		}
+	}
+}
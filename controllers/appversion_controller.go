@@ -18,12 +18,15 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
@@ -35,7 +38,8 @@ import (
 // AppVersionReconciler reconciles a AppVersion object
 type AppVersionReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=ops.getais.cloud,resources=appversions,verbs=get;list;watch;create;update;patch;delete
@@ -70,6 +74,7 @@ func (r *AppVersionReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			log.Error(err, "Failed to create new Update")
 			return ctrl.Result{RequeueAfter: 2 * time.Minute}, err
 		}
+		recordLineageEvent(ctx, r.Client, r.Recorder, appver, corev1.EventTypeNormal, ReasonUpdateCreated, fmt.Sprintf("Created Update %s", update.Name))
 		// Update created successfully
 		return ctrl.Result{}, nil
 	} else if err != nil {
@@ -97,14 +102,7 @@ func (r *AppVersionReconciler) NewUpdate(a *opsv1alpha1.AppVersion) *opsv1alpha1
 		},
 		Spec: opsv1alpha1.UpdateSpec{
 			Versioning: opsv1alpha1.UpdateVersioning{
-				Sources: []opsv1alpha1.UpdateSource{
-					{
-						Name:    a.Spec.Name,
-						Type:    a.Spec.Type,
-						Source:  a.Spec.Source,
-						Version: a.Spec.Version,
-					},
-				},
+				Sources: []opsv1alpha1.UpdateSource{*a.Spec.DeepCopy()},
 			},
 		},
 	}
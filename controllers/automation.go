@@ -0,0 +1,443 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+const (
+	// defaultRolloutTimeout is used for Updates that don't set
+	// spec.automation.rolloutTimeout.
+	defaultRolloutTimeout = 10 * time.Minute
+	// rolloutPollPeriod is how often rollouts are checked for completion.
+	rolloutPollPeriod = 15 * time.Second
+)
+
+// Automation event reasons.
+const (
+	ReasonUpdateApplying   = "UpdateApplying"
+	ReasonUpdateProposed   = "UpdateProposed"
+	ReasonUpdatePostponed  = "UpdatePostponed"
+	ReasonUpdateApplied    = "UpdateApplied"
+	ReasonUpdateFailed     = "UpdateFailed"
+	ReasonUpdateRolledBack = "UpdateRolledBack"
+)
+
+// bumper applies versions of a source to the object it was discovered on.
+type bumper interface {
+	// Target describes the object, e.g. Deployment/traefik.
+	Target() string
+	// Object returns the object versions are applied to.
+	Object() client.Object
+	// Current returns what applying a version replaces, e.g. the image of
+	// a container.
+	Current() (string, error)
+	// Apply sets a version, returning what was set.
+	Apply(ctx context.Context, version string) (applied string, err error)
+	// Restore sets back what a version replaced.
+	Restore(ctx context.Context, previous string) error
+	// Rollout reports whether the applied version rolled out, failing when
+	// it can't roll out anymore.
+	Rollout(ctx context.Context) (bool, error)
+}
+
+// proposer is implemented by bumpers which propose versions in pull
+// requests. Proposed versions are applied once the pull request is merged,
+// which Rollout reports.
+type proposer interface {
+	// PullRequest returns the url of the pull request opened by Apply,
+	// empty when the version was applied directly.
+	PullRequest() string
+}
+
+// gate is implemented by bumpers whose targets only accept changes at
+// certain times of their own, such as Argo CD Applications with sync
+// windows.
+type gate interface {
+	// Allowed reports whether versions can be applied now, or why not.
+	Allowed(ctx context.Context) (bool, string, error)
+}
+
+// automationEnabled reports whether new versions are applied to target,
+// either through spec.automation or the auto-update annotation on the
+// Update or the target.
+func automationEnabled(update *opsv1alpha1.Update, target client.Object) bool {
+	if update.Spec.Automation != nil && update.Spec.Automation.Enabled {
+		return true
+	}
+	if update.Annotations[opsv1alpha1.AutoUpdateAnnotation] == "true" {
+		return true
+	}
+	return target != nil && target.GetAnnotations()[opsv1alpha1.AutoUpdateAnnotation] == "true"
+}
+
+// rolloutInProgress reports whether an automatically applied version is
+// still rolling out.
+func rolloutInProgress(update *opsv1alpha1.Update) bool {
+	return update.Status.Automation != nil && update.Status.Automation.Phase == opsv1alpha1.AutomationProgressing
+}
+
+// automate applies the newest version allowed by policy of the first
+// outdated source that can be automated. Versions which failed to roll out
+// aren't applied again. Versions awaiting approval or outside of
+// maintenance windows are left pending, and automate returns when to try
+// again, if known.
+//
+// The version is recorded as pending along with what it replaces before it
+// is applied, so a rollout interrupted by a restart can still be followed
+// and rolled back. Versions which couldn't be applied because of transient
+// errors stay pending and are applied again later.
+func (r *UpdateReconciler) automate(ctx context.Context, update *opsv1alpha1.Update, now time.Time) time.Time {
+	log := ctrllog.FromContext(ctx)
+
+	for _, s := range update.Spec.Versioning.Sources {
+		status := update.Status.Source(s.Name)
+		if status == nil || !status.Outdated || status.Error != "" {
+			continue
+		}
+		last := update.Status.Automation
+		if last != nil && last.Phase != opsv1alpha1.AutomationPending && last.Source == s.Name && last.Version == status.LatestInPolicy {
+			continue
+		}
+
+		b, err := r.bumperFor(ctx, update, s)
+		if err != nil {
+			log.V(1).Info("Source can't be automated", "source", s.Name, "reason", err.Error())
+			continue
+		}
+		if !automationEnabled(update, b.Object()) {
+			continue
+		}
+		if allowed, retry, reason := r.automationAllowed(ctx, update, s.Name, status.LatestInPolicy, b, now); !allowed {
+			r.setPending(ctx, update, s.Name, status.LatestInPolicy, b.Target(), reason)
+			return retry
+		}
+
+		auto := &opsv1alpha1.AutomationStatus{
+			Phase:     opsv1alpha1.AutomationPending,
+			Source:    s.Name,
+			Version:   status.LatestInPolicy,
+			Target:    b.Target(),
+			StartTime: &metav1.Time{Time: now},
+		}
+		if last != nil && last.Phase == opsv1alpha1.AutomationPending && last.Source == s.Name && last.Version == auto.Version && last.Previous != "" {
+			// Applying was interrupted, the target may already run the version
+			auto.Previous = last.Previous
+		} else if auto.Previous, err = b.Current(); err != nil {
+			r.failAutomation(ctx, update, auto, now, err)
+			return time.Time{}
+		}
+		auto.Message = fmt.Sprintf("Pending: updating %s from %s to %s", auto.Target, auto.Previous, auto.Version)
+		update.Status.Automation = auto
+		if err := r.Status().Update(ctx, update); err != nil {
+			log.Error(err, "Failed to record pending update", "source", s.Name)
+			return now.Add(retryPeriod)
+		}
+
+		auto.Applied, err = b.Apply(ctx, auto.Version)
+		if err != nil {
+			if isTransient(err) {
+				log.Error(err, "Failed to apply update, retrying", "source", s.Name, "version", auto.Version)
+				auto.Message = fmt.Sprintf("Pending: failed to apply %s to %s, retrying: %s", auto.Version, auto.Target, err)
+				return now.Add(retryPeriod)
+			}
+			r.failAutomation(ctx, update, auto, now, err)
+			return time.Time{}
+		}
+		if p, ok := b.(proposer); ok && p.PullRequest() != "" {
+			auto.Phase = opsv1alpha1.AutomationProposed
+			auto.PullRequest = p.PullRequest()
+			auto.Message = fmt.Sprintf("Proposed updating %s from %s to %s in %s", auto.Target, auto.Previous, auto.Applied, auto.PullRequest)
+			recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeNormal, ReasonUpdateProposed, auto.Message)
+			return time.Time{}
+		}
+		auto.Phase = opsv1alpha1.AutomationProgressing
+		auto.Message = fmt.Sprintf("Updating %s from %s to %s", auto.Target, auto.Previous, auto.Applied)
+		recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeNormal, ReasonUpdateApplying, auto.Message)
+		return time.Time{}
+	}
+	return time.Time{}
+}
+
+// failAutomation records a version which can't be applied, it isn't
+// applied again.
+func (r *UpdateReconciler) failAutomation(ctx context.Context, update *opsv1alpha1.Update, auto *opsv1alpha1.AutomationStatus, now time.Time, err error) {
+	auto.Phase = opsv1alpha1.AutomationFailed
+	auto.Message = fmt.Sprintf("Failed to apply %s to %s: %s", auto.Version, auto.Target, err)
+	auto.CompletionTime = &metav1.Time{Time: now}
+	update.Status.Automation = auto
+	recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeWarning, ReasonUpdateFailed, auto.Message)
+}
+
+// isTransient reports whether applying or following a version may succeed
+// when tried again: on conflicts, throttling, timeouts, unavailable API
+// servers or networks, and errors bumpers marked for retry.
+func isTransient(err error) bool {
+	var netErr net.Error
+	var retry retryError
+	return errors.As(err, &retry) || apierrors.IsConflict(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) || apierrors.IsInternalError(err) || apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsUnexpectedServerError(err) || errors.As(err, &netErr)
+}
+
+// retryError marks errors of bumpers worth trying again, which isTransient
+// can't tell apart otherwise.
+type retryError struct {
+	err error
+}
+
+func (e retryError) Error() string { return e.err.Error() }
+
+func (e retryError) Unwrap() error { return e.err }
+
+// automationAllowed reports whether a version of a source can be applied to
+// the target of b now, or why not and when to try again, if known.
+func (r *UpdateReconciler) automationAllowed(ctx context.Context, update *opsv1alpha1.Update, source, version string, b bumper, now time.Time) (bool, time.Time, string) {
+	required, err := r.approvalRequired(ctx, update)
+	if err != nil {
+		return false, now.Add(retryPeriod), fmt.Sprintf("failed to look up whether approval is required: %s", err)
+	}
+	if required && !r.ApprovalWebhook {
+		meta.SetStatusCondition(&update.Status.Conditions, metav1.Condition{Type: "ApprovalUnavailable", Status: metav1.ConditionTrue, Reason: "WebhookNotServed",
+			Message: "Approvals are only accepted while the approval webhook is served, enable it with ENABLE_WEBHOOKS=true"})
+		return false, time.Time{}, "approval required, but the approval webhook isn't served"
+	}
+	meta.RemoveStatusCondition(&update.Status.Conditions, "ApprovalUnavailable")
+	if required {
+		approval, err := r.approval(ctx, update, source, version)
+		if err != nil {
+			return false, now.Add(retryPeriod), fmt.Sprintf("failed to request approval: %s", err)
+		}
+		if !approval.Approved(version) {
+			return false, time.Time{}, fmt.Sprintf("awaiting approval, annotate UpdateApproval %s with %s=true",
+				approval.Name, opsv1alpha1.ApproveAnnotation)
+		}
+	}
+
+	open, next, err := maintenanceWindow(ctx, r.Client, update, r.AdminNamespace, now)
+	if err != nil {
+		return false, now.Add(retryPeriod), fmt.Sprintf("failed to look up maintenance windows: %s", err)
+	}
+	if !open {
+		return false, next, fmt.Sprintf("next window at %s", next.Format(time.RFC3339))
+	}
+	if g, ok := b.(gate); ok {
+		allowed, reason, err := g.Allowed(ctx)
+		if err != nil {
+			return false, now.Add(retryPeriod), err.Error()
+		}
+		if !allowed {
+			return false, time.Time{}, reason
+		}
+	}
+	return true, time.Time{}, ""
+}
+
+// setPending records a version waiting to be applied, announcing it once.
+func (r *UpdateReconciler) setPending(ctx context.Context, update *opsv1alpha1.Update, source, version, target, reason string) {
+	last := update.Status.Automation
+	if last == nil || last.Phase != opsv1alpha1.AutomationPending || last.Source != source || last.Version != version {
+		recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeNormal, ReasonUpdatePostponed,
+			fmt.Sprintf("Postponed updating %s to %s: %s", target, version, reason))
+	}
+	update.Status.Automation = &opsv1alpha1.AutomationStatus{
+		Phase:   opsv1alpha1.AutomationPending,
+		Source:  source,
+		Version: version,
+		Target:  target,
+		Message: "Pending: " + reason,
+	}
+}
+
+// watchRollout follows the rollout of an applied version. Once rolled out
+// the version becomes the installed version of the source, a failed rollout
+// is rolled back if requested.
+func (r *UpdateReconciler) watchRollout(ctx context.Context, update *opsv1alpha1.Update, now time.Time) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+	auto := update.Status.Automation
+
+	var b bumper
+	var done bool
+	source := update.Spec.Versioning.Source(auto.Source)
+	err := fmt.Errorf("source %s was removed", auto.Source)
+	if source != nil {
+		if b, err = r.bumperFor(ctx, update, *source); err == nil {
+			done, err = b.Rollout(ctx)
+		}
+	}
+	if err != nil && isTransient(err) {
+		// Following the rollout failed rather than the rollout itself
+		log.Error(err, "Failed to follow rollout", "target", auto.Target)
+		err = nil
+	}
+	if err == nil && !done && now.Sub(auto.StartTime.Time) > rolloutTimeout(update) {
+		err = fmt.Errorf("rollout didn't complete within %s", rolloutTimeout(update))
+	}
+
+	switch {
+	case err != nil:
+		auto.Phase = opsv1alpha1.AutomationFailed
+		auto.Message = fmt.Sprintf("Update of %s to %s failed: %s", auto.Target, auto.Version, err)
+		auto.CompletionTime = &metav1.Time{Time: now}
+		reason := ReasonUpdateFailed
+		if b != nil && update.Spec.Automation != nil && update.Spec.Automation.Rollback {
+			if rerr := b.Restore(ctx, auto.Previous); rerr != nil {
+				auto.Message += fmt.Sprintf(", rollback failed: %s", rerr)
+			} else {
+				auto.Phase = opsv1alpha1.AutomationRolledBack
+				auto.Message += fmt.Sprintf(", rolled back to %s", auto.Previous)
+				reason = ReasonUpdateRolledBack
+			}
+		}
+		recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeWarning, reason, auto.Message)
+	case done:
+		auto.Phase = opsv1alpha1.AutomationApplied
+		auto.Message = fmt.Sprintf("Updated %s to %s", auto.Target, auto.Applied)
+		auto.CompletionTime = &metav1.Time{Time: now}
+		recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeNormal, ReasonUpdateApplied, auto.Message)
+	default:
+		return ctrl.Result{RequeueAfter: rolloutPollPeriod}, nil
+	}
+
+	if err := r.Status().Update(ctx, update); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{RequeueAfter: retryPeriod}, nil
+	}
+	if auto.Phase == opsv1alpha1.AutomationApplied {
+		if err := r.setInstalledVersion(ctx, update, auto.Source, auto.Version); err != nil {
+			log.Error(err, "Failed to record installed version")
+			return ctrl.Result{RequeueAfter: retryPeriod}, nil
+		}
+	}
+
+	if due, wait := checkDue(update, now); !due {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// checkProposal follows the pull request proposing a version, reporting
+// whether it was merged so the version is recorded as installed. A pull
+// request closed without merging fails the automated update, and the
+// version isn't proposed again.
+func (r *UpdateReconciler) checkProposal(ctx context.Context, update *opsv1alpha1.Update, now time.Time) bool {
+	log := ctrllog.FromContext(ctx)
+	auto := update.Status.Automation
+	if auto == nil || auto.Phase != opsv1alpha1.AutomationProposed {
+		return false
+	}
+
+	var merged bool
+	source := update.Spec.Versioning.Source(auto.Source)
+	err := fmt.Errorf("source %s was removed", auto.Source)
+	if source != nil {
+		var b bumper
+		if b, err = r.bumperFor(ctx, update, *source); err == nil {
+			merged, err = b.Rollout(ctx)
+		}
+	}
+
+	switch {
+	case errors.Is(err, errPullRequestClosed):
+		auto.Phase = opsv1alpha1.AutomationFailed
+		auto.Message = fmt.Sprintf("Update of %s to %s failed: %s", auto.Target, auto.Version, err)
+		auto.CompletionTime = &metav1.Time{Time: now}
+		recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeWarning, ReasonUpdateFailed, auto.Message)
+	case err != nil:
+		log.Error(err, "Failed to check pull request", "url", auto.PullRequest)
+	case merged:
+		auto.Phase = opsv1alpha1.AutomationApplied
+		auto.Message = fmt.Sprintf("Updated %s to %s, %s was merged", auto.Target, auto.Applied, auto.PullRequest)
+		auto.CompletionTime = &metav1.Time{Time: now}
+		recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeNormal, ReasonUpdateApplied, auto.Message)
+		return true
+	}
+	return false
+}
+
+// setInstalledVersion records a rolled out version as the installed version
+// of the source, on the Update as well as the AppVersion it was created for.
+// Changing the spec checks the Update again right away.
+func (r *UpdateReconciler) setInstalledVersion(ctx context.Context, update *opsv1alpha1.Update, source, version string) error {
+	if s := update.Spec.Versioning.Source(source); s != nil {
+		s.Version = version
+	}
+	if err := r.Update(ctx, update); err != nil {
+		return err
+	}
+
+	owner := metav1.GetControllerOf(update)
+	if owner == nil || owner.Kind != "AppVersion" {
+		return nil
+	}
+	appver := &opsv1alpha1.AppVersion{}
+	if err := r.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: update.Namespace}, appver); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if appver.Spec.Name != source || appver.Spec.Version == version {
+		return nil
+	}
+	appver.Spec.Version = version
+	return r.Update(ctx, appver)
+}
+
+// bumperFor returns the bumper of a source. Versions are written to Git
+// when the Update configures it, otherwise the owner references from the
+// Update are followed to the AppVersion and the object it was discovered on.
+func (r *UpdateReconciler) bumperFor(ctx context.Context, update *opsv1alpha1.Update, s opsv1alpha1.UpdateSource) (bumper, error) {
+	if a := update.Spec.Automation; a != nil && a.Git != nil {
+		return newGitBumper(ctx, r.Client, update, s)
+	}
+	return workloadBumper(ctx, r.Client, update, s)
+}
+
+// workloadBumper follows the owner references from the Update to the
+// AppVersion and the Deployment or Application it was discovered on.
+func workloadBumper(ctx context.Context, c client.Client, update *opsv1alpha1.Update, s opsv1alpha1.UpdateSource) (bumper, error) {
+	owner := metav1.GetControllerOf(update)
+	if owner == nil || owner.Kind != "AppVersion" {
+		return nil, fmt.Errorf("update wasn't discovered from a workload")
+	}
+	appver := &opsv1alpha1.AppVersion{}
+	if err := c.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: update.Namespace}, appver); err != nil {
+		return nil, err
+	}
+
+	if key, ok := applicationOf(appver); ok {
+		if !isHelmType(s.Type) {
+			return nil, fmt.Errorf("%s sources of an Application can't be updated automatically", s.Type)
+		}
+		return newApplicationBumper(ctx, c, key)
+	}
+
+	discovered := metav1.GetControllerOf(appver)
+	switch {
+	case discovered == nil:
+		return nil, fmt.Errorf("AppVersion %s wasn't discovered from a workload", appver.Name)
+	case discovered.Kind == "Deployment" && isImageType(s.Type):
+		return newDeploymentBumper(ctx, c, types.NamespacedName{Name: discovered.Name, Namespace: appver.Namespace}, s.Source)
+	}
+	return nil, fmt.Errorf("%s sources of a %s can't be updated automatically", s.Type, discovered.Kind)
+}
+
+// rolloutTimeout returns how long rollouts of the Update may take.
+func rolloutTimeout(update *opsv1alpha1.Update) time.Duration {
+	if a := update.Spec.Automation; a != nil && a.RolloutTimeout != nil && a.RolloutTimeout.Duration > 0 {
+		return a.RolloutTimeout.Duration
+	}
+	return defaultRolloutTimeout
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// failingClient fails requests about Deployments with the errors given.
+type failingClient struct {
+	client.Client
+	getErr, patchErr error
+}
+
+func (c failingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*appsv1.Deployment); ok && c.getErr != nil {
+		return c.getErr
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c failingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if _, ok := obj.(*appsv1.Deployment); ok && c.patchErr != nil {
+		return c.patchErr
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// automatedDeployment returns the objects of a Deployment running shop
+// v1.2.0 whose Update found v1.3.0 and applies it automatically.
+func automatedDeployment() (*opsv1alpha1.Update, *opsv1alpha1.AppVersion, *appsv1.Deployment, *corev1.Namespace) {
+	controller := true
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop", UID: "deployment-uid", Generation: 1},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas, Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "shop", Image: "ghcr.io/getais/shop:v1.2.0"}},
+		}}},
+		Status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+	}
+	appver := &opsv1alpha1.AppVersion{ObjectMeta: metav1.ObjectMeta{
+		Name: "shop", Namespace: "shop", UID: "appversion-uid",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "shop", UID: deployment.UID, Controller: &controller}},
+	}}
+	update := &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "shop", Namespace: "shop",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: opsv1alpha1.GroupVersion.String(), Kind: "AppVersion", Name: "shop", UID: appver.UID, Controller: &controller}},
+		},
+		Spec: opsv1alpha1.UpdateSpec{
+			Automation: &opsv1alpha1.UpdateAutomation{Enabled: true},
+			Versioning: opsv1alpha1.UpdateVersioning{Sources: []opsv1alpha1.UpdateSource{
+				{Name: "shop", Type: "image", Source: "ghcr.io/getais/shop", Version: "v1.2.0"},
+			}},
+		},
+		Status: opsv1alpha1.UpdateStatus{Sources: []opsv1alpha1.SourceStatus{
+			{Name: "shop", Type: "image", CurrentVersion: "v1.2.0", LatestInPolicy: "v1.3.0", Outdated: true},
+		}},
+	}
+	return update, appver, deployment, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+}
+
+func TestAutomateApply(t *testing.T) {
+	update, appver, deployment, ns := automatedDeployment()
+	c := newFakeClient(t, update, appver, deployment, ns)
+	r := &UpdateReconciler{Client: c, Recorder: record.NewFakeRecorder(10)}
+	ctx := context.Background()
+	now := time.Now()
+
+	if retry := r.automate(ctx, update, now); !retry.IsZero() {
+		t.Errorf("automate() = %s, want no retry", retry)
+	}
+	auto := update.Status.Automation
+	if auto == nil || auto.Phase != opsv1alpha1.AutomationProgressing || auto.Previous != "ghcr.io/getais/shop:v1.2.0" || auto.Applied != "ghcr.io/getais/shop:v1.3.0" {
+		t.Fatalf("automation = %+v, want v1.3.0 progressing", auto)
+	}
+
+	got := &appsv1.Deployment{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(deployment), got); err != nil {
+		t.Fatal(err)
+	}
+	if image := got.Spec.Template.Spec.Containers[0].Image; image != "ghcr.io/getais/shop:v1.3.0" {
+		t.Errorf("image = %s, want ghcr.io/getais/shop:v1.3.0", image)
+	}
+	// What the version replaces was persisted before it was applied
+	stored := &opsv1alpha1.Update{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(update), stored); err != nil {
+		t.Fatal(err)
+	}
+	if a := stored.Status.Automation; a == nil || a.Phase != opsv1alpha1.AutomationPending || a.Previous != "ghcr.io/getais/shop:v1.2.0" {
+		t.Errorf("persisted automation = %+v, want v1.3.0 pending with the previous image", a)
+	}
+}
+
+func TestAutomateApplyErrors(t *testing.T) {
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	tests := map[string]struct {
+		err   error
+		phase opsv1alpha1.AutomationPhase
+		retry bool
+	}{
+		"conflict":    {err: apierrors.NewConflict(gr, "shop", nil), phase: opsv1alpha1.AutomationPending, retry: true},
+		"unavailable": {err: apierrors.NewServiceUnavailable("overloaded"), phase: opsv1alpha1.AutomationPending, retry: true},
+		"forbidden":   {err: apierrors.NewForbidden(gr, "shop", nil), phase: opsv1alpha1.AutomationFailed},
+		"invalid":     {err: apierrors.NewBadRequest("invalid image"), phase: opsv1alpha1.AutomationFailed},
+	}
+	for name, tt := range tests {
+		update, appver, deployment, ns := automatedDeployment()
+		r := &UpdateReconciler{Client: failingClient{Client: newFakeClient(t, update, appver, deployment, ns), patchErr: tt.err}, Recorder: record.NewFakeRecorder(10)}
+		now := time.Now()
+
+		retry := r.automate(context.Background(), update, now)
+		if auto := update.Status.Automation; auto == nil || auto.Phase != tt.phase {
+			t.Errorf("%s: automation = %+v, want %s", name, auto, tt.phase)
+		}
+		if retry.IsZero() == tt.retry {
+			t.Errorf("%s: automate() = %s, want retry %v", name, retry, tt.retry)
+		}
+		// Pending versions are applied again, failed ones aren't
+		if again := r.automate(context.Background(), update, now); again.IsZero() == tt.retry {
+			t.Errorf("%s: automate() again = %s, want retry %v", name, again, tt.retry)
+		}
+	}
+}
+
+func TestWatchRollout(t *testing.T) {
+	now := time.Now()
+	tests := map[string]struct {
+		rolledOut bool
+		started   time.Duration
+		rollback  bool
+		getErr    error
+		phase     opsv1alpha1.AutomationPhase
+		image     string
+		installed string
+	}{
+		"rolled out":           {rolledOut: true, started: time.Minute, phase: opsv1alpha1.AutomationApplied, image: "ghcr.io/getais/shop:v1.3.0", installed: "v1.3.0"},
+		"rolling out":          {started: time.Minute, phase: opsv1alpha1.AutomationProgressing, image: "ghcr.io/getais/shop:v1.3.0", installed: "v1.2.0"},
+		"timed out":            {started: time.Hour, phase: opsv1alpha1.AutomationFailed, image: "ghcr.io/getais/shop:v1.3.0", installed: "v1.2.0"},
+		"rolled back":          {started: time.Hour, rollback: true, phase: opsv1alpha1.AutomationRolledBack, image: "ghcr.io/getais/shop:v1.2.0", installed: "v1.2.0"},
+		"api unavailable":      {started: time.Minute, getErr: apierrors.NewServiceUnavailable("overloaded"), phase: opsv1alpha1.AutomationProgressing, image: "ghcr.io/getais/shop:v1.3.0", installed: "v1.2.0"},
+		"unavailable too long": {started: time.Hour, getErr: apierrors.NewServiceUnavailable("overloaded"), phase: opsv1alpha1.AutomationFailed, image: "ghcr.io/getais/shop:v1.3.0", installed: "v1.2.0"},
+	}
+	for name, tt := range tests {
+		update, appver, deployment, ns := automatedDeployment()
+		update.Spec.Automation.Rollback = tt.rollback
+		update.Status.Automation = &opsv1alpha1.AutomationStatus{
+			Phase: opsv1alpha1.AutomationProgressing, Source: "shop", Version: "v1.3.0", Target: "Deployment/shop",
+			Previous: "ghcr.io/getais/shop:v1.2.0", Applied: "ghcr.io/getais/shop:v1.3.0",
+			StartTime: &metav1.Time{Time: now.Add(-tt.started)},
+		}
+		deployment.Generation = 2
+		deployment.Spec.Template.Spec.Containers[0].Image = "ghcr.io/getais/shop:v1.3.0"
+		if tt.rolledOut {
+			deployment.Status.ObservedGeneration = 2
+		}
+		c := newFakeClient(t, update, appver, deployment, ns)
+		r := &UpdateReconciler{Client: failingClient{Client: c, getErr: tt.getErr}, Recorder: record.NewFakeRecorder(10)}
+
+		res, err := r.watchRollout(context.Background(), update, now)
+		if err != nil {
+			t.Errorf("%s: watchRollout() = %v", name, err)
+		}
+		if update.Status.Automation.Phase != tt.phase {
+			t.Errorf("%s: phase = %s (%s), want %s", name, update.Status.Automation.Phase, update.Status.Automation.Message, tt.phase)
+		}
+		if tt.phase == opsv1alpha1.AutomationProgressing && res.RequeueAfter != rolloutPollPeriod {
+			t.Errorf("%s: watchRollout() = %+v, want polled again", name, res)
+		}
+
+		got := &appsv1.Deployment{}
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), got); err != nil {
+			t.Fatal(err)
+		}
+		if image := got.Spec.Template.Spec.Containers[0].Image; image != tt.image {
+			t.Errorf("%s: image = %s, want %s", name, image, tt.image)
+		}
+		stored := &opsv1alpha1.Update{}
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(update), stored); err != nil {
+			t.Fatal(err)
+		}
+		if v := stored.Spec.Versioning.Sources[0].Version; v != tt.installed {
+			t.Errorf("%s: installed version = %s, want %s", name, v, tt.installed)
+		}
+	}
+}
+
+func TestDeploymentRollout(t *testing.T) {
+	deadline := appsv1.DeploymentCondition{Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded", Message: "ReplicaSet shop-5d9c has timed out progressing"}
+	tests := map[string]struct {
+		observed   int64
+		status     appsv1.DeploymentStatus
+		done, fail bool
+	}{
+		"not observed":      {observed: 1, status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}},
+		"updating":          {observed: 2, status: appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2}},
+		"old replicas left": {observed: 2, status: appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 3}},
+		"unavailable":       {observed: 2, status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}},
+		"rolled out":        {observed: 2, status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}, done: true},
+		"deadline exceeded": {observed: 2, status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1, Conditions: []appsv1.DeploymentCondition{deadline}}, fail: true},
+	}
+	for name, tt := range tests {
+		replicas := int32(2)
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "shop", Generation: 2}, Spec: appsv1.DeploymentSpec{Replicas: &replicas}, Status: tt.status}
+		d.Status.ObservedGeneration = tt.observed
+		done, err := (&deploymentBumper{deployment: d}).Rollout(context.Background())
+		if done != tt.done || (err != nil) != tt.fail {
+			t.Errorf("%s: Rollout() = %v, %v, want %v, failing %v", name, done, err, tt.done, tt.fail)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=argoproj.io,resources=appprojects,verbs=get;list;watch
+
+// applicationBumper updates the chart version an Argo CD Application
+// deploys, spec.source.targetRevision.
+type applicationBumper struct {
+	client client.Client
+	app    *argov1alpha1.Application
+}
+
+func newApplicationBumper(ctx context.Context, c client.Client, key types.NamespacedName) (*applicationBumper, error) {
+	app := &argov1alpha1.Application{}
+	if err := c.Get(ctx, key, app); err != nil {
+		return nil, err
+	}
+	if app.Spec.Source.Chart == "" {
+		return nil, fmt.Errorf("Application %s doesn't deploy a Helm chart", app.Name)
+	}
+	return &applicationBumper{client: c, app: app}, nil
+}
+
+func (b *applicationBumper) Target() string {
+	return "Application/" + b.app.Name
+}
+
+func (b *applicationBumper) Object() client.Object {
+	return b.app
+}
+
+// Allowed reports whether the sync windows of the project of the
+// Application allow syncing it now.
+func (b *applicationBumper) Allowed(ctx context.Context) (bool, string, error) {
+	project := &argov1alpha1.AppProject{}
+	key := types.NamespacedName{Name: b.app.Spec.GetProject(), Namespace: b.app.Namespace}
+	if err := b.client.Get(ctx, key, project); err != nil {
+		return false, "", fmt.Errorf("failed to get AppProject %s: %w", key.Name, err)
+	}
+	windows := project.Spec.SyncWindows.Matches(b.app)
+	if !windows.CanSync(false) {
+		return false, fmt.Sprintf("sync windows of project %s don't allow syncing %s", key.Name, b.Target()), nil
+	}
+	return true, "", nil
+}
+
+// Current returns the targetRevision of the Application.
+func (b *applicationBumper) Current() (string, error) {
+	return b.app.Spec.Source.TargetRevision, nil
+}
+
+// Apply sets the targetRevision of the Application to version.
+func (b *applicationBumper) Apply(ctx context.Context, version string) (string, error) {
+	return version, b.setRevision(ctx, version)
+}
+
+// Restore sets the targetRevision of the Application back to previous.
+func (b *applicationBumper) Restore(ctx context.Context, previous string) error {
+	return b.setRevision(ctx, previous)
+}
+
+// setRevision changes the targetRevision, requesting a sync of
+// Applications which aren't synced automatically.
+func (b *applicationBumper) setRevision(ctx context.Context, revision string) error {
+	patch := client.MergeFrom(b.app.DeepCopy())
+	b.app.Spec.Source.TargetRevision = revision
+	if policy := b.app.Spec.SyncPolicy; (policy == nil || policy.Automated == nil) && b.app.Operation == nil {
+		b.app.Operation = &argov1alpha1.Operation{
+			Sync:        &argov1alpha1.SyncOperation{Revision: revision},
+			InitiatedBy: argov1alpha1.OperationInitiator{Username: "kupdater", Automated: true},
+		}
+	}
+	return b.client.Patch(ctx, b.app, patch)
+}
+
+// Rollout reports whether Argo CD synced the Application to the new
+// revision and it became healthy. A failed sync or degraded health fails
+// the rollout.
+func (b *applicationBumper) Rollout(ctx context.Context) (bool, error) {
+	app := b.app
+	revision := app.Spec.Source.TargetRevision
+	if app.Status.Sync.ComparedTo.Source.TargetRevision != revision {
+		// Not compared to the new revision yet
+		return false, nil
+	}
+
+	if op := app.Status.OperationState; op != nil && op.Operation.Sync != nil && op.SyncResult != nil && op.SyncResult.Revision == revision {
+		if op.Phase == synccommon.OperationFailed || op.Phase == synccommon.OperationError {
+			return false, fmt.Errorf("sync %s: %s", strings.ToLower(string(op.Phase)), op.Message)
+		}
+	}
+
+	synced := app.Status.Sync.Status == argov1alpha1.SyncStatusCodeSynced && app.Status.Sync.Revision == revision
+	switch app.Status.Health.Status {
+	case health.HealthStatusDegraded:
+		if synced {
+			return false, fmt.Errorf("%s is degraded: %s", b.Target(), app.Status.Health.Message)
+		}
+	case health.HealthStatusHealthy:
+		return synced, nil
+	}
+	return false, nil
+}
+
+// applicationOf returns the Application an AppVersion was discovered from.
+func applicationOf(appver *opsv1alpha1.AppVersion) (types.NamespacedName, bool) {
+	if ref := appver.Annotations[opsv1alpha1.ApplicationAnnotation]; ref != "" {
+		if ns, name, ok := strings.Cut(ref, "/"); ok {
+			return types.NamespacedName{Namespace: ns, Name: name}, true
+		}
+	}
+	if owner := metav1.GetControllerOf(appver); owner != nil && owner.Kind == "Application" {
+		return types.NamespacedName{Namespace: appver.Namespace, Name: owner.Name}, true
+	}
+	return types.NamespacedName{}, false
+}
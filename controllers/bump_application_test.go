@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// chartApplication returns an Application deploying traefik 17.0.5 from
+// the apps project.
+func chartApplication() *argov1alpha1.Application {
+	return &argov1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "argocd"},
+		Spec: argov1alpha1.ApplicationSpec{
+			Project:     "apps",
+			Source:      argov1alpha1.ApplicationSource{RepoURL: "https://helm.traefik.io/traefik", Chart: "traefik", TargetRevision: "17.0.5"},
+			Destination: argov1alpha1.ApplicationDestination{Server: "https://kubernetes.default.svc", Namespace: "traefik"},
+		},
+	}
+}
+
+func TestApplicationSetRevision(t *testing.T) {
+	running := &argov1alpha1.Operation{Sync: &argov1alpha1.SyncOperation{Revision: "17.0.5"}}
+	tests := map[string]struct {
+		policy    *argov1alpha1.SyncPolicy
+		operation *argov1alpha1.Operation
+		sync      string
+	}{
+		"manual sync":         {sync: "17.1.0"},
+		"automated sync":      {policy: &argov1alpha1.SyncPolicy{Automated: &argov1alpha1.SyncPolicyAutomated{}}},
+		"operation running":   {operation: running, sync: "17.0.5"},
+		"no automated policy": {policy: &argov1alpha1.SyncPolicy{}, sync: "17.1.0"},
+	}
+	for name, tt := range tests {
+		app := chartApplication()
+		app.Spec.SyncPolicy, app.Operation = tt.policy, tt.operation
+		c := newFakeClient(t, app)
+		ctx := context.Background()
+		b, err := newApplicationBumper(ctx, c, client.ObjectKeyFromObject(app))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if applied, err := b.Apply(ctx, "17.1.0"); err != nil || applied != "17.1.0" {
+			t.Errorf("%s: Apply() = %s, %v, want 17.1.0", name, applied, err)
+		}
+		got := &argov1alpha1.Application{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(app), got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Spec.Source.TargetRevision != "17.1.0" {
+			t.Errorf("%s: targetRevision = %s, want 17.1.0", name, got.Spec.Source.TargetRevision)
+		}
+		var sync string
+		if got.Operation != nil && got.Operation.Sync != nil {
+			sync = got.Operation.Sync.Revision
+		}
+		if sync != tt.sync {
+			t.Errorf("%s: sync operation of %q, want %q", name, sync, tt.sync)
+		}
+	}
+}
+
+func TestApplicationBumperErrors(t *testing.T) {
+	app := chartApplication()
+	app.Spec.Source.Chart, app.Spec.Source.Path = "", "apps/traefik"
+	c := newFakeClient(t, app)
+	if _, err := newApplicationBumper(context.Background(), c, client.ObjectKeyFromObject(app)); err == nil {
+		t.Error("newApplicationBumper() of an Application without chart succeeded")
+	}
+	if _, err := newApplicationBumper(context.Background(), c, types.NamespacedName{Name: "missing", Namespace: "argocd"}); err == nil {
+		t.Error("newApplicationBumper() of a missing Application succeeded")
+	}
+}
+
+func TestApplicationAllowed(t *testing.T) {
+	always := func(kind string, apps ...string) *argov1alpha1.SyncWindow {
+		return &argov1alpha1.SyncWindow{Kind: kind, Schedule: "* * * * *", Duration: "1h", Applications: apps}
+	}
+	never := &argov1alpha1.SyncWindow{Kind: "allow", Schedule: "0 0 29 2 *", Duration: "1m", Applications: []string{"*"}}
+	tests := map[string]struct {
+		windows argov1alpha1.SyncWindows
+		allowed bool
+	}{
+		"no windows":              {allowed: true},
+		"deny window":             {windows: argov1alpha1.SyncWindows{always("deny", "traefik")}},
+		"deny window of others":   {windows: argov1alpha1.SyncWindows{always("deny", "grafana")}, allowed: true},
+		"allow window open":       {windows: argov1alpha1.SyncWindows{always("allow", "*")}, allowed: true},
+		"allow window closed":     {windows: argov1alpha1.SyncWindows{never}},
+		"deny overrides allowing": {windows: argov1alpha1.SyncWindows{always("allow", "*"), always("deny", "traef*")}},
+	}
+	for name, tt := range tests {
+		app := chartApplication()
+		project := &argov1alpha1.AppProject{
+			ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "argocd"},
+			Spec:       argov1alpha1.AppProjectSpec{SyncWindows: tt.windows},
+		}
+		b := &applicationBumper{client: newFakeClient(t, app, project), app: app}
+
+		allowed, reason, err := b.Allowed(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Allowed() = %v", name, err)
+		}
+		if allowed != tt.allowed || allowed == (reason != "") {
+			t.Errorf("%s: Allowed() = %v, %q, want %v", name, allowed, reason, tt.allowed)
+		}
+	}
+
+	app := chartApplication()
+	b := &applicationBumper{client: newFakeClient(t, app), app: app}
+	if _, _, err := b.Allowed(context.Background()); err == nil {
+		t.Error("Allowed() without AppProject succeeded")
+	}
+}
+
+func TestApplicationRollout(t *testing.T) {
+	type state struct {
+		compared, synced string
+		sync             argov1alpha1.SyncStatusCode
+		health           health.HealthStatusCode
+		operation        synccommon.OperationPhase
+	}
+	tests := map[string]struct {
+		state
+		done, fail bool
+	}{
+		"not compared":     {state: state{compared: "17.0.5", synced: "17.0.5", sync: argov1alpha1.SyncStatusCodeSynced, health: health.HealthStatusHealthy}},
+		"out of sync":      {state: state{compared: "17.1.0", synced: "17.0.5", sync: argov1alpha1.SyncStatusCodeOutOfSync, health: health.HealthStatusHealthy}},
+		"progressing":      {state: state{compared: "17.1.0", synced: "17.1.0", sync: argov1alpha1.SyncStatusCodeSynced, health: health.HealthStatusProgressing}},
+		"synced healthy":   {state: state{compared: "17.1.0", synced: "17.1.0", sync: argov1alpha1.SyncStatusCodeSynced, health: health.HealthStatusHealthy}, done: true},
+		"synced degraded":  {state: state{compared: "17.1.0", synced: "17.1.0", sync: argov1alpha1.SyncStatusCodeSynced, health: health.HealthStatusDegraded}, fail: true},
+		"degraded syncing": {state: state{compared: "17.1.0", synced: "17.0.5", sync: argov1alpha1.SyncStatusCodeOutOfSync, health: health.HealthStatusDegraded}},
+		"sync failed":      {state: state{compared: "17.1.0", synced: "17.0.5", sync: argov1alpha1.SyncStatusCodeOutOfSync, health: health.HealthStatusHealthy, operation: synccommon.OperationFailed}, fail: true},
+		"sync error":       {state: state{compared: "17.1.0", synced: "17.0.5", sync: argov1alpha1.SyncStatusCodeOutOfSync, health: health.HealthStatusHealthy, operation: synccommon.OperationError}, fail: true},
+		"sync running":     {state: state{compared: "17.1.0", synced: "17.0.5", sync: argov1alpha1.SyncStatusCodeOutOfSync, health: health.HealthStatusHealthy, operation: synccommon.OperationRunning}},
+	}
+	for name, tt := range tests {
+		app := chartApplication()
+		app.Spec.Source.TargetRevision = "17.1.0"
+		app.Status.Sync = argov1alpha1.SyncStatus{
+			Status:     tt.sync,
+			Revision:   tt.synced,
+			ComparedTo: argov1alpha1.ComparedTo{Source: argov1alpha1.ApplicationSource{TargetRevision: tt.compared}},
+		}
+		app.Status.Health = argov1alpha1.HealthStatus{Status: tt.health, Message: "pods crash looping"}
+		if tt.operation != "" {
+			app.Status.OperationState = &argov1alpha1.OperationState{
+				Operation:  argov1alpha1.Operation{Sync: &argov1alpha1.SyncOperation{Revision: "17.1.0"}},
+				Phase:      tt.operation,
+				Message:    "one or more objects failed to apply",
+				SyncResult: &argov1alpha1.SyncOperationResult{Revision: "17.1.0"},
+			}
+		}
+		b := &applicationBumper{client: newFakeClient(t, app), app: app}
+
+		done, err := b.Rollout(context.Background())
+		if done != tt.done || (err != nil) != tt.fail {
+			t.Errorf("%s: Rollout() = %v, %v, want %v, failed %v", name, done, err, tt.done, tt.fail)
+		}
+	}
+}
+
+func TestApplicationOf(t *testing.T) {
+	controller := true
+	owned := []metav1.OwnerReference{{APIVersion: "argoproj.io/v1alpha1", Kind: "Application", Name: "traefik", Controller: &controller}}
+	deployment := []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "traefik", Controller: &controller}}
+	tests := map[string]struct {
+		annotation string
+		owners     []metav1.OwnerReference
+		want       types.NamespacedName
+		ok         bool
+	}{
+		"annotation":           {annotation: "argocd/traefik", want: types.NamespacedName{Namespace: "argocd", Name: "traefik"}, ok: true},
+		"annotation and owner": {annotation: "argocd/traefik-prod", owners: owned, want: types.NamespacedName{Namespace: "argocd", Name: "traefik-prod"}, ok: true},
+		"owner":                {owners: owned, want: types.NamespacedName{Namespace: "traefik", Name: "traefik"}, ok: true},
+		"invalid annotation":   {annotation: "traefik", owners: owned, want: types.NamespacedName{Namespace: "traefik", Name: "traefik"}, ok: true},
+		"deployment":           {owners: deployment},
+		"none":                 {},
+	}
+	for name, tt := range tests {
+		appver := &opsv1alpha1.AppVersion{ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik", OwnerReferences: tt.owners}}
+		if tt.annotation != "" {
+			appver.Annotations = map[string]string{opsv1alpha1.ApplicationAnnotation: tt.annotation}
+		}
+		if got, ok := applicationOf(appver); got != tt.want || ok != tt.ok {
+			t.Errorf("%s: applicationOf() = %s, %v, want %s, %v", name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestWorkloadBumperApplication(t *testing.T) {
+	controller := true
+	app := chartApplication()
+	appver := &opsv1alpha1.AppVersion{ObjectMeta: metav1.ObjectMeta{
+		Name: "traefik", Namespace: "traefik", UID: "appversion-uid",
+		Annotations: map[string]string{opsv1alpha1.ApplicationAnnotation: "argocd/traefik"},
+	}}
+	update := &opsv1alpha1.Update{ObjectMeta: metav1.ObjectMeta{
+		Name: "traefik", Namespace: "traefik",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: opsv1alpha1.GroupVersion.String(), Kind: "AppVersion", Name: "traefik", UID: appver.UID, Controller: &controller}},
+	}}
+	c := newFakeClient(t, app, appver, update)
+
+	b, err := workloadBumper(context.Background(), c, update, opsv1alpha1.UpdateSource{Name: "chart", Type: "helm", Source: "https://helm.traefik.io/traefik"})
+	if err != nil {
+		t.Fatalf("workloadBumper() = %v", err)
+	}
+	if _, ok := b.(*applicationBumper); !ok || b.Target() != "Application/traefik" {
+		t.Errorf("workloadBumper() = %T %s, want Application/traefik", b, b.Target())
+	}
+	if _, err := workloadBumper(context.Background(), c, update, opsv1alpha1.UpdateSource{Name: "image", Type: "image", Source: "traefik"}); err == nil {
+		t.Error("workloadBumper() of an image source of an Application succeeded")
+	}
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/getais/kupdater/pkg/libs/registry"
+)
+
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update;patch
+
+// deploymentBumper updates the image tag of a Deployment container.
+type deploymentBumper struct {
+	client     client.Client
+	deployment *appsv1.Deployment
+	// image is the repository of the container being updated.
+	image string
+}
+
+func newDeploymentBumper(ctx context.Context, c client.Client, key types.NamespacedName, image string) (*deploymentBumper, error) {
+	deployment := &appsv1.Deployment{}
+	if err := c.Get(ctx, key, deployment); err != nil {
+		return nil, err
+	}
+	return &deploymentBumper{client: c, deployment: deployment, image: image}, nil
+}
+
+func (b *deploymentBumper) Target() string {
+	return "Deployment/" + b.deployment.Name
+}
+
+func (b *deploymentBumper) Object() client.Object {
+	return b.deployment
+}
+
+// container returns the container running the tracked image.
+func (b *deploymentBumper) container(d *appsv1.Deployment) (*corev1.Container, error) {
+	for i := range d.Spec.Template.Spec.Containers {
+		c := &d.Spec.Template.Spec.Containers[i]
+		if repository, _ := registry.SplitImage(c.Image); sameImage(b.image, repository) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no container of %s runs image %s", b.Target(), b.image)
+}
+
+// Current returns the image of the container.
+func (b *deploymentBumper) Current() (string, error) {
+	c, err := b.container(b.deployment)
+	if err != nil {
+		return "", err
+	}
+	return c.Image, nil
+}
+
+// Apply sets the image tag of the container to version.
+func (b *deploymentBumper) Apply(ctx context.Context, version string) (string, error) {
+	c, err := b.container(b.deployment)
+	if err != nil {
+		return "", err
+	}
+	repository, _ := registry.SplitImage(c.Image)
+	image := repository + ":" + version
+	return image, b.setImage(ctx, image)
+}
+
+// Restore sets the image of the container back to previous.
+func (b *deploymentBumper) Restore(ctx context.Context, previous string) error {
+	return b.setImage(ctx, previous)
+}
+
+func (b *deploymentBumper) setImage(ctx context.Context, image string) error {
+	patch := client.MergeFrom(b.deployment.DeepCopy())
+	c, err := b.container(b.deployment)
+	if err != nil {
+		return err
+	}
+	c.Image = image
+	return b.client.Patch(ctx, b.deployment, patch)
+}
+
+// Rollout reports whether every replica runs the current template, the way
+// kubectl rollout status does.
+func (b *deploymentBumper) Rollout(ctx context.Context) (bool, error) {
+	d := b.deployment
+	if d.Status.ObservedGeneration < d.Generation {
+		return false, nil
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return false, fmt.Errorf("%s exceeded its progress deadline: %s", b.Target(), c.Message)
+		}
+	}
+
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return d.Status.UpdatedReplicas >= replicas &&
+		d.Status.Replicas == d.Status.UpdatedReplicas &&
+		d.Status.AvailableReplicas >= d.Status.UpdatedReplicas, nil
+}
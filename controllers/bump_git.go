@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/forge"
+	"github.com/getais/kupdater/pkg/gitops"
+	"github.com/getais/kupdater/pkg/providers"
+)
+
+// gitAuthor signs the commits written by kupdater.
+var gitAuthor = object.Signature{Name: "kupdater", Email: "kupdater@getais.cloud"}
+
+// errPullRequestClosed is returned by Rollout for pull requests closed
+// without being merged.
+var errPullRequestClosed = errors.New("pull request was closed without merging")
+
+// gitBumper writes versions of a source to a file of a Git repository,
+// leaving the rollout to the tool syncing the repository. With a forge
+// configured, versions are proposed in a pull request instead.
+type gitBumper struct {
+	update *opsv1alpha1.Update
+	source opsv1alpha1.UpdateSource
+	repo   *gitops.Repository
+	branch string
+	file   opsv1alpha1.GitFile
+	forge  forge.Client
+	// pullRequest is the url of the pull request opened by Apply.
+	pullRequest string
+}
+
+func newGitBumper(ctx context.Context, c client.Client, update *opsv1alpha1.Update, s opsv1alpha1.UpdateSource) (*gitBumper, error) {
+	wb := update.Spec.Automation.Git
+	file := wb.File(s.Name)
+	if file == nil {
+		return nil, fmt.Errorf("no file of %s holds the version of %s", wb.URL, s.Name)
+	}
+	b := &gitBumper{
+		update: update,
+		source: s,
+		repo:   &gitops.Repository{URL: wb.URL, Branch: wb.Branch, Author: gitAuthor},
+		branch: wb.PushBranch,
+		file:   *file,
+	}
+
+	var credentials map[string][]byte
+	if wb.SecretRef != nil {
+		var err error
+		if credentials, err = secretData(ctx, c, update.Namespace, wb.SecretRef.Name); err != nil {
+			return nil, err
+		}
+		if b.repo.Auth, err = gitops.Auth(credentials); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", wb.SecretRef.Name, err)
+		}
+	}
+
+	pr := wb.PullRequest
+	if pr == nil {
+		return b, nil
+	}
+	if b.branch == "" {
+		b.branch = fmt.Sprintf("kupdater/%s-%s", update.Name, s.Name)
+	}
+	if b.branch == wb.Branch {
+		return nil, fmt.Errorf("pull requests can't be opened from the base branch %s", wb.Branch)
+	}
+	cfg := forge.Config{Type: pr.Forge, URL: pr.URL, Repository: pr.Repository}
+	if cfg.URL == "" || cfg.Repository == "" {
+		server, repository, err := forge.ParseRepository(wb.URL)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.URL == "" {
+			cfg.URL = server
+		}
+		if cfg.Repository == "" {
+			cfg.Repository = repository
+		}
+	}
+	if pr.SecretRef != nil {
+		var err error
+		if credentials, err = secretData(ctx, c, update.Namespace, pr.SecretRef.Name); err != nil {
+			return nil, err
+		}
+	}
+	cfg.Token = string(credentials["token"])
+
+	var err error
+	b.forge, err = forge.New(cfg)
+	return b, err
+}
+
+// secretData returns the data of a Secret.
+func secretData(ctx context.Context, c client.Client, namespace, name string) (map[string][]byte, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", name, err)
+	}
+	return secret.Data, nil
+}
+
+func (b *gitBumper) Target() string {
+	return fmt.Sprintf("%s in %s", b.file.Path, b.repo.URL)
+}
+
+// Object returns the Update, Git write-back is enabled on the Update only.
+func (b *gitBumper) Object() client.Object {
+	return b.update
+}
+
+// Current returns the installed version of the source, the one the file
+// holds.
+func (b *gitBumper) Current() (string, error) {
+	return b.source.Version, nil
+}
+
+// Apply commits version to the file and pushes it, opening or updating the
+// pull request of the branch if a forge is configured. Failing to open the
+// pull request is retried rather than failing the version.
+func (b *gitBumper) Apply(ctx context.Context, version string) (string, error) {
+	res, err := b.push(ctx, version)
+	if err != nil || b.forge == nil || res.Commit == "" {
+		return version, err
+	}
+
+	pr, err := forge.Open(ctx, b.forge, forge.PullRequest{
+		Head:  res.Branch,
+		Base:  res.Base,
+		Title: fmt.Sprintf("Update %s to %s", b.source.Name, version),
+		Body:  b.pullRequestBody(version),
+	})
+	if err != nil {
+		// The branch is pushed again and the pull request opened on the
+		// next try
+		return version, retryError{fmt.Errorf("opening pull request: %w", err)}
+	}
+	b.pullRequest = pr.URL
+	return version, nil
+}
+
+// Restore commits the previous version back.
+func (b *gitBumper) Restore(ctx context.Context, previous string) error {
+	_, err := b.push(ctx, previous)
+	return err
+}
+
+func (b *gitBumper) push(ctx context.Context, version string) (gitops.Result, error) {
+	change := gitops.Change{
+		Branch:  b.branch,
+		Message: fmt.Sprintf("Update %s to %s", b.source.Name, version),
+		Edits: []gitops.Edit{{
+			Path:    b.file.Path,
+			Format:  b.file.Format,
+			Key:     b.file.Key,
+			Version: version,
+		}},
+	}
+	res, err := b.repo.Push(ctx, change)
+	if errors.Is(err, gitops.ErrNoChanges) {
+		return res, nil
+	}
+	return res, err
+}
+
+// pullRequestBody describes the version change for reviewers.
+func (b *gitBumper) pullRequestBody(version string) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Updates **%s** from `%s` to `%s` in `%s`.\n", b.source.Name, b.source.Version, version, b.file.Path)
+	if notes := releaseNotesURL(b.source, version); notes != "" {
+		fmt.Fprintf(&body, "\nRelease notes: %s\n", notes)
+	}
+	fmt.Fprintf(&body, "\n---\nOpened by kupdater for Update `%s/%s`. Newer versions update this pull request.\n", b.update.Namespace, b.update.Name)
+	return body.String()
+}
+
+// PullRequest returns the url of the pull request opened by Apply.
+func (b *gitBumper) PullRequest() string {
+	return b.pullRequest
+}
+
+// Rollout reports pushed versions as rolled out, syncing the repository is
+// up to the GitOps tooling. Proposed versions roll out once their pull
+// request is merged.
+func (b *gitBumper) Rollout(ctx context.Context) (bool, error) {
+	auto := b.update.Status.Automation
+	if b.forge == nil || auto == nil || auto.PullRequest == "" {
+		return true, nil
+	}
+	number, err := forge.Number(auto.PullRequest)
+	if err != nil {
+		return false, err
+	}
+	pr, err := b.forge.Get(ctx, number)
+	if err != nil {
+		return false, err
+	}
+	switch pr.State {
+	case forge.StateMerged:
+		return true, nil
+	case forge.StateClosed:
+		return false, errPullRequestClosed
+	}
+	return false, nil
+}
+
+// releaseNotesURL returns the page of the release notes of a version, if
+// the source publishes them.
+func releaseNotesURL(s opsv1alpha1.UpdateSource, version string) string {
+	if providers.Normalize(s.Type) == "github" {
+		return strings.TrimSuffix(strings.TrimSuffix(s.Source, "/"), ".git") + "/releases/tag/" + version
+	}
+	return ""
+}
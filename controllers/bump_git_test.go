@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/memory"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/gitops"
+)
+
+func init() {
+	// Serve local repositories in process rather than through git binaries
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+// valuesRepository returns the url of a bare repository whose main branch
+// holds values of shop v1.2.0.
+func valuesRepository(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, _ := repo.Worktree()
+	f, _ := tree.Filesystem.Create("apps/shop/values.yaml")
+	f.Write([]byte("image:\n  tag: v1.2.0\n"))
+	f.Close()
+	tree.Add("apps/shop/values.yaml")
+	_, err = tree.Commit("Add shop", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := "file://" + dir
+	repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}})
+	if err := repo.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/main"}}); err != nil {
+		t.Fatal(err)
+	}
+	bare, _ := git.PlainOpen(dir)
+	bare.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main")))
+	return url
+}
+
+// giteaPull is a pull request as served by fakeGitea.
+type giteaPull struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+// fakeGitea serves the pull requests API of getais/homelab, failing every
+// request while down.
+type fakeGitea struct {
+	mu    sync.Mutex
+	pulls []*giteaPull
+	down  bool
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		http.Error(w, `{"message":"bad gateway"}`, http.StatusBadGateway)
+		return
+	}
+
+	const prefix = "/api/v1/repos/getais/homelab/pulls"
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+	switch number := strings.TrimPrefix(r.URL.Path, prefix); {
+	case number == "" && r.Method == http.MethodGet:
+		open := []*giteaPull{}
+		for _, pr := range f.pulls {
+			if pr.State == "open" && r.URL.Query().Get("page") == "1" {
+				open = append(open, pr)
+			}
+		}
+		json.NewEncoder(w).Encode(open)
+	case number == "" && r.Method == http.MethodPost:
+		n := len(f.pulls) + 1
+		pr := &giteaPull{
+			Number:  n,
+			HTMLURL: fmt.Sprintf("https://gitea.example.com/getais/homelab/pulls/%d", n),
+			Title:   body["title"],
+			Body:    body["body"],
+			State:   "open",
+		}
+		pr.Head.Ref, pr.Base.Ref = body["head"], body["base"]
+		f.pulls = append(f.pulls, pr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pr)
+	default:
+		var n int
+		fmt.Sscanf(number, "/%d", &n)
+		if n < 1 || n > len(f.pulls) {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(f.pulls[n-1])
+	}
+}
+
+// proposedUpdate returns an Update of shop v1.2.0 which found v1.3.0 and
+// proposes it in pull requests on the Gitea serving forgeURL.
+func proposedUpdate(repoURL, forgeURL string) (*opsv1alpha1.Update, *corev1.Namespace) {
+	update := &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop"},
+		Spec: opsv1alpha1.UpdateSpec{
+			Automation: &opsv1alpha1.UpdateAutomation{Enabled: true, Git: &opsv1alpha1.GitWriteBack{
+				URL:         repoURL,
+				Branch:      "main",
+				Files:       []opsv1alpha1.GitFile{{Source: "shop", Path: "apps/shop/values.yaml", Format: gitops.HelmValues, Key: "image.tag"}},
+				PullRequest: &opsv1alpha1.GitPullRequest{Forge: "gitea", URL: forgeURL, Repository: "getais/homelab"},
+			}},
+			Versioning: opsv1alpha1.UpdateVersioning{Sources: []opsv1alpha1.UpdateSource{
+				{Name: "shop", Type: "image", Source: "ghcr.io/getais/shop", Version: "v1.2.0"},
+			}},
+		},
+		Status: opsv1alpha1.UpdateStatus{Sources: []opsv1alpha1.SourceStatus{
+			{Name: "shop", Type: "image", CurrentVersion: "v1.2.0", LatestInPolicy: "v1.3.0", Outdated: true},
+		}},
+	}
+	return update, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+}
+
+func TestAutomatePullRequest(t *testing.T) {
+	forge := &fakeGitea{down: true}
+	srv := httptest.NewServer(forge)
+	defer srv.Close()
+	update, ns := proposedUpdate(valuesRepository(t), srv.URL)
+	r := &UpdateReconciler{Client: newFakeClient(t, update, ns), Recorder: record.NewFakeRecorder(10)}
+	ctx := context.Background()
+	now := time.Now()
+
+	// The branch is pushed but the forge is down
+	if retry := r.automate(ctx, update, now); retry.IsZero() {
+		t.Error("automate() with the forge down = no retry, want a retry")
+	}
+	if auto := update.Status.Automation; auto == nil || auto.Phase != opsv1alpha1.AutomationPending || auto.Version != "v1.3.0" {
+		t.Fatalf("automation = %+v, want v1.3.0 pending", auto)
+	}
+
+	forge.mu.Lock()
+	forge.down = false
+	forge.mu.Unlock()
+	if retry := r.automate(ctx, update, now); !retry.IsZero() {
+		t.Errorf("automate() = %s, want no retry", retry)
+	}
+	auto := update.Status.Automation
+	if auto == nil || auto.Phase != opsv1alpha1.AutomationProposed || auto.PullRequest != "https://gitea.example.com/getais/homelab/pulls/1" {
+		t.Fatalf("automation = %+v, want v1.3.0 proposed in pull request 1", auto)
+	}
+	if len(forge.pulls) != 1 || forge.pulls[0].Head.Ref != "kupdater/shop-shop" || forge.pulls[0].Base.Ref != "main" {
+		t.Errorf("pull requests = %+v, want one from kupdater/shop-shop into main", forge.pulls)
+	}
+}
+
+func TestCheckProposal(t *testing.T) {
+	tests := map[string]struct {
+		state   string
+		merged  bool
+		down    bool
+		applied bool
+		phase   opsv1alpha1.AutomationPhase
+	}{
+		"open":       {state: "open", phase: opsv1alpha1.AutomationProposed},
+		"forge down": {state: "open", down: true, phase: opsv1alpha1.AutomationProposed},
+		"merged":     {state: "closed", merged: true, applied: true, phase: opsv1alpha1.AutomationApplied},
+		"closed":     {state: "closed", phase: opsv1alpha1.AutomationFailed},
+	}
+	for name, tt := range tests {
+		pr := &giteaPull{Number: 1, HTMLURL: "https://gitea.example.com/getais/homelab/pulls/1", State: tt.state, Merged: tt.merged}
+		srv := httptest.NewServer(&fakeGitea{pulls: []*giteaPull{pr}, down: tt.down})
+		update, ns := proposedUpdate("https://gitea.example.com/getais/homelab.git", srv.URL)
+		update.Status.Automation = &opsv1alpha1.AutomationStatus{
+			Phase:       opsv1alpha1.AutomationProposed,
+			Source:      "shop",
+			Version:     "v1.3.0",
+			Applied:     "v1.3.0",
+			PullRequest: pr.HTMLURL,
+		}
+		r := &UpdateReconciler{Client: newFakeClient(t, update, ns), Recorder: record.NewFakeRecorder(10)}
+
+		if applied := r.checkProposal(context.Background(), update, time.Now()); applied != tt.applied {
+			t.Errorf("%s: checkProposal() = %v, want %v", name, applied, tt.applied)
+		}
+		if phase := update.Status.Automation.Phase; phase != tt.phase {
+			t.Errorf("%s: phase = %s, want %s", name, phase, tt.phase)
+		}
+		srv.Close()
+	}
+}
@@ -19,29 +19,34 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/libs/registry"
+	"github.com/getais/kupdater/pkg/providers"
 )
 
 // AppVersionReconciler reconciles a AppVersion object
 type DeploymentReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -70,25 +75,22 @@ func (r *DeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		// If Pod has annotations
 		if _, enabled := dep.Annotations["kupdater.ops.getais.cloud/enabled"]; enabled {
 
-			// If Source repo annotation is present
-			if _, source := dep.Annotations["kupdater.ops.getais.cloud/source"]; source {
+			// Create AppVersion to track updates on
+			appversions, err := r.NewAppver(ctx, dep)
+			if err != nil {
+				log.Error(err, "Invalid Deployment")
+				return ctrl.Result{RequeueAfter: 2 * time.Minute}, err
+			}
 
-				// Create AppVersion to track updates on
-				appversions, err := r.NewAppver(dep)
+			for _, appver := range appversions {
+				// Create Appversion{
+				log.Info("Creating a new AppVersion")
+				err = r.Create(ctx, appver)
 				if err != nil {
-					log.Error(err, "Invalid Deployment")
+					log.Error(err, "Failed to create new AppVersion")
 					return ctrl.Result{RequeueAfter: 2 * time.Minute}, err
 				}
-
-				for _, appver := range appversions {
-					// Create Appversion{
-					log.Info("Creating a new AppVersion")
-					err = r.Create(ctx, appver)
-					if err != nil {
-						log.Error(err, "Failed to create new AppVersion")
-						return ctrl.Result{RequeueAfter: 2 * time.Minute}, err
-					}
-				}
+				r.Recorder.Eventf(dep, corev1.EventTypeNormal, ReasonAppVersionCreated, "Created AppVersion %s tracking %s %s", appver.Name, appver.Spec.Type, appver.Spec.Version)
 			}
 
 		}
@@ -111,26 +113,54 @@ func (r *DeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-func (r *DeploymentReconciler) NewAppver(a *appsv1.Deployment) (AppVersions []*opsv1alpha1.AppVersion, err error) {
-
-	Source, success := a.Annotations["kupdater.ops.getais.cloud/source"]
-	if !success {
-		return []*opsv1alpha1.AppVersion{}, fmt.Errorf("Missing AppVersion source")
-	}
+func (r *DeploymentReconciler) NewAppver(ctx context.Context, a *appsv1.Deployment) (AppVersions []*opsv1alpha1.AppVersion, err error) {
 
 	Type, success := a.Annotations["kupdater.ops.getais.cloud/type"]
 	if !success {
 		return []*opsv1alpha1.AppVersion{}, fmt.Errorf("Missing AppVersion type")
 	}
 
+	// Image sources default to the image of the container
+	Source, success := a.Annotations["kupdater.ops.getais.cloud/source"]
+	if !success && !isImageType(Type) {
+		return []*opsv1alpha1.AppVersion{}, fmt.Errorf("Missing AppVersion source")
+	}
+
 	var Version string
 	Version, _ = a.Annotations["kupdater.ops.getais.cloud/version"]
+	Constraint := a.Annotations["kupdater.ops.getais.cloud/constraint"]
+	Channel := a.Annotations["kupdater.ops.getais.cloud/channel"]
+	Ordering := a.Annotations["kupdater.ops.getais.cloud/ordering"]
+	VersionExtract := a.Annotations["kupdater.ops.getais.cloud/version-extract"]
+	APIURL := a.Annotations["kupdater.ops.getais.cloud/api-url"]
+	Mode := a.Annotations["kupdater.ops.getais.cloud/mode"]
+
+	var Filter *opsv1alpha1.TagFilter
+	Include, hasInclude := a.Annotations["kupdater.ops.getais.cloud/tag-include"]
+	Exclude, hasExclude := a.Annotations["kupdater.ops.getais.cloud/tag-exclude"]
+	if hasInclude || hasExclude {
+		Filter = &opsv1alpha1.TagFilter{Include: Include, Exclude: Exclude}
+	}
 
 	for _, Container := range a.Spec.Template.Spec.Containers {
 		AppVer := new(opsv1alpha1.AppVersion)
+		ContainerSource := Source
+		var SecretRef *corev1.LocalObjectReference
 
-		if Type == "github" || Type == "Github" {
-			Version = strings.Split(Container.Image, ":")[1]
+		if isReleaseType(Type) {
+			_, Version = registry.SplitImage(Container.Image)
+		}
+
+		if isImageType(Type) {
+			Repository, Tag := registry.SplitImage(Container.Image)
+			if Source != "" && !sameImage(Source, Repository) {
+				continue
+			}
+			ContainerSource, Version = Repository, Tag
+
+			// Private images are looked up with the pull secret of the Deployment
+			// holding credentials for their registry
+			SecretRef = r.pullSecretFor(ctx, a, Repository)
 		}
 
 		AppVer = &opsv1alpha1.AppVersion{
@@ -139,17 +169,85 @@ func (r *DeploymentReconciler) NewAppver(a *appsv1.Deployment) (AppVersions []*o
 				Namespace: a.Namespace,
 			},
 			Spec: opsv1alpha1.UpdateSource{
-				Name:    a.Name,
-				Type:    Type,
-				Source:  Source,
-				Version: Version,
+				Name:           a.Name,
+				Type:           Type,
+				Source:         ContainerSource,
+				Version:        Version,
+				Constraint:     Constraint,
+				Channel:        Channel,
+				TagFilter:      Filter,
+				VersionExtract: VersionExtract,
+				Ordering:       Ordering,
+				SecretRef:      SecretRef,
+				Mode:           Mode,
+				APIURL:         APIURL,
 			},
 			Status: opsv1alpha1.AppVersionStatus{},
 		}
 		// Set Application instance as the owner and controller
 		ctrl.SetControllerReference(a, AppVer, r.Scheme)
 		AppVersions = append(AppVersions, AppVer)
+
+		// A Deployment tracks a single image, the one of its first matching container
+		if isImageType(Type) {
+			break
+		}
+	}
+
+	if len(AppVersions) == 0 {
+		return AppVersions, fmt.Errorf("No container runs image %s", Source)
 	}
 	return AppVersions, nil
 
 }
+
+// pullSecretFor returns the first image pull secret of the Deployment which
+// holds credentials for the registry of image, or nil when none does.
+func (r *DeploymentReconciler) pullSecretFor(ctx context.Context, a *appsv1.Deployment, image string) *corev1.LocalObjectReference {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return nil
+	}
+	for i, s := range a.Spec.Template.Spec.ImagePullSecrets {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: s.Name, Namespace: a.Namespace}, secret); err != nil {
+			continue
+		}
+		var username string
+		if config, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+			username, _, err = registry.DockerConfigCredentials(config, ref.Registry)
+		} else if config, ok := secret.Data[corev1.DockerConfigKey]; ok {
+			username, _, err = registry.DockerCfgCredentials(config, ref.Registry)
+		}
+		if err == nil && username != "" {
+			return &a.Spec.Template.Spec.ImagePullSecrets[i]
+		}
+	}
+	return nil
+}
+
+// isImageType reports whether a source type tracks container image tags.
+func isImageType(Type string) bool {
+	t := providers.Normalize(Type)
+	return t == "oci" || t == "image"
+}
+
+// isReleaseType reports whether a source type tracks releases or tags of a
+// repository, whose version is the tag of the container image.
+func isReleaseType(Type string) bool {
+	switch providers.Normalize(Type) {
+	case "github", "gitlab", "gitea", "forgejo", "git":
+		return true
+	}
+	return false
+}
+
+// sameImage reports whether two image references point to the same repository.
+func sameImage(a, b string) bool {
+	refA, errA := registry.ParseReference(a)
+	refB, errB := registry.ParseReference(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return refA.Registry == refB.Registry && refA.Repository == refB.Repository
+}
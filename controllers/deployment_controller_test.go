@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewAppverPullSecret(t *testing.T) {
+	secret := func(name, key, data string) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"}, Data: map[string][]byte{key: []byte(data)}}
+	}
+	c := newFakeClient(t,
+		secret("dockerhub", corev1.DockerConfigJsonKey, `{"auths": {"https://index.docker.io/v1/": {"username": "robot", "password": "secret"}}}`),
+		secret("ghcr", corev1.DockerConfigJsonKey, `{"auths": {"ghcr.io": {"username": "bot", "password": "token"}}}`),
+		secret("quay", corev1.DockerConfigKey, `{"quay.io": {"username": "bot", "password": "token"}}`),
+	)
+	r := &DeploymentReconciler{Client: c, Scheme: c.Scheme()}
+
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx:1.23.1", "dockerhub"},
+		{"ghcr.io/getais/shop:v1.2.0", "ghcr"},
+		{"quay.io/getais/shop:v1.2.0", "quay"},
+		{"registry.example.com/shop:v1.2.0", ""},
+	}
+	for _, tt := range tests {
+		dep := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop", Annotations: map[string]string{"kupdater.ops.getais.cloud/type": "image"}},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers:       []corev1.Container{{Name: "shop", Image: tt.image}},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "missing"}, {Name: "dockerhub"}, {Name: "ghcr"}, {Name: "quay"}},
+			}}},
+		}
+		appvers, err := r.NewAppver(context.Background(), dep)
+		if err != nil {
+			t.Fatalf("NewAppver(%s) = %v", tt.image, err)
+		}
+		got := ""
+		if ref := appvers[0].Spec.SecretRef; ref != nil {
+			got = ref.Name
+		}
+		if got != tt.want {
+			t.Errorf("NewAppver(%s) secret = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// Event reasons, shared by all reconcilers.
+const (
+	ReasonUpdateAvailable   = "UpdateAvailable"
+	ReasonCheckFailed       = "CheckFailed"
+	ReasonSourceUnreachable = "SourceUnreachable"
+	ReasonRateLimited       = "RateLimited"
+	ReasonAppVersionCreated = "AppVersionCreated"
+	ReasonUpdateCreated     = "UpdateCreated"
+	ReasonDigestSent        = "DigestSent"
+	ReasonDeliveryFailed    = "DeliveryFailed"
+)
+
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// recordLineageEvent records an event on obj and on the objects it was
+// created from, so the Deployment or Application an Update tracks shows the
+// same events as the Update and its AppVersion.
+func recordLineageEvent(ctx context.Context, c client.Client, recorder record.EventRecorder, obj client.Object, eventtype, reason, message string) {
+	recorder.Event(obj, eventtype, reason, message)
+	for _, owner := range lineage(ctx, c, obj) {
+		recorder.Event(owner, eventtype, reason, message)
+	}
+}
+
+// lineage follows the controller references of obj, Update -> AppVersion ->
+// Deployment or Application. Owners which aren't AppVersions are referenced
+// without being looked up, as only AppVersions have owners kupdater knows
+// about. Applications usually live in the namespace of Argo CD rather than
+// the one of the AppVersion, which records where they are.
+func lineage(ctx context.Context, c client.Client, obj client.Object) []*corev1.ObjectReference {
+	var refs []*corev1.ObjectReference
+	owner := metav1.GetControllerOf(obj)
+	for owner != nil && len(refs) < 3 {
+		namespace := obj.GetNamespace()
+		if appver, ok := obj.(*opsv1alpha1.AppVersion); ok && owner.Kind == "Application" {
+			if app, ok := applicationOf(appver); ok {
+				namespace = app.Namespace
+			}
+		}
+		refs = append(refs, &corev1.ObjectReference{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			Namespace:  namespace,
+			UID:        owner.UID,
+		})
+		if owner.Kind != "AppVersion" || owner.APIVersion != opsv1alpha1.GroupVersion.String() {
+			break
+		}
+
+		appver := &opsv1alpha1.AppVersion{}
+		if err := c.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: obj.GetNamespace()}, appver); err != nil {
+			break
+		}
+		obj, owner = appver, metav1.GetControllerOf(appver)
+	}
+	return refs
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+func TestLineage(t *testing.T) {
+	controller := true
+	ownedBy := func(apiVersion, kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID("uid-" + name), Controller: &controller}}
+	}
+	fromApplication := &opsv1alpha1.AppVersion{ObjectMeta: metav1.ObjectMeta{
+		Name: "traefik", Namespace: "traefik",
+		Annotations:     map[string]string{opsv1alpha1.ApplicationAnnotation: "argocd/traefik"},
+		OwnerReferences: ownedBy("argoproj.io/v1alpha1", "Application", "traefik"),
+	}}
+	fromDeployment := &opsv1alpha1.AppVersion{ObjectMeta: metav1.ObjectMeta{
+		Name: "shop", Namespace: "shop",
+		OwnerReferences: ownedBy("apps/v1", "Deployment", "shop"),
+	}}
+	c := newFakeClient(t, fromApplication, fromDeployment)
+
+	tests := map[string]struct {
+		update *opsv1alpha1.Update
+		want   []string
+	}{
+		"application": {
+			update: &opsv1alpha1.Update{ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik", OwnerReferences: ownedBy(opsv1alpha1.GroupVersion.String(), "AppVersion", "traefik")}},
+			want:   []string{"AppVersion traefik/traefik", "Application argocd/traefik"},
+		},
+		"deployment": {
+			update: &opsv1alpha1.Update{ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop", OwnerReferences: ownedBy(opsv1alpha1.GroupVersion.String(), "AppVersion", "shop")}},
+			want:   []string{"AppVersion shop/shop", "Deployment shop/shop"},
+		},
+		"standalone": {
+			update: &opsv1alpha1.Update{ObjectMeta: metav1.ObjectMeta{Name: "velero", Namespace: "velero"}},
+		},
+	}
+	for name, tt := range tests {
+		refs := lineage(context.Background(), c, tt.update)
+		var got []string
+		for _, ref := range refs {
+			got = append(got, ref.Kind+" "+ref.Namespace+"/"+ref.Name)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: lineage() = %v, want %v", name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: lineage() = %v, want %v", name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// MaintenanceWindowReconciler reports whether MaintenanceWindows are open.
+type MaintenanceWindowReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=maintenancewindows,verbs=get;list;watch
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=maintenancewindows/status,verbs=get;update;patch
+
+// Reconcile updates the status of a window and requeues it for its next
+// opening or closing.
+func (r *MaintenanceWindowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var log = ctrllog.Log.WithName("maintenancewindow.ops.getais.Reconcile").WithValues("namespace", req.Namespace, "name", req.Name)
+
+	window := &opsv1alpha1.MaintenanceWindow{}
+	err := r.Get(ctx, req.NamespacedName, window)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get MaintenanceWindow.")
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	schedule, err := parseMaintenanceWindow(window)
+	if err != nil {
+		meta.SetStatusCondition(&window.Status.Conditions, metav1.Condition{Type: "Ready", Status: metav1.ConditionFalse, Reason: "InvalidSchedule", Message: err.Error()})
+		window.Status.Open = false
+		window.Status.OpenTime, window.Status.CloseTime = nil, nil
+		return ctrl.Result{}, r.Status().Update(ctx, window)
+	}
+	meta.SetStatusCondition(&window.Status.Conditions, metav1.Condition{Type: "Ready", Status: metav1.ConditionTrue, Reason: "ValidSchedule"})
+
+	opens, closes, open := schedule.window(now)
+	window.Status.Open = open
+	window.Status.OpenTime = &metav1.Time{Time: opens}
+	window.Status.CloseTime = &metav1.Time{Time: closes}
+	if err := r.Status().Update(ctx, window); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{RequeueAfter: retryPeriod}, nil
+	}
+
+	next := opens
+	if open {
+		next = closes
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MaintenanceWindowReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&opsv1alpha1.MaintenanceWindow{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
+
+// maintenanceSchedule is the parsed schedule of a MaintenanceWindow.
+type maintenanceSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+	duration time.Duration
+}
+
+func parseMaintenanceWindow(w *opsv1alpha1.MaintenanceWindow) (*maintenanceSchedule, error) {
+	schedule, err := cron.ParseStandard(w.Spec.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", w.Spec.Schedule, err)
+	}
+	if w.Spec.Duration.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+	location := time.UTC
+	if w.Spec.TimeZone != "" {
+		if location, err = time.LoadLocation(w.Spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", w.Spec.TimeZone, err)
+		}
+	}
+	return &maintenanceSchedule{schedule: schedule, location: location, duration: w.Spec.Duration.Duration}, nil
+}
+
+// window returns when the window open at t opened and closes, or when the
+// next window opens and closes if none is open.
+func (s *maintenanceSchedule) window(t time.Time) (opens, closes time.Time, open bool) {
+	// The first opening within the last duration is a window still open
+	opens = s.schedule.Next(t.Add(-s.duration).In(s.location))
+	if opens.After(t) {
+		return opens, opens.Add(s.duration), false
+	}
+	return opens, opens.Add(s.duration), true
+}
+
+// maintenanceWindow reports whether the MaintenanceWindows applying to an
+// Update allow automated updates at t, and if not when the next window
+// opens. Updates no window applies to are never restricted, and neither
+// are Updates annotated to ignore maintenance windows. Windows outside the
+// admin namespace only apply to their own.
+func maintenanceWindow(ctx context.Context, c client.Client, update *opsv1alpha1.Update, admin string, t time.Time) (bool, time.Time, error) {
+	if update.Annotations[opsv1alpha1.MaintenanceOverrideAnnotation] == "true" {
+		return true, time.Time{}, nil
+	}
+
+	windows := &opsv1alpha1.MaintenanceWindowList{}
+	if err := c.List(ctx, windows); err != nil {
+		return false, time.Time{}, err
+	}
+
+	restricted := false
+	var next time.Time
+	for i := range windows.Items {
+		w := &windows.Items[i]
+		if update.Namespace != w.Namespace && !crossNamespace(w.Namespace, admin) {
+			continue
+		}
+		applies, err := w.Applies(update)
+		if err != nil || !applies {
+			continue
+		}
+		schedule, err := parseMaintenanceWindow(w)
+		if err != nil {
+			// Reported in the status of the window
+			continue
+		}
+		restricted = true
+		opens, _, open := schedule.window(t)
+		if open {
+			return true, time.Time{}, nil
+		}
+		if next.IsZero() || opens.Before(next) {
+			next = opens
+		}
+	}
+	return !restricted, next, nil
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// weekendWindow returns a window opening Saturdays at 02:00 for 4 hours.
+func weekendWindow(namespace, timeZone string, namespaces ...string) *opsv1alpha1.MaintenanceWindow {
+	return &opsv1alpha1.MaintenanceWindow{
+		ObjectMeta: metav1.ObjectMeta{Name: "weekend", Namespace: namespace},
+		Spec: opsv1alpha1.MaintenanceWindowSpec{
+			Schedule:   "0 2 * * 6",
+			Duration:   metav1.Duration{Duration: 4 * time.Hour},
+			TimeZone:   timeZone,
+			Namespaces: namespaces,
+		},
+	}
+}
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	tests := map[string]struct {
+		schedule string
+		duration time.Duration
+		timeZone string
+		valid    bool
+	}{
+		"utc":              {schedule: "0 2 * * 6", duration: time.Hour, valid: true},
+		"time zone":        {schedule: "0 2 * * 6", duration: time.Hour, timeZone: "Europe/Berlin", valid: true},
+		"descriptor":       {schedule: "@daily", duration: time.Hour, valid: true},
+		"invalid schedule": {schedule: "0 2 * *", duration: time.Hour},
+		"zero duration":    {schedule: "0 2 * * 6"},
+		"invalid zone":     {schedule: "0 2 * * 6", duration: time.Hour, timeZone: "Europe/Atlantis"},
+	}
+	for name, tt := range tests {
+		w := &opsv1alpha1.MaintenanceWindow{Spec: opsv1alpha1.MaintenanceWindowSpec{
+			Schedule: tt.schedule,
+			Duration: metav1.Duration{Duration: tt.duration},
+			TimeZone: tt.timeZone,
+		}}
+		if _, err := parseMaintenanceWindow(w); (err == nil) != tt.valid {
+			t.Errorf("%s: parseMaintenanceWindow() = %v, want valid %v", name, err, tt.valid)
+		}
+	}
+}
+
+func TestMaintenanceScheduleWindow(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	// Saturday October 22nd 2022
+	saturday := func(hour, min int, loc *time.Location) time.Time {
+		return time.Date(2022, 10, 22, hour, min, 0, 0, loc)
+	}
+	tests := map[string]struct {
+		timeZone string
+		at       time.Time
+		opens    time.Time
+		open     bool
+	}{
+		"before":           {at: saturday(1, 59, time.UTC), opens: saturday(2, 0, time.UTC)},
+		"opening":          {at: saturday(2, 0, time.UTC), opens: saturday(2, 0, time.UTC), open: true},
+		"open":             {at: saturday(4, 30, time.UTC), opens: saturday(2, 0, time.UTC), open: true},
+		"closing":          {at: saturday(6, 0, time.UTC), opens: saturday(2, 0, time.UTC).AddDate(0, 0, 7)},
+		"closed":           {at: saturday(12, 0, time.UTC), opens: saturday(2, 0, time.UTC).AddDate(0, 0, 7)},
+		"zone open":        {timeZone: "Europe/Berlin", at: saturday(1, 0, time.UTC), opens: saturday(2, 0, berlin), open: true},
+		"zone not open":    {timeZone: "Europe/Berlin", at: saturday(4, 30, time.UTC), opens: saturday(2, 0, berlin).AddDate(0, 0, 7)},
+		"zone local clock": {timeZone: "Europe/Berlin", at: saturday(3, 0, berlin), opens: saturday(2, 0, berlin), open: true},
+	}
+	for name, tt := range tests {
+		schedule, err := parseMaintenanceWindow(weekendWindow("shop", tt.timeZone))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opens, closes, open := schedule.window(tt.at)
+		if !opens.Equal(tt.opens) || !closes.Equal(tt.opens.Add(4*time.Hour)) || open != tt.open {
+			t.Errorf("%s: window() = %s, %s, %v, want %s, %s, %v", name, opens, closes, open, tt.opens, tt.opens.Add(4*time.Hour), tt.open)
+		}
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	open := time.Date(2022, 10, 22, 3, 0, 0, 0, time.UTC)
+	closed := time.Date(2022, 10, 20, 8, 0, 0, 0, time.UTC)
+	next := time.Date(2022, 10, 22, 2, 0, 0, 0, time.UTC)
+	override := map[string]string{opsv1alpha1.MaintenanceOverrideAnnotation: "true"}
+
+	tests := map[string]struct {
+		windows     []*opsv1alpha1.MaintenanceWindow
+		annotations map[string]string
+		admin       string
+		at          time.Time
+		allowed     bool
+		next        time.Time
+	}{
+		"no window":              {at: closed, allowed: true},
+		"open":                   {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("shop", "")}, at: open, allowed: true},
+		"closed":                 {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("shop", "")}, at: closed, next: next},
+		"override":               {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("shop", "")}, annotations: override, at: closed, allowed: true},
+		"other namespace":        {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("billing", "")}, at: closed, allowed: true},
+		"tenant selecting all":   {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("billing", "", "*")}, admin: "kupdater", at: closed, allowed: true},
+		"tenant selecting shop":  {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("billing", "", "shop")}, at: closed, allowed: true},
+		"admin selecting all":    {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("kupdater", "", "*")}, admin: "kupdater", at: closed, next: next},
+		"admin selecting shop":   {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("kupdater", "", "shop")}, admin: "kupdater", at: closed, next: next},
+		"admin selecting others": {windows: []*opsv1alpha1.MaintenanceWindow{weekendWindow("kupdater", "", "billing")}, admin: "kupdater", at: closed, allowed: true},
+		"any window open": {windows: []*opsv1alpha1.MaintenanceWindow{
+			weekendWindow("shop", ""),
+			{ObjectMeta: metav1.ObjectMeta{Name: "thursday", Namespace: "shop"}, Spec: opsv1alpha1.MaintenanceWindowSpec{Schedule: "0 7 * * 4", Duration: metav1.Duration{Duration: 2 * time.Hour}}},
+		}, at: closed, allowed: true},
+		"invalid window": {windows: []*opsv1alpha1.MaintenanceWindow{
+			{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "shop"}, Spec: opsv1alpha1.MaintenanceWindowSpec{Schedule: "never"}},
+		}, at: closed, allowed: true},
+	}
+	for name, tt := range tests {
+		update := &opsv1alpha1.Update{ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop", Annotations: tt.annotations}}
+		c := newFakeClient(t, update)
+		for _, w := range tt.windows {
+			if err := c.Create(context.Background(), w); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		allowed, at, err := maintenanceWindow(context.Background(), c, update, tt.admin, tt.at)
+		if err != nil {
+			t.Fatalf("%s: maintenanceWindow() = %v", name, err)
+		}
+		if allowed != tt.allowed || !at.Equal(tt.next) {
+			t.Errorf("%s: maintenanceWindow() = %v, %s, want %v, %s", name, allowed, at, tt.allowed, tt.next)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/providers"
+)
+
+var (
+	updateAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kupdater_update_available",
+		Help: "Whether a newer version allowed by policy is available for a source (1) or not (0).",
+	}, []string{"namespace", "name", "source", "type", "current", "latest"})
+
+	versionsBehind = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kupdater_versions_behind",
+		Help: "Number of published versions allowed by policy which are newer than the installed one.",
+	}, []string{"namespace", "name", "source", "type"})
+
+	releaseAge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kupdater_release_age_seconds",
+		Help: "Seconds since the newest version allowed by policy was published.",
+	}, []string{"namespace", "name", "source", "type"})
+
+	providerRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kupdater_provider_requests_total",
+		Help: "Total number of version lookups made through providers.",
+	}, []string{"type", "operation"})
+
+	providerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kupdater_provider_errors_total",
+		Help: "Total number of version lookups made through providers which failed.",
+	}, []string{"type", "operation"})
+
+	providerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kupdater_provider_request_duration_seconds",
+		Help:    "Duration of version lookups made through providers.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"type", "operation"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(updateAvailable, versionsBehind, releaseAge, providerRequests, providerErrors, providerDuration)
+}
+
+// sourceSeries holds the label sets exported for a source, so they can be
+// removed once the source or its versions change.
+type sourceSeries struct {
+	available prometheus.Labels
+	source    prometheus.Labels
+}
+
+// delete removes every series exported for the source.
+func (s sourceSeries) delete() {
+	updateAvailable.Delete(s.available)
+	versionsBehind.Delete(s.source)
+	releaseAge.Delete(s.source)
+}
+
+// updateMetrics tracks the series exported for each Update. The available
+// gauge carries the versions as labels, so the previous series have to be
+// deleted when they change rather than left behind with a stale value.
+type updateMetrics struct {
+	mu     sync.Mutex
+	series map[types.NamespacedName]map[string]sourceSeries
+}
+
+var sourceMetrics = &updateMetrics{series: map[types.NamespacedName]map[string]sourceSeries{}}
+
+// export exports the status of every source of the Update which was
+// checked successfully at least once, and deletes the series of sources no
+// longer part of it. It is called on every reconcile so the series are
+// restored from the status after a restart.
+func (m *updateMetrics) export(update *opsv1alpha1.Update, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, status := range update.Status.Sources {
+		if status.CurrentVersion == "" && status.LatestInPolicy == "" {
+			continue
+		}
+		m.observe(update, status, now)
+	}
+	m.prune(update)
+}
+
+// observe exports the versions of a source.
+func (m *updateMetrics) observe(update *opsv1alpha1.Update, status opsv1alpha1.SourceStatus, now time.Time) {
+	key := types.NamespacedName{Namespace: update.Namespace, Name: update.Name}
+	if m.series[key] == nil {
+		m.series[key] = map[string]sourceSeries{}
+	}
+	if previous, ok := m.series[key][status.Name]; ok {
+		previous.delete()
+	}
+
+	s := sourceSeries{
+		source: prometheus.Labels{"namespace": update.Namespace, "name": update.Name, "source": status.Name, "type": providers.Normalize(status.Type)},
+	}
+	s.available = prometheus.Labels{"current": status.CurrentVersion, "latest": status.LatestInPolicy}
+	for k, v := range s.source {
+		s.available[k] = v
+	}
+
+	available := 0.0
+	if status.Outdated {
+		available = 1
+	}
+	updateAvailable.With(s.available).Set(available)
+	versionsBehind.With(s.source).Set(float64(status.Behind))
+	if status.ReleaseDate != nil {
+		releaseAge.With(s.source).Set(now.Sub(status.ReleaseDate.Time).Seconds())
+	}
+	m.series[key][status.Name] = s
+}
+
+// prune deletes the series of sources which are no longer part of the Update.
+func (m *updateMetrics) prune(update *opsv1alpha1.Update) {
+	key := types.NamespacedName{Namespace: update.Namespace, Name: update.Name}
+	for name, s := range m.series[key] {
+		if update.Status.Source(name) == nil {
+			s.delete()
+			delete(m.series[key], name)
+		}
+	}
+}
+
+// forget deletes every series of a deleted Update.
+func (m *updateMetrics) forget(key types.NamespacedName) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.series[key] {
+		s.delete()
+	}
+	delete(m.series, key)
+}
+
+// instrumentedProvider records the number, errors and duration of calls
+// made to a provider.
+type instrumentedProvider struct {
+	providers.Provider
+	typ string
+}
+
+func instrument(typ string, p providers.Provider) providers.Provider {
+	return instrumentedProvider{Provider: p, typ: providers.Normalize(typ)}
+}
+
+func (p instrumentedProvider) ListVersions(ctx context.Context, src providers.Source) ([]providers.Release, error) {
+	defer p.observe("list", time.Now())
+	releases, err := p.Provider.ListVersions(ctx, src)
+	if err != nil && !providers.IsTruncated(err) {
+		providerErrors.WithLabelValues(p.typ, "list").Inc()
+	}
+	return releases, err
+}
+
+func (p instrumentedProvider) Latest(ctx context.Context, src providers.Source) (providers.Release, error) {
+	defer p.observe("latest", time.Now())
+	release, err := p.Provider.Latest(ctx, src)
+	if err != nil && !providers.IsTruncated(err) {
+		providerErrors.WithLabelValues(p.typ, "latest").Inc()
+	}
+	return release, err
+}
+
+func (p instrumentedProvider) observe(operation string, start time.Time) {
+	providerRequests.WithLabelValues(p.typ, operation).Inc()
+	providerDuration.WithLabelValues(p.typ, operation).Observe(time.Since(start).Seconds())
+}
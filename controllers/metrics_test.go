@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+func TestUpdateMetricsExport(t *testing.T) {
+	now := time.Date(2022, 10, 20, 8, 0, 0, 0, time.UTC)
+	update := &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "metrics"},
+		Status: opsv1alpha1.UpdateStatus{Sources: []opsv1alpha1.SourceStatus{
+			{Name: "chart", Type: "helm", CurrentVersion: "17.0.5", LatestInPolicy: "17.1.0", Outdated: true, Behind: 2, ReleaseDate: &metav1.Time{Time: now.Add(-time.Hour)}},
+			{Name: "image", Type: "image", CurrentVersion: "2.9.1", LatestInPolicy: "2.9.1"},
+			{Name: "binary", Type: "github", Error: "not found"},
+		}},
+	}
+	m := &updateMetrics{series: map[types.NamespacedName]map[string]sourceSeries{}}
+	chart := prometheus.Labels{"namespace": "metrics", "name": "traefik", "source": "chart", "type": "helm"}
+	available := prometheus.Labels{"namespace": "metrics", "name": "traefik", "source": "chart", "type": "helm", "current": "17.0.5", "latest": "17.1.0"}
+
+	// A restarted controller exports the status without checking
+	m.export(update, now)
+	if got := testutil.ToFloat64(updateAvailable.With(available)); got != 1 {
+		t.Errorf("kupdater_update_available = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(versionsBehind.With(chart)); got != 2 {
+		t.Errorf("kupdater_versions_behind = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(releaseAge.With(chart)); got != time.Hour.Seconds() {
+		t.Errorf("kupdater_release_age_seconds = %v, want %v", got, time.Hour.Seconds())
+	}
+	key := types.NamespacedName{Namespace: "metrics", Name: "traefik"}
+	if _, ok := m.series[key]["binary"]; ok {
+		t.Errorf("export() exported a source which was never checked")
+	}
+
+	// New versions replace the series of the previous ones
+	update.Status.Sources[0].CurrentVersion, update.Status.Sources[0].Outdated, update.Status.Sources[0].Behind = "17.1.0", false, 0
+	update.Status.Sources = update.Status.Sources[:1]
+	m.export(update, now)
+	if updateAvailable.Delete(available) {
+		t.Errorf("export() kept the series of the previous versions")
+	}
+	if _, ok := m.series[key]["image"]; ok {
+		t.Errorf("export() kept the series of a removed source")
+	}
+
+	m.forget(key)
+	if versionsBehind.Delete(chart) {
+		t.Errorf("forget() kept the series of a deleted Update")
+	}
+}
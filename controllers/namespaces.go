@@ -0,0 +1,8 @@
+package controllers
+
+// crossNamespace reports whether objects in namespace may select objects of
+// other namespaces. Only the admin namespace of the controller may, so
+// tenants can't announce, block or promote the Updates of each other.
+func crossNamespace(namespace, admin string) bool {
+	return admin != "" && namespace == admin
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/notify"
+)
+
+// NotificationChannelReconciler sends the digests of NotificationChannels.
+type NotificationChannelReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// AdminNamespace is the namespace whose channels may watch other
+	// namespaces than their own.
+	AdminNamespace string
+}
+
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=notificationchannels,verbs=get;list;watch
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=notificationchannels/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=updates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=updates/status,verbs=get;update;patch
+
+// Reconcile sends the digest of a channel when it is due, and requeues the
+// channel for its next digest.
+func (r *NotificationChannelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var log = ctrllog.Log.WithName("notificationchannel.ops.getais.Reconcile").WithValues("namespace", req.Namespace, "name", req.Name)
+
+	channel := &opsv1alpha1.NotificationChannel{}
+	err := r.Get(ctx, req.NamespacedName, channel)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get NotificationChannel.")
+		return ctrl.Result{}, err
+	}
+	if channel.Spec.Digest == nil {
+		return ctrl.Result{}, nil
+	}
+
+	schedule, err := digestSchedule(channel.Spec.Digest)
+	if err != nil {
+		meta.SetStatusCondition(&channel.Status.Conditions, metav1.Condition{Type: "Delivered", Status: metav1.ConditionFalse, Reason: "InvalidSchedule", Message: err.Error()})
+		channel.Status.NextDigestTime = nil
+		return ctrl.Result{}, r.Status().Update(ctx, channel)
+	}
+
+	now := time.Now()
+	last := channel.CreationTimestamp.Time
+	if channel.Status.LastDigestTime != nil {
+		last = channel.Status.LastDigestTime.Time
+	}
+	if next := schedule(last); next.After(now) {
+		if channel.Status.NextDigestTime == nil || !channel.Status.NextDigestTime.Time.Equal(next) {
+			channel.Status.NextDigestTime = &metav1.Time{Time: next}
+			if err := r.Status().Update(ctx, channel); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
+
+	log.Info("Sending digest")
+	sent, err := r.sendDigest(ctx, channel, now)
+	setDelivered(ctx, r.Client, channel, err)
+	if err != nil {
+		log.Error(err, "Failed to send digest")
+		r.Recorder.Eventf(channel, corev1.EventTypeWarning, ReasonDeliveryFailed, "Sending digest failed: %s", err)
+		return ctrl.Result{RequeueAfter: retryPeriod}, nil
+	}
+	if sent > 0 {
+		r.Recorder.Eventf(channel, corev1.EventTypeNormal, ReasonDigestSent, "Announced %d updates", sent)
+	}
+
+	next := schedule(now)
+	channel.Status.LastDigestTime = &metav1.Time{Time: now}
+	channel.Status.NextDigestTime = &metav1.Time{Time: next}
+	if err := r.Status().Update(ctx, channel); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{RequeueAfter: retryPeriod}, nil
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+// sendDigest announces every Update watched by the channel whose versions
+// haven't been announced on it yet in a single message, returning how many
+// were announced.
+func (r *NotificationChannelReconciler) sendDigest(ctx context.Context, channel *opsv1alpha1.NotificationChannel, now time.Time) (int, error) {
+	updates := &opsv1alpha1.UpdateList{}
+	if err := r.List(ctx, updates); err != nil {
+		return 0, err
+	}
+
+	var pending []*opsv1alpha1.Update
+	var announced [][]string
+	digest := notify.Digest{}
+	for i := range updates.Items {
+		update := &updates.Items[i]
+		if !channelWatches(channel, update.Namespace, r.AdminNamespace) || update.Status.Phase != opsv1alpha1.UpdatePhaseOutdated {
+			continue
+		}
+		if !pendingAnnouncement(update, channel.Key()) {
+			continue
+		}
+		pending = append(pending, update)
+		announced = append(announced, outdatedVersions(update))
+		digest.Updates = append(digest.Updates, notificationEvent(update))
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	err := sendNotification(ctx, r.Client, channel, func(sink notify.Sink) error {
+		return sink.SendDigest(ctx, digest)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, update := range pending {
+		if err := r.recordDigest(ctx, update, channel.Key(), announced[i], now); err != nil {
+			ctrllog.FromContext(ctx).Error(err, "Failed to record announcement", "update", update.Namespace+"/"+update.Name)
+		}
+	}
+	return len(pending), nil
+}
+
+// recordDigest records the versions of an Update sent in a digest as
+// announced, retrying when the Update was changed meanwhile. Versions found
+// since are announced in the next digest.
+func (r *NotificationChannelReconciler) recordDigest(ctx context.Context, update *opsv1alpha1.Update, channel string, versions []string, now time.Time) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(update), update); err != nil {
+			return err
+		}
+		recordAnnouncement(update, channel, versions, now)
+		return r.Status().Update(ctx, update)
+	})
+}
+
+// digestSchedule returns when a digest is due after a given time.
+func digestSchedule(digest *opsv1alpha1.NotificationDigest) (func(time.Time) time.Time, error) {
+	schedule, err := cron.ParseStandard(digest.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest schedule %q: %w", digest.Schedule, err)
+	}
+	location := time.UTC
+	if digest.TimeZone != "" {
+		if location, err = time.LoadLocation(digest.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid digest time zone %q: %w", digest.TimeZone, err)
+		}
+	}
+	return func(t time.Time) time.Time {
+		return schedule.Next(t.In(location))
+	}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NotificationChannelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&opsv1alpha1.NotificationChannel{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/notify"
+)
+
+// deliveryBackoff bounds how often a notification is sent again when the
+// sink is unreachable or unavailable.
+var deliveryBackoff = wait.Backoff{Steps: 3, Duration: 2 * time.Second, Factor: 2, Jitter: 0.1}
+
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=notificationchannels,verbs=get;list;watch
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=notificationchannels/status,verbs=get;update;patch
+
+// notifyChannels announces an Update on every NotificationChannel watching
+// its namespace, when it became outdated and when it is up to date again.
+// What was announced is recorded in the Update status, so versions are only
+// announced once per channel. Channels with a digest announce outdated
+// Updates on their own schedule, see NotificationChannelReconciler.
+//
+// It is called once the checked versions are persisted, and returns the
+// changes made to the status so they can be applied again should the Update
+// change before they are persisted, see recordAnnouncements.
+func (r *UpdateReconciler) notifyChannels(ctx context.Context, update *opsv1alpha1.Update, now time.Time) []func(*opsv1alpha1.Update) {
+	log := ctrllog.FromContext(ctx)
+
+	// Failed checks are neither announced nor change what was announced
+	condition := meta.FindStatusCondition(update.Status.Conditions, "UpToDate")
+	if condition == nil || condition.Status == metav1.ConditionUnknown {
+		return nil
+	}
+
+	channels := &opsv1alpha1.NotificationChannelList{}
+	if err := r.List(ctx, channels); err != nil {
+		log.Error(err, "Failed to list notification channels")
+		return nil
+	}
+
+	var changes []func(*opsv1alpha1.Update)
+	apply := func(change func(*opsv1alpha1.Update)) {
+		change(update)
+		changes = append(changes, change)
+	}
+
+	watching := map[string]bool{}
+	versions := outdatedVersions(update)
+	for i := range channels.Items {
+		channel := &channels.Items[i]
+		if !channelWatches(channel, update.Namespace, r.AdminNamespace) {
+			continue
+		}
+		key := channel.Key()
+		watching[key] = true
+
+		if !pendingAnnouncement(update, key) {
+			continue
+		}
+		if len(versions) > 0 && channel.Spec.Digest != nil {
+			continue
+		}
+		// Only Updates announced as outdated are announced as up to date
+		if len(versions) == 0 && channel.Spec.Digest != nil {
+			apply(func(u *opsv1alpha1.Update) { u.Status.RemoveAnnouncement(key) })
+			continue
+		}
+
+		err := sendNotification(ctx, r.Client, channel, func(sink notify.Sink) error {
+			return sink.Send(ctx, notificationEvent(update))
+		})
+		setDelivered(ctx, r.Client, channel, err)
+		if err != nil {
+			log.Error(err, "Failed to send notification", "channel", channel.Key())
+			r.Recorder.Eventf(channel, corev1.EventTypeWarning, ReasonDeliveryFailed, "Announcing %s/%s failed: %s", update.Namespace, update.Name, err)
+			continue
+		}
+		apply(func(u *opsv1alpha1.Update) { recordAnnouncement(u, key, versions, now) })
+	}
+
+	for _, a := range append([]opsv1alpha1.Announcement(nil), update.Status.Announcements...) {
+		if !watching[a.Channel] {
+			key := a.Channel
+			apply(func(u *opsv1alpha1.Update) { u.Status.RemoveAnnouncement(key) })
+		}
+	}
+	return changes
+}
+
+// recordAnnouncements persists the changes notifyChannels made to the
+// status, applying them again when the Update was changed meanwhile.
+func (r *UpdateReconciler) recordAnnouncements(ctx context.Context, update *opsv1alpha1.Update, changes []func(*opsv1alpha1.Update)) error {
+	fetch := false
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if fetch {
+			if err := r.Get(ctx, client.ObjectKeyFromObject(update), update); err != nil {
+				return err
+			}
+			for _, change := range changes {
+				change(update)
+			}
+		}
+		fetch = true
+		return r.Status().Update(ctx, update)
+	})
+}
+
+// channelWatches reports whether Updates in namespace are announced on the
+// channel. Channels outside the admin namespace only watch their own.
+func channelWatches(channel *opsv1alpha1.NotificationChannel, namespace, admin string) bool {
+	if namespace != channel.Namespace && !crossNamespace(channel.Namespace, admin) {
+		return false
+	}
+	return channel.Watches(namespace)
+}
+
+// outdatedVersions lists the versions an Update can be updated to, as
+// sorted source=version entries.
+func outdatedVersions(update *opsv1alpha1.Update) []string {
+	var versions []string
+	for _, s := range update.Status.Sources {
+		if s.Outdated {
+			versions = append(versions, s.Name+"="+s.LatestInPolicy)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// pendingAnnouncement reports whether the versions of an Update differ from
+// the ones announced on the channel.
+func pendingAnnouncement(update *opsv1alpha1.Update, channel string) bool {
+	var announced []string
+	if a := update.Status.Announcement(channel); a != nil {
+		announced = a.Versions
+	}
+	versions := outdatedVersions(update)
+	if len(versions) != len(announced) {
+		return true
+	}
+	for i := range versions {
+		if versions[i] != announced[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAnnouncement records the versions of an Update announced on the
+// channel. Nothing is kept for Updates announced as up to date.
+func recordAnnouncement(update *opsv1alpha1.Update, channel string, versions []string, now time.Time) {
+	if len(versions) == 0 {
+		update.Status.RemoveAnnouncement(channel)
+		return
+	}
+	update.Status.SetAnnouncement(opsv1alpha1.Announcement{Channel: channel, Versions: versions, Time: &metav1.Time{Time: now}})
+}
+
+// sendNotification builds the sink of a channel and delivers through it.
+func sendNotification(ctx context.Context, c client.Client, channel *opsv1alpha1.NotificationChannel, send func(notify.Sink) error) error {
+	cfg := notify.Config{
+		Type:     channel.Spec.Type,
+		URL:      channel.Spec.URL,
+		Template: channel.Spec.Template,
+	}
+	if channel.Spec.Digest != nil {
+		cfg.DigestTemplate = channel.Spec.Digest.Template
+	}
+	if channel.Spec.URLSecretRef != nil {
+		url, err := secretValue(ctx, c, channel.Namespace, channel.Spec.URLSecretRef)
+		if err != nil {
+			return err
+		}
+		cfg.URL = string(url)
+	}
+	if channel.Spec.SigningSecretRef != nil {
+		key, err := secretValue(ctx, c, channel.Namespace, channel.Spec.SigningSecretRef)
+		if err != nil {
+			return err
+		}
+		cfg.SigningKey = key
+	}
+
+	sink, err := notify.NewSink(cfg)
+	if err != nil {
+		return err
+	}
+	return retry.OnError(deliveryBackoff, notify.Retriable, func() error {
+		return send(sink)
+	})
+}
+
+// setDelivered records on the channel whether the last notification was
+// delivered.
+func setDelivered(ctx context.Context, c client.Client, channel *opsv1alpha1.NotificationChannel, err error) {
+	condition := metav1.Condition{Type: "Delivered", Status: metav1.ConditionTrue, Reason: "Delivered", Message: "Last notification was delivered"}
+	if err != nil {
+		condition = metav1.Condition{Type: "Delivered", Status: metav1.ConditionFalse, Reason: "DeliveryFailed", Message: err.Error()}
+	}
+	if !meta.IsStatusConditionPresentAndEqual(channel.Status.Conditions, condition.Type, condition.Status) {
+		meta.SetStatusCondition(&channel.Status.Conditions, condition)
+		if err := c.Status().Update(ctx, channel); err != nil {
+			ctrllog.FromContext(ctx).Error(err, "Failed to update notification channel status")
+		}
+	}
+}
+
+// secretValue returns the value of a Secret key.
+func secretValue(ctx context.Context, c client.Client, namespace string, ref *corev1.SecretKeySelector) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", ref.Name, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s has no key %q", ref.Name, ref.Key)
+	}
+	return value, nil
+}
+
+// notificationEvent describes an Update for notification sinks.
+func notificationEvent(update *opsv1alpha1.Update) notify.Event {
+	ev := notify.Event{
+		Namespace: update.Namespace,
+		Name:      update.Name,
+		Outdated:  update.Status.Phase == opsv1alpha1.UpdatePhaseOutdated,
+	}
+	if c := meta.FindStatusCondition(update.Status.Conditions, "UpToDate"); c != nil {
+		ev.Message = c.Message
+	}
+	for _, s := range update.Status.Sources {
+		source := notify.Source{
+			Name:           s.Name,
+			Type:           s.Type,
+			Current:        s.CurrentVersion,
+			Latest:         s.LatestVersion,
+			LatestInPolicy: s.LatestInPolicy,
+			Outdated:       s.Outdated,
+		}
+		if s.ReleaseDate != nil {
+			released := s.ReleaseDate.Time
+			source.ReleaseDate = &released
+		}
+		ev.Sources = append(ev.Sources, source)
+	}
+	return ev
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+func TestChannelWatches(t *testing.T) {
+	channel := func(namespace string, namespaces ...string) *opsv1alpha1.NotificationChannel {
+		return &opsv1alpha1.NotificationChannel{
+			ObjectMeta: metav1.ObjectMeta{Name: "platform", Namespace: namespace},
+			Spec:       opsv1alpha1.NotificationChannelSpec{Namespaces: namespaces},
+		}
+	}
+	tests := map[string]struct {
+		channel   *opsv1alpha1.NotificationChannel
+		namespace string
+		admin     string
+		want      bool
+	}{
+		"own namespace":                {channel: channel("shop"), namespace: "shop", want: true},
+		"other namespace":              {channel: channel("shop"), namespace: "billing"},
+		"tenant wildcard":              {channel: channel("shop", "*"), namespace: "billing", admin: "kupdater"},
+		"tenant wildcard own":          {channel: channel("shop", "*"), namespace: "shop", admin: "kupdater", want: true},
+		"tenant listing other":         {channel: channel("shop", "billing"), namespace: "billing", admin: "kupdater"},
+		"admin wildcard":               {channel: channel("kupdater", "*"), namespace: "billing", admin: "kupdater", want: true},
+		"admin listing":                {channel: channel("kupdater", "billing"), namespace: "billing", admin: "kupdater", want: true},
+		"admin not listing":            {channel: channel("kupdater", "billing"), namespace: "shop", admin: "kupdater"},
+		"wildcard without admin":       {channel: channel("kupdater", "*"), namespace: "billing"},
+		"admin without own namespaces": {channel: channel("kupdater"), namespace: "billing", admin: "kupdater"},
+	}
+	for name, tt := range tests {
+		if got := channelWatches(tt.channel, tt.namespace, tt.admin); got != tt.want {
+			t.Errorf("%s: channelWatches() = %v, want %v", name, got, tt.want)
+		}
+	}
+}
+
+func TestNotifyChannelsRetriesDelivery(t *testing.T) {
+	defer func(backoff wait.Backoff) { deliveryBackoff = backoff }(deliveryBackoff)
+	deliveryBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond}
+
+	var calls int32
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer sink.Close()
+
+	update := &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik"},
+		Status: opsv1alpha1.UpdateStatus{
+			Phase:   opsv1alpha1.UpdatePhaseOutdated,
+			Sources: []opsv1alpha1.SourceStatus{{Name: "chart", CurrentVersion: "17.0.5", LatestInPolicy: "17.1.0", Outdated: true}},
+		},
+	}
+	meta.SetStatusCondition(&update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionFalse, Reason: "UpdatesAvailable"})
+	own := &opsv1alpha1.NotificationChannel{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik"},
+		Spec:       opsv1alpha1.NotificationChannelSpec{Type: "webhook", URL: sink.URL},
+	}
+	tenant := &opsv1alpha1.NotificationChannel{
+		ObjectMeta: metav1.ObjectMeta{Name: "snoop", Namespace: "shop"},
+		Spec:       opsv1alpha1.NotificationChannelSpec{Type: "webhook", URL: sink.URL, Namespaces: []string{"*"}},
+	}
+	r := &UpdateReconciler{Client: newFakeClient(t, update, own, tenant), Recorder: record.NewFakeRecorder(10), AdminNamespace: "kupdater"}
+
+	changes := r.notifyChannels(context.Background(), update, time.Now())
+	if len(changes) != 1 {
+		t.Errorf("notifyChannels() returned %d changes, want 1", len(changes))
+	}
+	if calls != 2 {
+		t.Errorf("sink called %d times, want the unavailable sink retried once and the tenant channel skipped", calls)
+	}
+	if update.Status.Announcement(own.Key()) == nil {
+		t.Errorf("announcements = %+v, want the update announced on %s", update.Status.Announcements, own.Key())
+	}
+	if update.Status.Announcement(tenant.Key()) != nil {
+		t.Errorf("update announced on the channel of another tenant")
+	}
+}
+
+func TestRecordAnnouncementsConflict(t *testing.T) {
+	update := &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik"},
+		Status:     opsv1alpha1.UpdateStatus{Sources: []opsv1alpha1.SourceStatus{{Name: "chart", LatestInPolicy: "17.1.0", Outdated: true}}},
+	}
+	c := newFakeClient(t, update)
+	r := &UpdateReconciler{Client: c}
+	ctx := context.Background()
+
+	stale := &opsv1alpha1.Update{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(update), stale); err != nil {
+		t.Fatal(err)
+	}
+	// The Update is changed after it was checked
+	fresh := stale.DeepCopy()
+	fresh.Status.Phase = opsv1alpha1.UpdatePhaseOutdated
+	if err := c.Status().Update(ctx, fresh); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	changes := []func(*opsv1alpha1.Update){func(u *opsv1alpha1.Update) {
+		recordAnnouncement(u, "traefik/platform", []string{"chart=17.1.0"}, now)
+	}}
+	for _, change := range changes {
+		change(stale)
+	}
+	if err := r.recordAnnouncements(ctx, stale, changes); err != nil {
+		t.Fatalf("recordAnnouncements() = %v", err)
+	}
+
+	got := &opsv1alpha1.Update{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(update), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.Phase != opsv1alpha1.UpdatePhaseOutdated || got.Status.Announcement("traefik/platform") == nil {
+		t.Errorf("status = %+v, want the announcement recorded without losing the concurrent change", got.Status)
+	}
+}
+
+func TestRecordDigestVersions(t *testing.T) {
+	// A newer version was found after the digest was sent
+	update := &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{Name: "velero", Namespace: "velero"},
+		Status:     opsv1alpha1.UpdateStatus{Sources: []opsv1alpha1.SourceStatus{{Name: "chart", LatestInPolicy: "2.32.2", Outdated: true}}},
+	}
+	c := newFakeClient(t, update)
+	r := &NotificationChannelReconciler{Client: c}
+
+	if err := r.recordDigest(context.Background(), update.DeepCopy(), "kupdater/platform", []string{"chart=2.32.1"}, time.Now()); err != nil {
+		t.Fatalf("recordDigest() = %v", err)
+	}
+	got := &opsv1alpha1.Update{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(update), got); err != nil {
+		t.Fatal(err)
+	}
+	a := got.Status.Announcement("kupdater/platform")
+	if a == nil || len(a.Versions) != 1 || a.Versions[0] != "chart=2.32.1" {
+		t.Errorf("announcement = %+v, want the digested chart=2.32.1", a)
+	}
+	if !pendingAnnouncement(got, "kupdater/platform") {
+		t.Errorf("pendingAnnouncement() = false, want 2.32.2 announced in the next digest")
+	}
+}
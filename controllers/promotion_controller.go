@@ -0,0 +1,313 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// PromotionReconciler follows versions through the stages of Promotions,
+// promoting them to the next stage once they soaked.
+type PromotionReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// AdminNamespace is the namespace whose Promotions may promote versions
+	// between Updates of other namespaces.
+	AdminNamespace string
+}
+
+// ReasonVersionPromoted is recorded when a version is promoted to a stage.
+const ReasonVersionPromoted = "VersionPromoted"
+
+// stagePollPeriod is how often stages are checked while versions soak or
+// aren't healthy, as workload health changes aren't watched.
+const stagePollPeriod = 5 * time.Minute
+
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=promotions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=promotions/status,verbs=get;update;patch
+
+// Reconcile records the version running in every stage and promotes versions
+// which ran healthy for the soak time of their stage to the next one.
+func (r *PromotionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var log = ctrllog.Log.WithName("promotion.ops.getais.Reconcile").WithValues("namespace", req.Namespace, "name", req.Name)
+
+	promotion := &opsv1alpha1.Promotion{}
+	err := r.Get(ctx, req.NamespacedName, promotion)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get Promotion.")
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	var missing []string
+	poll := false
+	stages := make([]opsv1alpha1.StageStatus, 0, len(promotion.Spec.Stages))
+	for i, stage := range promotion.Spec.Stages {
+		st := opsv1alpha1.StageStatus{Name: stage.Name}
+		if previous := promotion.Status.Stage(stage.Name); previous != nil {
+			st = *previous.DeepCopy()
+		}
+
+		update, err := r.observeStage(ctx, promotion, stage, &st, now)
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("%s: %s", stage.Name, err))
+		}
+		if i > 0 {
+			r.promote(ctx, promotion, update, stages[i-1], &st, now)
+		}
+
+		st.PromotionTime, st.SoakRemaining = nil, ""
+		if i < len(promotion.Spec.Stages)-1 && st.Since != nil {
+			at := st.Since.Add(soakTime(stage))
+			st.PromotionTime = &metav1.Time{Time: at}
+			if remaining := at.Sub(now); remaining > 0 {
+				st.SoakRemaining = remaining.Round(time.Minute).String()
+			}
+		}
+		if !st.Healthy || st.SoakRemaining != "" {
+			poll = true
+		}
+		stages = append(stages, st)
+	}
+
+	promotion.Status.Stages = stages
+	promotion.Status.Chain = promotionChain(stages)
+	if len(missing) > 0 {
+		meta.SetStatusCondition(&promotion.Status.Conditions, metav1.Condition{Type: "Ready", Status: metav1.ConditionFalse, Reason: "StageNotFound", Message: strings.Join(missing, "; ")})
+	} else {
+		meta.SetStatusCondition(&promotion.Status.Conditions, metav1.Condition{Type: "Ready", Status: metav1.ConditionTrue, Reason: "StagesFound"})
+	}
+	if err := r.Status().Update(ctx, promotion); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{RequeueAfter: retryPeriod}, nil
+	}
+
+	if poll {
+		return ctrl.Result{RequeueAfter: stagePollPeriod}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PromotionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&opsv1alpha1.Promotion{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &opsv1alpha1.Update{}}, handler.EnqueueRequestsFromMapFunc(r.promotionsOf)).
+		Complete(r)
+}
+
+// promotionsOf maps an Update to the Promotions it is a stage of.
+func (r *PromotionReconciler) promotionsOf(obj client.Object) []reconcile.Request {
+	promotions := &opsv1alpha1.PromotionList{}
+	if err := r.List(context.Background(), promotions); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range promotions.Items {
+		p := &promotions.Items[i]
+		for _, stage := range p.Spec.Stages {
+			if key, ok := stageUpdate(p, stage, r.AdminNamespace); ok && key == client.ObjectKeyFromObject(obj) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// observeStage records the version installed in a stage and since when it
+// runs healthy. Versions which aren't healthy start soaking again once they
+// are.
+func (r *PromotionReconciler) observeStage(ctx context.Context, promotion *opsv1alpha1.Promotion, stage opsv1alpha1.PromotionStage, st *opsv1alpha1.StageStatus, now time.Time) (*opsv1alpha1.Update, error) {
+	key, ok := stageUpdate(promotion, stage, r.AdminNamespace)
+	if !ok {
+		st.Healthy, st.Since = false, nil
+		st.Message = fmt.Sprintf("Update %s is outside the namespace of the Promotion", key)
+		return nil, fmt.Errorf("%s", st.Message)
+	}
+	update := &opsv1alpha1.Update{}
+	if err := r.Get(ctx, key, update); err != nil {
+		st.Healthy, st.Since = false, nil
+		st.Message = err.Error()
+		return nil, err
+	}
+
+	name := promotionSource(promotion, update)
+	s := update.Spec.Versioning.Source(name)
+	if s == nil {
+		st.Healthy, st.Since = false, nil
+		st.Message = fmt.Sprintf("Update %s has no source %q", update.Name, name)
+		return update, fmt.Errorf("%s", st.Message)
+	}
+
+	version := s.Version
+	if status := update.Status.Source(name); status != nil && status.CurrentVersion != "" {
+		version = status.CurrentVersion
+	}
+	healthy, message := stageHealth(ctx, r.Client, update, *s)
+	if version != st.Version || !healthy {
+		st.Since = nil
+	}
+	st.Version, st.Healthy, st.Message = version, healthy, message
+	if healthy && st.Since == nil {
+		st.Since = &metav1.Time{Time: now}
+	}
+	return update, nil
+}
+
+// promote promotes the version of the previous stage to st once it soaked.
+// Promoted versions stay promoted while the previous stage soaks the next
+// version.
+func (r *PromotionReconciler) promote(ctx context.Context, promotion *opsv1alpha1.Promotion, update *opsv1alpha1.Update, previous opsv1alpha1.StageStatus, st *opsv1alpha1.StageStatus, now time.Time) {
+	if previous.Version == "" || previous.Version == st.Promoted || !previous.Healthy ||
+		previous.PromotionTime == nil || previous.PromotionTime.After(now) {
+		return
+	}
+	st.Promoted = previous.Version
+
+	message := fmt.Sprintf("Promoted %s from %s to %s", st.Promoted, previous.Name, st.Name)
+	r.Recorder.Event(promotion, corev1.EventTypeNormal, ReasonVersionPromoted, message)
+	if update != nil {
+		recordLineageEvent(ctx, r.Client, r.Recorder, update, corev1.EventTypeNormal, ReasonVersionPromoted, message)
+	}
+}
+
+// stageUpdate returns the Update of a stage, and whether the Promotion may
+// promote versions to it. Promotions outside the admin namespace only
+// promote versions between the Updates of their own namespace.
+func stageUpdate(promotion *opsv1alpha1.Promotion, stage opsv1alpha1.PromotionStage, admin string) (types.NamespacedName, bool) {
+	key := promotion.UpdateKey(stage)
+	return key, key.Namespace == promotion.Namespace || crossNamespace(promotion.Namespace, admin)
+}
+
+// stageHealth reports whether the installed version of a source runs
+// healthy, or why not. Updates which don't track a Deployment or Application
+// are healthy unless a new version is rolling out.
+func stageHealth(ctx context.Context, c client.Client, update *opsv1alpha1.Update, s opsv1alpha1.UpdateSource) (bool, string) {
+	if auto := update.Status.Automation; auto != nil && auto.Source == s.Name && auto.Phase == opsv1alpha1.AutomationProgressing {
+		return false, fmt.Sprintf("%s is rolling out", auto.Version)
+	}
+	b, err := workloadBumper(ctx, c, update, s)
+	if err != nil {
+		return true, ""
+	}
+	ready, err := b.Rollout(ctx)
+	switch {
+	case err != nil:
+		return false, err.Error()
+	case !ready:
+		return false, fmt.Sprintf("%s isn't ready", b.Target())
+	}
+	return true, ""
+}
+
+// promotionSource returns the name of the source a Promotion promotes
+// versions of in an Update.
+func promotionSource(promotion *opsv1alpha1.Promotion, update *opsv1alpha1.Update) string {
+	if promotion.Spec.Source != "" {
+		return promotion.Spec.Source
+	}
+	if len(update.Spec.Versioning.Sources) > 0 {
+		return update.Spec.Versioning.Sources[0].Name
+	}
+	return ""
+}
+
+// soakTime returns how long versions soak in a stage.
+func soakTime(stage opsv1alpha1.PromotionStage) time.Duration {
+	if stage.SoakTime != nil {
+		return stage.SoakTime.Duration
+	}
+	return 0
+}
+
+// promotionChain summarises the stages, e.g.
+// "dev: 1.3.0 -> staging: 1.3.0 (soaking, 5h0m0s left) -> prod: 1.2.0".
+func promotionChain(stages []opsv1alpha1.StageStatus) string {
+	chain := make([]string, 0, len(stages))
+	for _, st := range stages {
+		link := fmt.Sprintf("%s: %s", st.Name, st.Version)
+		switch {
+		case st.Version == "":
+			link = fmt.Sprintf("%s: unknown", st.Name)
+		case !st.Healthy:
+			link += " (unhealthy)"
+		case st.SoakRemaining != "":
+			link += fmt.Sprintf(" (soaking, %s left)", st.SoakRemaining)
+		case st.Promoted != "" && st.Promoted != st.Version:
+			link += fmt.Sprintf(" (%s promoted)", st.Promoted)
+		}
+		chain = append(chain, link)
+	}
+	return strings.Join(chain, " -> ")
+}
+
+// promotedVersion returns the newest version promoted to a source of an
+// Update, and whether the Update receives versions of the source from a
+// previous stage of a Promotion at all. Promotions of other namespaces are
+// ignored unless they are in the admin namespace.
+func promotedVersion(ctx context.Context, c client.Client, update *opsv1alpha1.Update, source, admin string) (string, bool, error) {
+	promotions := &opsv1alpha1.PromotionList{}
+	if err := c.List(ctx, promotions); err != nil {
+		return "", false, err
+	}
+	key := client.ObjectKeyFromObject(update)
+	for i := range promotions.Items {
+		p := &promotions.Items[i]
+		if promotionSource(p, update) != source {
+			continue
+		}
+		for j, stage := range p.Spec.Stages {
+			if j == 0 {
+				continue
+			}
+			if stageKey, ok := stageUpdate(p, stage, admin); !ok || stageKey != key {
+				continue
+			}
+			if st := p.Status.Stage(stage.Name); st != nil {
+				return st.Promoted, true, nil
+			}
+			return "", true, nil
+		}
+	}
+	return "", false, nil
+}
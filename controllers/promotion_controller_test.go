@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+// traefikStage returns the Update of traefik in the namespace of a stage,
+// running installed.
+func traefikStage(namespace, installed string) *opsv1alpha1.Update {
+	return &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: namespace},
+		Spec: opsv1alpha1.UpdateSpec{Versioning: opsv1alpha1.UpdateVersioning{Sources: []opsv1alpha1.UpdateSource{
+			{Name: "traefik", Type: "helm", Source: "https://helm.traefik.io/traefik", Version: installed},
+		}}},
+	}
+}
+
+// traefikPromotion returns a Promotion in namespace from the dev to the prod
+// namespace.
+func traefikPromotion(namespace string) *opsv1alpha1.Promotion {
+	return &opsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: namespace},
+		Spec: opsv1alpha1.PromotionSpec{Stages: []opsv1alpha1.PromotionStage{
+			{Name: "dev", Update: opsv1alpha1.UpdateReference{Name: "traefik", Namespace: "dev"}, SoakTime: &metav1.Duration{Duration: 24 * time.Hour}},
+			{Name: "prod", Update: opsv1alpha1.UpdateReference{Name: "traefik", Namespace: "prod"}},
+		}},
+	}
+}
+
+func TestObserveStage(t *testing.T) {
+	now := time.Date(2022, 10, 20, 8, 0, 0, 0, time.UTC)
+	since := &metav1.Time{Time: now.Add(-time.Hour)}
+	rollingOut := &opsv1alpha1.AutomationStatus{Phase: opsv1alpha1.AutomationProgressing, Source: "traefik", Version: "17.1.0"}
+
+	tests := map[string]struct {
+		namespace  string
+		admin      string
+		source     string
+		current    string
+		automation *opsv1alpha1.AutomationStatus
+		previous   opsv1alpha1.StageStatus
+		want       opsv1alpha1.StageStatus
+		fail       bool
+	}{
+		"first seen":      {namespace: "kupdater", admin: "kupdater", want: opsv1alpha1.StageStatus{Version: "17.0.5", Healthy: true, Since: &metav1.Time{Time: now}}},
+		"still healthy":   {namespace: "kupdater", admin: "kupdater", previous: opsv1alpha1.StageStatus{Version: "17.0.5", Healthy: true, Since: since}, want: opsv1alpha1.StageStatus{Version: "17.0.5", Healthy: true, Since: since}},
+		"new version":     {namespace: "kupdater", admin: "kupdater", current: "17.1.0", previous: opsv1alpha1.StageStatus{Version: "17.0.5", Healthy: true, Since: since}, want: opsv1alpha1.StageStatus{Version: "17.1.0", Healthy: true, Since: &metav1.Time{Time: now}}},
+		"rolling out":     {namespace: "kupdater", admin: "kupdater", automation: rollingOut, previous: opsv1alpha1.StageStatus{Version: "17.0.5", Healthy: true, Since: since}, want: opsv1alpha1.StageStatus{Version: "17.0.5"}},
+		"missing source":  {namespace: "kupdater", admin: "kupdater", source: "dashboard", fail: true},
+		"same namespace":  {namespace: "dev", want: opsv1alpha1.StageStatus{Version: "17.0.5", Healthy: true, Since: &metav1.Time{Time: now}}},
+		"other namespace": {namespace: "kupdater", previous: opsv1alpha1.StageStatus{Version: "17.0.5", Healthy: true, Since: since}, fail: true},
+		"tenant":          {namespace: "billing", admin: "kupdater", fail: true},
+	}
+	for name, tt := range tests {
+		update := traefikStage("dev", "17.0.5")
+		update.Status.Automation = tt.automation
+		if tt.current != "" {
+			update.Status.Sources = []opsv1alpha1.SourceStatus{{Name: "traefik", CurrentVersion: tt.current}}
+		}
+		promotion := traefikPromotion(tt.namespace)
+		promotion.Spec.Source = tt.source
+		r := &PromotionReconciler{Client: newFakeClient(t, update, promotion), Recorder: record.NewFakeRecorder(10), AdminNamespace: tt.admin}
+
+		st := tt.previous
+		got, err := r.observeStage(context.Background(), promotion, promotion.Spec.Stages[0], &st, now)
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: observeStage() = %v, want failed %v", name, err, tt.fail)
+		}
+		if tt.fail {
+			if st.Healthy || st.Since != nil || st.Message == "" {
+				t.Errorf("%s: stage = %+v, want it unhealthy with a message", name, st)
+			}
+			continue
+		}
+		if got == nil || got.Namespace != "dev" {
+			t.Errorf("%s: observeStage() = %v, want the dev Update", name, got)
+		}
+		if st.Version != tt.want.Version || st.Healthy != tt.want.Healthy || (st.Since == nil) != (tt.want.Since == nil) || (st.Since != nil && !st.Since.Equal(tt.want.Since)) {
+			t.Errorf("%s: stage = %+v, want %+v", name, st, tt.want)
+		}
+	}
+}
+
+func TestPromote(t *testing.T) {
+	now := time.Date(2022, 10, 20, 8, 0, 0, 0, time.UTC)
+	soaked := &metav1.Time{Time: now.Add(-time.Minute)}
+	soaking := &metav1.Time{Time: now.Add(time.Hour)}
+
+	tests := map[string]struct {
+		previous opsv1alpha1.StageStatus
+		promoted string
+		want     string
+	}{
+		"soaked":           {previous: opsv1alpha1.StageStatus{Version: "17.1.0", Healthy: true, PromotionTime: soaked}, promoted: "17.0.5", want: "17.1.0"},
+		"soaking":          {previous: opsv1alpha1.StageStatus{Version: "17.1.0", Healthy: true, PromotionTime: soaking}, promoted: "17.0.5", want: "17.0.5"},
+		"unhealthy":        {previous: opsv1alpha1.StageStatus{Version: "17.1.0", PromotionTime: soaked}, promoted: "17.0.5", want: "17.0.5"},
+		"unknown version":  {previous: opsv1alpha1.StageStatus{Healthy: true, PromotionTime: soaked}, promoted: "17.0.5", want: "17.0.5"},
+		"not soaked yet":   {previous: opsv1alpha1.StageStatus{Version: "17.1.0", Healthy: true}, want: ""},
+		"already promoted": {previous: opsv1alpha1.StageStatus{Version: "17.1.0", Healthy: true, PromotionTime: soaked}, promoted: "17.1.0", want: "17.1.0"},
+	}
+	for name, tt := range tests {
+		promotion := traefikPromotion("kupdater")
+		update := traefikStage("prod", "17.0.5")
+		recorder := record.NewFakeRecorder(10)
+		r := &PromotionReconciler{Client: newFakeClient(t, update, promotion), Recorder: recorder}
+
+		tt.previous.Name = "dev"
+		st := opsv1alpha1.StageStatus{Name: "prod", Promoted: tt.promoted}
+		r.promote(context.Background(), promotion, update, tt.previous, &st, now)
+		if st.Promoted != tt.want {
+			t.Errorf("%s: promoted = %q, want %q", name, st.Promoted, tt.want)
+		}
+		if promoted := st.Promoted != tt.promoted; promoted != (len(recorder.Events) > 0) {
+			t.Errorf("%s: %d events recorded, want events only when promoting", name, len(recorder.Events))
+		}
+	}
+}
+
+func TestPromotionCeiling(t *testing.T) {
+	s := opsv1alpha1.UpdateSource{Name: "traefik", Version: "17.0.5"}
+	tests := map[string]struct {
+		promoted string
+		gated    bool
+		want     string
+	}{
+		"not gated":          {want: ""},
+		"not gated promoted": {promoted: "17.1.0", want: ""},
+		"nothing promoted":   {gated: true, want: "17.0.5"},
+		"promoted":           {promoted: "17.1.0", gated: true, want: "17.1.0"},
+	}
+	for name, tt := range tests {
+		if got := promotionCeiling(s, tt.promoted, tt.gated); got != tt.want {
+			t.Errorf("%s: promotionCeiling() = %q, want %q", name, got, tt.want)
+		}
+	}
+}
+
+func TestPromotedVersion(t *testing.T) {
+	tests := map[string]struct {
+		namespace string
+		admin     string
+		stage     string
+		promoted  string
+		gated     bool
+	}{
+		"admin promotion":     {namespace: "kupdater", admin: "kupdater", stage: "prod", promoted: "17.1.0", gated: true},
+		"not reported yet":    {namespace: "kupdater", admin: "kupdater", gated: true},
+		"first stage":         {namespace: "kupdater", admin: "kupdater", stage: "dev"},
+		"without admin":       {namespace: "kupdater", stage: "prod", promoted: "17.1.0"},
+		"tenant promotion":    {namespace: "billing", admin: "kupdater", stage: "prod", promoted: "17.1.0"},
+		"same namespace":      {namespace: "prod", stage: "prod", promoted: "17.1.0", gated: true},
+		"same namespace only": {namespace: "prod", admin: "kupdater", stage: "prod", promoted: "17.1.0", gated: true},
+	}
+	for name, tt := range tests {
+		promotion := traefikPromotion(tt.namespace)
+		if tt.namespace == "prod" {
+			// A Promotion between Updates of its own namespace
+			promotion.Spec.Stages[0].Update = opsv1alpha1.UpdateReference{Name: "traefik-canary"}
+			promotion.Spec.Stages[1].Update = opsv1alpha1.UpdateReference{Name: "traefik"}
+		}
+		if tt.stage != "" {
+			promotion.Status.Stages = []opsv1alpha1.StageStatus{{Name: tt.stage, Promoted: tt.promoted}}
+		}
+		update := traefikStage("prod", "17.0.5")
+		if tt.stage == "dev" {
+			update = traefikStage("dev", "17.0.5")
+		}
+		c := newFakeClient(t, update, promotion)
+
+		promoted, gated, err := promotedVersion(context.Background(), c, update, "traefik", tt.admin)
+		if err != nil {
+			t.Fatalf("%s: promotedVersion() = %v", name, err)
+		}
+		wantPromoted := ""
+		if tt.gated {
+			wantPromoted = tt.promoted
+		}
+		if promoted != wantPromoted || gated != tt.gated {
+			t.Errorf("%s: promotedVersion() = %q, %v, want %q, %v", name, promoted, gated, wantPromoted, tt.gated)
+		}
+	}
+}
+
+func TestPromotionsOf(t *testing.T) {
+	r := &PromotionReconciler{Client: newFakeClient(t, traefikPromotion("billing")), AdminNamespace: "kupdater"}
+	if requests := r.promotionsOf(traefikStage("prod", "17.0.5")); len(requests) != 0 {
+		t.Errorf("promotionsOf() = %v, want no tenant Promotion", requests)
+	}
+	r = &PromotionReconciler{Client: newFakeClient(t, traefikPromotion("kupdater")), AdminNamespace: "kupdater"}
+	if requests := r.promotionsOf(traefikStage("prod", "17.0.5")); len(requests) != 1 || requests[0].NamespacedName != client.ObjectKeyFromObject(traefikPromotion("kupdater")) {
+		t.Errorf("promotionsOf() = %v, want the admin Promotion", requests)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/providers"
+	"github.com/getais/kupdater/pkg/versions"
+)
+
+// versionSelection gathers how the versions of a source are selected.
+type versionSelection struct {
+	versions.Options
+	// Channel is the least stable channel releases are selected from.
+	Channel string
+	// Literal allows comparing the latest release literally when the
+	// source doesn't publish comparable versions.
+	Literal bool
+}
+
+func newVersionSelection(s opsv1alpha1.UpdateSource) (versionSelection, error) {
+	var sel versionSelection
+	var err error
+
+	if sel.Policy, err = versions.NewPolicy(s.Constraint); err != nil {
+		return sel, err
+	}
+	if sel.Channel, err = versions.ParseChannel(s.Channel, s.IncludePrereleases); err != nil {
+		return sel, err
+	}
+	if sel.Parser, err = versions.NewParser(s.Ordering, s.VersionExtract); err != nil {
+		return sel, err
+	}
+	if s.TagFilter != nil {
+		if sel.Filter, err = versions.NewTagFilter(s.TagFilter.Include, s.TagFilter.Exclude); err != nil {
+			return sel, err
+		}
+	}
+	sel.Literal = s.TagFilter == nil && s.VersionExtract == ""
+	return sel, nil
+}
+
+// resolveVersions finds the newest versions of a source allowed by the
+// selection, along with the release date of the newest allowed version.
+// Sources which don't publish comparable versions are compared literally
+// with their latest release, which a ceiling only allows once they are
+// equal. Versions resolved out of a truncated list are returned along with
+// the TruncatedError of the provider.
+func resolveVersions(ctx context.Context, provider providers.Provider, src providers.Source, sel versionSelection) (versions.Result, time.Time, error) {
+	releases, err := provider.ListVersions(ctx, src)
+	if err != nil && !providers.IsTruncated(err) {
+		return versions.Result{}, time.Time{}, err
+	}
+	res, published, rerr := resolveReleases(ctx, provider, src, sel, releases)
+	if rerr != nil {
+		return res, published, rerr
+	}
+	return res, published, err
+}
+
+// resolveReleases resolves the versions of a source out of its releases,
+// see resolveVersions.
+func resolveReleases(ctx context.Context, provider providers.Provider, src providers.Source, sel versionSelection, releases []providers.Release) (versions.Result, time.Time, error) {
+
+	available := make([]string, 0, len(releases))
+	for _, r := range releases {
+		if r.Deprecated || !versions.InChannel(sel.Channel, releaseStability(r)) {
+			continue
+		}
+		available = append(available, r.Version)
+	}
+
+	res, err := versions.Resolve(src.Version, available, sel.Options)
+	if !errors.Is(err, versions.ErrNoVersions) || !sel.Literal {
+		for _, r := range releases {
+			if r.Version == res.LatestInPolicy {
+				return res, r.Published, err
+			}
+		}
+		return res, time.Time{}, err
+	}
+
+	release, err := provider.Latest(ctx, src)
+	if err != nil && !providers.IsTruncated(err) {
+		return versions.Result{}, time.Time{}, err
+	}
+	if release.Deprecated || !versions.InChannel(sel.Channel, releaseStability(release)) {
+		// The latest release isn't published on the channel, nothing newer
+		// than the installed version is available
+		return versions.Result{Current: src.Version, Latest: src.Version, LatestInPolicy: src.Version}, time.Time{}, err
+	}
+	res = versions.Result{
+		Current:        src.Version,
+		Latest:         release.Version,
+		LatestInPolicy: release.Version,
+	}
+	published := release.Published
+	if sel.Ceiling != "" && sel.Ceiling != release.Version {
+		// Versions can't be compared with the ceiling, the latest release
+		// is only allowed once it is the ceiling itself
+		res.LatestInPolicy = src.Version
+		published = time.Time{}
+	}
+	res.Outdated = src.Version != res.LatestInPolicy
+	if res.Outdated {
+		res.Behind = 1
+	}
+	return res, published, err
+}
+
+// releaseStability returns the channel a release is published on, taking
+// the pre-release flag of sources such as Github into account.
+func releaseStability(r providers.Release) string {
+	stability := versions.Stability(r.Version)
+	if r.Prerelease {
+		return versions.Least(stability, versions.Beta)
+	}
+	return stability
+}
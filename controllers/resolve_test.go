@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/providers"
+)
+
+func TestResolveVersionsLiteralChannel(t *testing.T) {
+	tests := map[string]struct {
+		published      []string
+		channel        string
+		latestInPolicy string
+		outdated       bool
+	}{
+		"stable release":             {published: []string{"release-2022-10-20"}, latestInPolicy: "release-2022-10-20", outdated: true},
+		"pre-release off channel":    {published: []string{"2.0.0-rc.1"}, latestInPolicy: "release-2022-10-01"},
+		"literal pre-release":        {published: []string{"release-rc"}, latestInPolicy: "release-2022-10-01"},
+		"literal pre-release beta":   {published: []string{"release-rc"}, channel: "beta", latestInPolicy: "release-rc", outdated: true},
+		"pre-release on channel":     {published: []string{"2.0.0-rc.1"}, channel: "beta", latestInPolicy: "2.0.0-rc.1", outdated: true},
+		"installed is latest stable": {published: []string{"release-2022-10-01"}, latestInPolicy: "release-2022-10-01"},
+	}
+	for name, tt := range tests {
+		s := opsv1alpha1.UpdateSource{Name: "backup", Version: "release-2022-10-01", Channel: tt.channel}
+		sel, err := newVersionSelection(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, _, err := resolveVersions(context.Background(), staticProvider(tt.published), providers.Source{Version: s.Version}, sel)
+		if err != nil {
+			t.Errorf("%s: resolveVersions() = %v", name, err)
+			continue
+		}
+		if res.LatestInPolicy != tt.latestInPolicy || res.Outdated != tt.outdated {
+			t.Errorf("%s: resolveVersions() = %s, outdated %v, want %s, outdated %v", name, res.LatestInPolicy, res.Outdated, tt.latestInPolicy, tt.outdated)
+		}
+	}
+}
+
+func TestResolveVersionsLiteralCeiling(t *testing.T) {
+	tests := map[string]struct {
+		ceiling        string
+		latestInPolicy string
+		outdated       bool
+	}{
+		"no ceiling":          {latestInPolicy: "release-2022-10-20", outdated: true},
+		"latest promoted":     {ceiling: "release-2022-10-20", latestInPolicy: "release-2022-10-20", outdated: true},
+		"nothing promoted":    {ceiling: "release-2022-10-01", latestInPolicy: "release-2022-10-01"},
+		"older promoted":      {ceiling: "release-2022-10-10", latestInPolicy: "release-2022-10-01"},
+		"incomparable latest": {ceiling: "stable", latestInPolicy: "release-2022-10-01"},
+	}
+	for name, tt := range tests {
+		s := opsv1alpha1.UpdateSource{Name: "backup", Version: "release-2022-10-01"}
+		sel, err := newVersionSelection(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sel.Ceiling = tt.ceiling
+		res, _, err := resolveVersions(context.Background(), staticProvider{"release-2022-10-20", "release-2022-10-10"}, providers.Source{Version: s.Version}, sel)
+		if err != nil {
+			t.Errorf("%s: resolveVersions() = %v", name, err)
+			continue
+		}
+		if res.LatestInPolicy != tt.latestInPolicy || res.Outdated != tt.outdated {
+			t.Errorf("%s: resolveVersions() = %s, outdated %v, want %s, outdated %v", name, res.LatestInPolicy, res.Outdated, tt.latestInPolicy, tt.outdated)
+		}
+	}
+}
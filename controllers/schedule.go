@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+const (
+	// MinCheckInterval is the shortest interval Updates are checked at, so
+	// sources aren't hammered and checks are always requeued.
+	MinCheckInterval = time.Minute
+	// DefaultCheckInterval is used when no valid interval is configured.
+	DefaultCheckInterval = time.Hour
+)
+
+// checkInterval returns how often the Update should be checked, falling back
+// to the operator wide default when spec.checkInterval is not set. Intervals
+// shorter than MinCheckInterval are raised to it.
+func checkInterval(update *opsv1alpha1.Update, fallback time.Duration) time.Duration {
+	interval := fallback
+	if update.Spec.CheckInterval != nil && update.Spec.CheckInterval.Duration > 0 {
+		interval = update.Spec.CheckInterval.Duration
+	}
+	switch {
+	case interval <= 0:
+		return DefaultCheckInterval
+	case interval < MinCheckInterval:
+		return MinCheckInterval
+	}
+	return interval
+}
+
+// nextCheckTime schedules the next check of an Update. A jitter of up to
+// jitter*interval is added so Updates created at the same time (or all of
+// them after an operator restart) don't hit their sources together. The
+// offset is derived from the object UID, so it is stable between checks.
+func nextCheckTime(update *opsv1alpha1.Update, now time.Time, interval time.Duration, jitter float64) time.Time {
+	if jitter <= 0 {
+		return now.Add(interval)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(update.UID))
+	if update.UID == "" {
+		h.Write([]byte(update.Namespace + "/" + update.Name))
+	}
+	fraction := float64(h.Sum32()) / float64(math.MaxUint32)
+
+	return now.Add(interval + time.Duration(fraction*jitter*float64(interval)))
+}
+
+// checkDue reports whether an Update has to be checked now, and if not, how
+// long until it has to be. Spec changes always trigger an immediate check,
+// as does overriding maintenance windows while a version is pending.
+func checkDue(update *opsv1alpha1.Update, now time.Time) (bool, time.Duration) {
+	if update.Status.ObservedGeneration != update.Generation || update.Status.NextCheckTime == nil {
+		return true, 0
+	}
+	if auto := update.Status.Automation; auto != nil && auto.Phase == opsv1alpha1.AutomationPending &&
+		update.Annotations[opsv1alpha1.MaintenanceOverrideAnnotation] == "true" {
+		return true, 0
+	}
+	if wait := update.Status.NextCheckTime.Sub(now); wait > 0 {
+		return false, wait
+	}
+	return true, 0
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+)
+
+func TestCheckInterval(t *testing.T) {
+	tests := map[string]struct {
+		spec     *metav1.Duration
+		fallback time.Duration
+		want     time.Duration
+	}{
+		"flag":                   {fallback: 2 * time.Hour, want: 2 * time.Hour},
+		"spec overrides flag":    {spec: &metav1.Duration{Duration: 15 * time.Minute}, fallback: time.Hour, want: 15 * time.Minute},
+		"zero spec":              {spec: &metav1.Duration{}, fallback: time.Hour, want: time.Hour},
+		"negative spec":          {spec: &metav1.Duration{Duration: -time.Minute}, fallback: time.Hour, want: time.Hour},
+		"spec below minimum":     {spec: &metav1.Duration{Duration: time.Second}, fallback: time.Hour, want: MinCheckInterval},
+		"zero flag":              {want: DefaultCheckInterval},
+		"negative flag":          {fallback: -time.Hour, want: DefaultCheckInterval},
+		"flag below minimum":     {fallback: 10 * time.Second, want: MinCheckInterval},
+		"spec with invalid flag": {spec: &metav1.Duration{Duration: 5 * time.Minute}, want: 5 * time.Minute},
+	}
+	for name, tt := range tests {
+		update := &opsv1alpha1.Update{Spec: opsv1alpha1.UpdateSpec{CheckInterval: tt.spec}}
+		if got := checkInterval(update, tt.fallback); got != tt.want {
+			t.Errorf("%s: checkInterval() = %s, want %s", name, got, tt.want)
+		}
+	}
+}
+
+func TestNextCheckTime(t *testing.T) {
+	now := time.Date(2022, 10, 20, 8, 0, 0, 0, time.UTC)
+	a := &opsv1alpha1.Update{ObjectMeta: metav1.ObjectMeta{UID: "5f0c7b52-4b8e-4b1f-9f0e-1d1e0f1c2a3b"}}
+	b := &opsv1alpha1.Update{ObjectMeta: metav1.ObjectMeta{UID: "0a6e2d2e-8a55-4d0b-a1c9-7c3b0e0f9d41"}}
+
+	if got := nextCheckTime(a, now, time.Hour, 0); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("nextCheckTime() without jitter = %s, want %s", got, now.Add(time.Hour))
+	}
+
+	first := nextCheckTime(a, now, time.Hour, 0.1)
+	if first.Before(now.Add(time.Hour)) || first.After(now.Add(66*time.Minute)) {
+		t.Errorf("nextCheckTime() = %s, want within 10%% after %s", first, now.Add(time.Hour))
+	}
+	if again := nextCheckTime(a, now, time.Hour, 0.1); !again.Equal(first) {
+		t.Errorf("nextCheckTime() = %s then %s, want a stable jitter", first, again)
+	}
+	if other := nextCheckTime(b, now, time.Hour, 0.1); other.Equal(first) {
+		t.Errorf("nextCheckTime() = %s for different Updates, want them spread", other)
+	}
+}
+
+func TestCheckDue(t *testing.T) {
+	now := time.Date(2022, 10, 20, 8, 0, 0, 0, time.UTC)
+	next := &metav1.Time{Time: now.Add(20 * time.Minute)}
+	pending := &opsv1alpha1.AutomationStatus{Phase: opsv1alpha1.AutomationPending}
+	override := map[string]string{opsv1alpha1.MaintenanceOverrideAnnotation: "true"}
+
+	tests := map[string]struct {
+		generation, observed int64
+		next                 *metav1.Time
+		annotations          map[string]string
+		automation           *opsv1alpha1.AutomationStatus
+		due                  bool
+		wait                 time.Duration
+	}{
+		"never checked":        {generation: 1, observed: 1, due: true},
+		"spec changed":         {generation: 2, observed: 1, next: next, due: true},
+		"not due":              {generation: 1, observed: 1, next: next, wait: 20 * time.Minute},
+		"due":                  {generation: 1, observed: 1, next: &metav1.Time{Time: now.Add(-time.Second)}, due: true},
+		"override pending":     {generation: 1, observed: 1, next: next, annotations: override, automation: pending, due: true},
+		"override not pending": {generation: 1, observed: 1, next: next, annotations: override, wait: 20 * time.Minute},
+	}
+	for name, tt := range tests {
+		update := &opsv1alpha1.Update{
+			ObjectMeta: metav1.ObjectMeta{Generation: tt.generation, Annotations: tt.annotations},
+			Status: opsv1alpha1.UpdateStatus{
+				ObservedGeneration: tt.observed,
+				NextCheckTime:      tt.next,
+				Automation:         tt.automation,
+			},
+		}
+		due, wait := checkDue(update, now)
+		if due != tt.due || wait != tt.wait {
+			t.Errorf("%s: checkDue() = %v, %s, want %v, %s", name, due, wait, tt.due, tt.wait)
+		}
+	}
+}
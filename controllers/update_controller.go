@@ -6,33 +6,59 @@ import (
 	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	"github.com/Masterminds/semver"
-	"github.com/getais/kupdater/api/v1alpha1"
 	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
-	"github.com/getais/kupdater/pkg/libs/helm"
-	"github.com/google/go-github/github"
+	"github.com/getais/kupdater/pkg/providers"
+	"github.com/getais/kupdater/pkg/versions"
 )
 
 // UpdateReconciler reconciles a Update object
 type UpdateReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Providers looks up versions for each source type.
+	Providers *providers.Registry
+	// CheckInterval is used for Updates that don't set spec.checkInterval.
+	CheckInterval time.Duration
+	// CheckJitter is the fraction of the interval checks are spread over.
+	CheckJitter float64
+	// AdminNamespace is the namespace whose NotificationChannels,
+	// MaintenanceWindows and Promotions may apply to other namespaces than
+	// their own.
+	AdminNamespace string
+	// ApprovalWebhook is set when the webhook recording who approved
+	// UpdateApprovals is served. Without it approvals could be written by
+	// anyone, so versions requiring approval stay pending.
+	ApprovalWebhook bool
 }
 
-const reconcilePeriod string = "2m"
+// retryPeriod is how long to wait before retrying a failed check.
+const retryPeriod = 2 * time.Minute
 
 //+kubebuilder:rbac:groups=ops.getais.cloud,resources=updates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=ops.getais.cloud,resources=updates/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=ops.getais.cloud,resources=updates/finalizers,verbs=update
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=updateapprovals,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=ops.getais.cloud,resources=promotions,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -46,6 +72,7 @@ func (r *UpdateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	if err != nil {
 		if errors.IsNotFound(err) {
 			log.Info("Update resource not found. Ignoring since object must be deleted.")
+			sourceMetrics.forget(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -53,99 +80,333 @@ func (r *UpdateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, err
 	}
 
-	log.Info("Reconciling")
+	now := time.Now()
+	// Export the last known versions, also when no check is due
+	sourceMetrics.export(update, now)
+	if rolloutInProgress(update) {
+		return r.watchRollout(ctx, update, now)
+	}
+	if due, wait := checkDue(update, now); !due && !r.approvalGranted(ctx, update) && !r.promotionChanged(ctx, update) {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
 
-	update.Status.Phase = "UpToDate"
-	update.Status.SyncTimestamp = time.Now().Format(time.RFC3339)
-	meta.SetStatusCondition(&update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionFalse, Reason: "CheckingForUpdates", Message: "Currently checking for new updates"})
+	log.Info("Reconciling")
 
 	// Check for updates
-	update = checkUpdatesHelm(ctx, update)
-	update, err = checkUpdatesGithub(ctx, update)
+	next := nextCheckTime(update, now, checkInterval(update, r.CheckInterval), r.CheckJitter)
+	retry, err := r.checkUpdates(ctx, update, now)
 	if err != nil {
 		log.Error(err, "Failed calling Update services")
-		return ctrl.Result{RequeueAfter: 2 * time.Minute}, nil
+		next = retry
 	}
 
+	merged := r.checkProposal(ctx, update, now)
+	if retry := r.automate(ctx, update, now); !retry.IsZero() && retry.Before(next) {
+		// Try pending versions again as soon as they may be applied
+		next = retry
+	}
+
+	// Schedule the next check
+	update.Status.ObservedGeneration = update.Generation
+	update.Status.LastCheckTime = &metav1.Time{Time: now}
+	update.Status.NextCheckTime = &metav1.Time{Time: next}
+
 	// Update CRD status
 	err = r.Status().Update(ctx, update)
 	if err != nil {
 		log.Error(err, "Failed to update status")
+		return ctrl.Result{RequeueAfter: retryPeriod}, nil
+	}
+	// Versions are announced once persisted, so they aren't announced again
+	// when the status couldn't be updated
+	if changes := r.notifyChannels(ctx, update, now); len(changes) > 0 {
+		if err := r.recordAnnouncements(ctx, update, changes); err != nil {
+			log.Error(err, "Failed to record announcements")
+			return ctrl.Result{RequeueAfter: retryPeriod}, nil
+		}
+	}
+	if merged {
+		auto := update.Status.Automation
+		if err := r.setInstalledVersion(ctx, update, auto.Source, auto.Version); err != nil {
+			log.Error(err, "Failed to record installed version")
+			return ctrl.Result{RequeueAfter: retryPeriod}, nil
+		}
 	}
 
-	return ctrl.Result{}, nil
+	if rolloutInProgress(update) {
+		return ctrl.Result{RequeueAfter: rolloutPollPeriod}, nil
+	}
+
+	log.Info("Next check scheduled", "at", next.Format(time.RFC3339))
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Updates are
+// checked again when versions are promoted to them.
 func (r *UpdateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	changed := builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&opsv1alpha1.Update{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		For(&opsv1alpha1.Update{}, changed).
+		Owns(&opsv1alpha1.UpdateApproval{}, changed).
+		Watches(&source.Kind{Type: &opsv1alpha1.Promotion{}}, handler.EnqueueRequestsFromMapFunc(promotedUpdates)).
 		Complete(r)
 }
 
-func checkUpdatesGithub(ctx context.Context, Update *v1alpha1.Update) (*v1alpha1.Update, error) {
-	if len(Update.Spec.Versioning.Sources) > 0 {
-		for _, s := range Update.Spec.Versioning.Sources {
-
-			if s.Type == "github" || s.Type == "Github" {
-				client := github.NewClient(nil)
+// promotedUpdates maps a Promotion to the Updates versions are promoted to.
+func promotedUpdates(obj client.Object) []reconcile.Request {
+	promotion, ok := obj.(*opsv1alpha1.Promotion)
+	if !ok {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i, stage := range promotion.Spec.Stages {
+		if i > 0 {
+			requests = append(requests, reconcile.Request{NamespacedName: promotion.UpdateKey(stage)})
+		}
+	}
+	return requests
+}
 
-				// https://github.com/argoproj/argo-cd
-				var owner = strings.Split(s.Source, "/")[3]
-				var repo = strings.Split(s.Source, "/")[4]
+// promotionChanged reports whether a version was promoted to the Update
+// since it was last checked.
+func (r *UpdateReconciler) promotionChanged(ctx context.Context, update *opsv1alpha1.Update) bool {
+	for _, s := range update.Status.Sources {
+		promoted, gated, err := promotedVersion(ctx, r.Client, update, s.Name, r.AdminNamespace)
+		if err == nil && gated && promoted != s.Promoted {
+			return true
+		}
+	}
+	return false
+}
 
-				// Fetch latest Github release
-				release, _, err := client.Repositories.GetLatestRelease(context.Background(), owner, repo)
-				if err != nil {
-					return nil, err
-				}
+// checkUpdates looks up the versions of every source through the provider
+// registered for its type and records them in the status. Sources are
+// checked independently, a failing source doesn't prevent checking others.
+// When sources fail, it returns when to check them again: once their rate
+// limit resets, or after the retry period.
+func (r *UpdateReconciler) checkUpdates(ctx context.Context, Update *opsv1alpha1.Update, now time.Time) (time.Time, error) {
+	var errs, limited, truncated []string
+	var retry time.Time
+	statuses := make([]opsv1alpha1.SourceStatus, 0, len(Update.Spec.Versioning.Sources))
+	var skipped []string
 
-				LatestVersion := *release.TagName
+	for _, s := range Update.Spec.Versioning.Sources {
+		status := opsv1alpha1.SourceStatus{Name: s.Name, Type: s.Type}
+		if previous := Update.Status.Source(s.Name); previous != nil {
+			status = *previous.DeepCopy()
+			status.Type = s.Type
+		}
+		status.LastChecked = &metav1.Time{Time: now}
 
-				meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionTrue, Reason: "NoUpdatesAvailable", Message: "No updates were found"})
-				if s.Version != LatestVersion {
-					Update.Status.Phase = fmt.Sprintf("Outdated (%s available)", LatestVersion)
-					s.Version = LatestVersion
-					meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionFalse, Reason: "UpdatesAvailable", Message: fmt.Sprintf("New release available: %s", LatestVersion)})
+		promoted, gated, err := promotedVersion(ctx, r.Client, Update, s.Name, r.AdminNamespace)
+		var res versions.Result
+		var released time.Time
+		if err == nil {
+			res, released, err = r.checkSource(ctx, Update.Namespace, s, promotionCeiling(s, promoted, gated))
+		}
+		if providers.IsTruncated(err) {
+			truncated = append(truncated, fmt.Sprintf("%s: %s", s.Name, err))
+			err = nil
+		}
+		if err != nil {
+			reason := ReasonCheckFailed
+			again := now.Add(retryPeriod)
+			if providers.IsUnreachable(err) {
+				reason = ReasonSourceUnreachable
+			}
+			if reset, ok := providers.RateLimitReset(err); ok {
+				reason = ReasonRateLimited
+				if reset.After(now) {
+					again = reset
 				}
+				limited = append(limited, fmt.Sprintf("%s until %s", s.Name, again.UTC().Format(time.RFC3339)))
+			}
+			if retry.IsZero() || again.Before(retry) {
+				retry = again
 			}
+			recordLineageEvent(ctx, r.Client, r.Recorder, Update, corev1.EventTypeWarning, reason, fmt.Sprintf("Source %s: %s", s.Name, err))
+
+			status.Error = err.Error()
+			errs = append(errs, fmt.Sprintf("%s: %s", s.Name, err))
+			statuses = append(statuses, status)
+			continue
+		}
+
+		if res.Outdated && (!status.Outdated || status.LatestInPolicy != res.LatestInPolicy) {
+			recordLineageEvent(ctx, r.Client, r.Recorder, Update, corev1.EventTypeNormal, ReasonUpdateAvailable,
+				fmt.Sprintf("Source %s can be updated from %s to %s", s.Name, res.Current, res.LatestInPolicy))
+		}
+
+		status.Error = ""
+		status.Promoted = promoted
+		status.CurrentVersion = res.Current
+		status.LatestVersion = res.Latest
+		status.LatestInPolicy = res.LatestInPolicy
+		status.Outdated = res.Outdated
+		status.Behind = res.Behind
+		status.ReleaseDate = nil
+		if !released.IsZero() {
+			status.ReleaseDate = &metav1.Time{Time: released}
+		}
+		statuses = append(statuses, status)
+
+		for _, v := range res.Skipped {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", s.Name, v))
 		}
 	}
-	return Update, nil
+
+	Update.Status.Sources = statuses
+	sourceMetrics.export(Update, now)
+	setUpdatePhase(Update)
+	reportSkippedVersions(ctx, Update, skipped)
+	reportRateLimits(Update, limited)
+	reportTruncatedVersions(Update, truncated)
+
+	if len(errs) > 0 {
+		return retry, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return time.Time{}, nil
 }
 
-func checkUpdatesHelm(ctx context.Context, Update *v1alpha1.Update) *v1alpha1.Update {
-	if len(Update.Spec.Versioning.Sources) > 0 {
-		for _, s := range Update.Spec.Versioning.Sources {
+// checkSource resolves the versions of a single source, returning when the
+// newest version allowed by policy was released, if known. Versions newer
+// than ceiling aren't suggested, unless it is empty.
+func (r *UpdateReconciler) checkSource(ctx context.Context, namespace string, s opsv1alpha1.UpdateSource, ceiling string) (versions.Result, time.Time, error) {
+	provider, err := r.Providers.Get(s.Type)
+	if err != nil {
+		return versions.Result{}, time.Time{}, err
+	}
+	provider = instrument(s.Type, provider)
 
-			if s.Type == "helm" || s.Type == "Helm" {
-				var Helm helm.Helm
+	selection, err := newVersionSelection(s)
+	if err != nil {
+		return versions.Result{}, time.Time{}, err
+	}
+	selection.Ceiling = ceiling
 
-				Repo, err := Helm.GetReleases(s.Source)
-				if err != nil {
-					Update.Status.Phase = err.Error()
-					return Update
-				}
-				Releases := Repo.Entries[s.Name]
-
-				if len(Releases) > 0 {
-					Versions := make([]*semver.Version, len(Releases))
-					for i, r := range Releases {
-						v, _ := semver.NewVersion(r.Version)
-						Versions[i] = v
-					}
-					LatestVersion := Versions[0].String()
-
-					meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionTrue, Reason: "NoUpdatesAvailable", Message: "No updates were found"})
-					if s.Version != LatestVersion {
-						Update.Status.Phase = fmt.Sprintf("Outdated (%s available)", LatestVersion)
-						s.Version = LatestVersion
-						meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionFalse, Reason: "UpdatesAvailable", Message: fmt.Sprintf("New release available: %s", LatestVersion)})
-					}
-				}
-			}
+	src, err := r.providerSource(ctx, namespace, s)
+	if err != nil {
+		return versions.Result{}, time.Time{}, err
+	}
+	src.Compare = selection.Parser.CompareInstalled(src.Version)
+	if err := provider.Validate(src); err != nil {
+		return versions.Result{}, time.Time{}, err
+	}
+
+	return resolveVersions(ctx, provider, src, selection)
+}
+
+// promotionCeiling returns the newest version a source receiving versions
+// from a previous stage may be updated to: the promoted version, or the
+// installed one until a version is promoted.
+func promotionCeiling(s opsv1alpha1.UpdateSource, promoted string, gated bool) string {
+	switch {
+	case !gated:
+		return ""
+	case promoted == "":
+		return s.Version
+	}
+	return promoted
+}
+
+// setUpdatePhase summarises the status of all sources into the phase and
+// UpToDate condition.
+func setUpdatePhase(Update *opsv1alpha1.Update) {
+	var outdated, failed []string
+	for _, s := range Update.Status.Sources {
+		switch {
+		case s.Error != "":
+			failed = append(failed, fmt.Sprintf("%s: %s", s.Name, s.Error))
+		case s.Outdated:
+			outdated = append(outdated, fmt.Sprintf("%s: %s", s.Name, s.LatestInPolicy))
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		Update.Status.Phase = opsv1alpha1.UpdatePhaseFailed
+		meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionUnknown, Reason: "CheckFailed", Message: strings.Join(failed, "; ")})
+	case len(outdated) > 0:
+		Update.Status.Phase = opsv1alpha1.UpdatePhaseOutdated
+		meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionFalse, Reason: "UpdatesAvailable", Message: fmt.Sprintf("New release available: %s", strings.Join(outdated, ", "))})
+	default:
+		Update.Status.Phase = opsv1alpha1.UpdatePhaseUpToDate
+		meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "UpToDate", Status: metav1.ConditionTrue, Reason: "NoUpdatesAvailable", Message: upToDateMessage(Update.Status.Sources)})
+	}
+}
+
+// reportSkippedVersions warns about published versions which couldn't be
+// compared, as they might hide an update.
+func reportSkippedVersions(ctx context.Context, Update *opsv1alpha1.Update, skipped []string) {
+	if len(skipped) == 0 {
+		meta.RemoveStatusCondition(&Update.Status.Conditions, "InvalidVersions")
+		return
+	}
+
+	ctrllog.FromContext(ctx).Info("Skipped versions which couldn't be parsed", "versions", skipped)
+
+	listed := skipped
+	if len(listed) > 10 {
+		listed = listed[:10]
+	}
+	message := fmt.Sprintf("Skipped %d versions which couldn't be parsed: %s", len(skipped), strings.Join(listed, ", "))
+	meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "InvalidVersions", Status: metav1.ConditionTrue, Reason: "VersionsSkipped", Message: message})
+}
+
+// reportRateLimits sets the RateLimited condition while the API of sources
+// refuses requests, so they aren't mistaken for broken sources.
+func reportRateLimits(Update *opsv1alpha1.Update, limited []string) {
+	if len(limited) == 0 {
+		meta.RemoveStatusCondition(&Update.Status.Conditions, "RateLimited")
+		return
+	}
+	message := fmt.Sprintf("API rate limit exceeded, checking again once it resets: %s", strings.Join(limited, ", "))
+	meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "RateLimited", Status: metav1.ConditionTrue, Reason: "RateLimitExceeded", Message: message})
+}
+
+// reportTruncatedVersions sets the VersionsTruncated condition while sources
+// publish more versions than are listed, as newer versions may be missing.
+func reportTruncatedVersions(Update *opsv1alpha1.Update, truncated []string) {
+	if len(truncated) == 0 {
+		meta.RemoveStatusCondition(&Update.Status.Conditions, "VersionsTruncated")
+		return
+	}
+	message := fmt.Sprintf("Versions were resolved out of the first pages listed: %s", strings.Join(truncated, "; "))
+	meta.SetStatusCondition(&Update.Status.Conditions, metav1.Condition{Type: "VersionsTruncated", Status: metav1.ConditionTrue, Reason: "PageLimitReached", Message: message})
+}
+
+// upToDateMessage mentions newer releases not allowed by policy.
+func upToDateMessage(sources []opsv1alpha1.SourceStatus) string {
+	var newer []string
+	for _, s := range sources {
+		if s.LatestVersion != s.LatestInPolicy && s.LatestVersion != s.CurrentVersion {
+			newer = append(newer, fmt.Sprintf("%s: %s", s.Name, s.LatestVersion))
+		}
+	}
+	if len(newer) > 0 {
+		return fmt.Sprintf("No updates allowed by policy, latest releases are %s", strings.Join(newer, ", "))
+	}
+	return "No updates were found"
+}
+
+// providerSource converts an UpdateSource into what providers look up,
+// resolving the credentials it references.
+func (r *UpdateReconciler) providerSource(ctx context.Context, namespace string, s opsv1alpha1.UpdateSource) (providers.Source, error) {
+	src := providers.Source{
+		Name:    s.Name,
+		URL:     s.Source,
+		Version: s.Version,
+		Mode:    s.Mode,
+		APIURL:  s.APIURL,
+	}
+
+	if s.SecretRef != nil {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: s.SecretRef.Name, Namespace: namespace}, secret)
+		if err != nil {
+			return src, fmt.Errorf("%s: failed to get credentials: %w", s.Name, err)
 		}
+		src.Credentials = secret.Data
 	}
-	return Update
+	return src, nil
 }
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
+	"github.com/getais/kupdater/pkg/providers"
+)
+
+// newFakeClient returns a client serving objs from memory.
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, appsv1.AddToScheme, opsv1alpha1.AddToScheme, argov1alpha1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+// staticProvider publishes a fixed list of versions.
+type staticProvider []string
+
+func (p staticProvider) Validate(providers.Source) error { return nil }
+
+func (p staticProvider) ListVersions(context.Context, providers.Source) ([]providers.Release, error) {
+	releases := make([]providers.Release, 0, len(p))
+	for _, v := range p {
+		releases = append(releases, providers.Release{Version: v})
+	}
+	return releases, nil
+}
+
+func (p staticProvider) Latest(ctx context.Context, src providers.Source) (providers.Release, error) {
+	return providers.Release{Version: p[0]}, nil
+}
+
+// truncatedProvider lists its versions as the first pages of a longer list.
+type truncatedProvider struct{ staticProvider }
+
+func (p truncatedProvider) ListVersions(ctx context.Context, src providers.Source) ([]providers.Release, error) {
+	releases, _ := p.staticProvider.ListVersions(ctx, src)
+	return releases, &providers.TruncatedError{Source: src.URL, Pages: 10}
+}
+
+func TestCheckUpdatesUnknownType(t *testing.T) {
+	update := &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik"},
+		Spec: opsv1alpha1.UpdateSpec{Versioning: opsv1alpha1.UpdateVersioning{Sources: []opsv1alpha1.UpdateSource{
+			{Name: "chart", Type: "static", Source: "https://helm.traefik.io/traefik", Version: "17.0.5"},
+			{Name: "binary", Type: "svn", Source: "svn://example.com/traefik", Version: "2.9.1"},
+		}}},
+	}
+	registry := providers.NewRegistry()
+	registry.Register(staticProvider{"17.0.5", "17.1.0"}, "static")
+	r := &UpdateReconciler{Client: newFakeClient(t, update), Recorder: record.NewFakeRecorder(10), Providers: registry}
+
+	_, err := r.checkUpdates(context.Background(), update, time.Now())
+	if err == nil {
+		t.Fatal("checkUpdates() succeeded with an unsupported source type")
+	}
+	chart, binary := update.Status.Source("chart"), update.Status.Source("binary")
+	if chart == nil || chart.Error != "" || chart.LatestVersion != "17.1.0" || !chart.Outdated {
+		t.Errorf("chart status = %+v, want it checked despite the other source failing", chart)
+	}
+	if binary == nil || binary.Error == "" {
+		t.Errorf("binary status = %+v, want the unsupported type reported", binary)
+	}
+	if update.Status.Phase != opsv1alpha1.UpdatePhaseFailed {
+		t.Errorf("phase = %s, want %s", update.Status.Phase, opsv1alpha1.UpdatePhaseFailed)
+	}
+}
+
+func TestCheckUpdatesTruncated(t *testing.T) {
+	update := &opsv1alpha1.Update{
+		ObjectMeta: metav1.ObjectMeta{Name: "traefik", Namespace: "traefik"},
+		Spec: opsv1alpha1.UpdateSpec{Versioning: opsv1alpha1.UpdateVersioning{Sources: []opsv1alpha1.UpdateSource{
+			{Name: "chart", Type: "truncated", Source: "https://helm.traefik.io/traefik", Version: "17.0.5"},
+		}}},
+	}
+	registry := providers.NewRegistry()
+	registry.Register(truncatedProvider{staticProvider{"17.0.5", "17.1.0"}}, "truncated")
+	r := &UpdateReconciler{Client: newFakeClient(t, update), Recorder: record.NewFakeRecorder(10), Providers: registry}
+
+	if _, err := r.checkUpdates(context.Background(), update, time.Now()); err != nil {
+		t.Fatalf("checkUpdates() = %v, want the versions listed resolved", err)
+	}
+	if chart := update.Status.Source("chart"); chart == nil || chart.Error != "" || chart.LatestVersion != "17.1.0" {
+		t.Errorf("chart status = %+v, want 17.1.0 found", chart)
+	}
+	if !meta.IsStatusConditionTrue(update.Status.Conditions, "VersionsTruncated") {
+		t.Errorf("conditions = %+v, want VersionsTruncated", update.Status.Conditions)
+	}
+}
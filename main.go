@@ -20,6 +20,7 @@ import (
 	"flag"
 	"os"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -33,10 +34,12 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	opsv1alpha1 "github.com/getais/kupdater/api/v1alpha1"
 	"github.com/getais/kupdater/controllers"
+	"github.com/getais/kupdater/pkg/providers"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -59,12 +62,22 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var sources string
+	var checkInterval time.Duration
+	var checkJitter float64
+	var adminNamespace string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&sources, "appversion-sources", "crd,deployment", "Sources operator looks into when reconciling")
+	flag.DurationVar(&checkInterval, "update-check-interval", time.Hour,
+		"How often Updates are checked for new versions, unless overridden by spec.checkInterval.")
+	flag.Float64Var(&checkJitter, "update-check-jitter", 0.1,
+		"Fraction of the check interval used to spread checks of different Updates over time.")
+	flag.StringVar(&adminNamespace, "admin-namespace", "",
+		"Namespace whose NotificationChannels, MaintenanceWindows and Promotions may apply to the Updates of other namespaces. "+
+			"When empty, every object only applies to its own namespace.")
 
 	opts := zap.Options{
 		Development: true,
@@ -74,6 +87,11 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if checkInterval < controllers.MinCheckInterval {
+		setupLog.Error(nil, "--update-check-interval is shorter than the minimum", "interval", checkInterval, "minimum", controllers.MinCheckInterval)
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -97,26 +115,62 @@ func main() {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
+	// The webhook needs a serving certificate, config/default issues one with
+	// cert-manager and enables it. Approvals are refused without it.
+	webhooks := os.Getenv("ENABLE_WEBHOOKS") == "true"
 
 	if err = (&controllers.UpdateReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("kupdater-update"),
+		Providers:       providers.Default(),
+		CheckInterval:   checkInterval,
+		CheckJitter:     checkJitter,
+		AdminNamespace:  adminNamespace,
+		ApprovalWebhook: webhooks,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Update")
 		os.Exit(1)
 	}
 	if err = (&controllers.AppVersionReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("kupdater-appversion"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AppVersion")
+		os.Exit(1)
+	}
+	if err = (&controllers.NotificationChannelReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("kupdater-notification"),
+		AdminNamespace: adminNamespace,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NotificationChannel")
+		os.Exit(1)
+	}
+	if err = (&controllers.MaintenanceWindowReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "AppVersion")
+		setupLog.Error(err, "unable to create controller", "controller", "MaintenanceWindow")
+		os.Exit(1)
+	}
+	if err = (&controllers.PromotionReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("kupdater-promotion"),
+		AdminNamespace: adminNamespace,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Promotion")
 		os.Exit(1)
 	}
 
 	if strings.Contains(sources, "argocd") {
 		if err = (&controllers.ApplicationReconciler{
-			Client: mgr.GetClient(),
-			Scheme: mgr.GetScheme(),
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("kupdater-application"),
 		}).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "Application")
 			os.Exit(1)
@@ -125,13 +179,18 @@ func main() {
 
 	if strings.Contains(sources, "deployment") {
 		if err = (&controllers.DeploymentReconciler{
-			Client: mgr.GetClient(),
-			Scheme: mgr.GetScheme(),
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("kupdater-deployment"),
 		}).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "Application")
 			os.Exit(1)
 		}
 	}
+	if webhooks {
+		mgr.GetWebhookServer().Register(opsv1alpha1.UpdateApprovalWebhookPath,
+			&webhook.Admission{Handler: &opsv1alpha1.ApprovalRecorder{}})
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
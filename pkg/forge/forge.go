@@ -0,0 +1,195 @@
+// Package forge opens pull requests on the forges hosting Git repositories.
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// State of a pull request.
+type State string
+
+const (
+	StateOpen   State = "open"
+	StateMerged State = "merged"
+	StateClosed State = "closed"
+)
+
+// PullRequest is a pull request, or merge request in Gitlab terms.
+type PullRequest struct {
+	Number int
+	// URL of the pull request page.
+	URL   string
+	Title string
+	Body  string
+	// Head is the branch with the changes.
+	Head string
+	// Base is the branch the changes are merged into.
+	Base  string
+	State State
+}
+
+// Client manages pull requests of one repository.
+type Client interface {
+	// FindOpen returns the open pull request from head into base, or nil.
+	FindOpen(ctx context.Context, head, base string) (*PullRequest, error)
+	// Get returns a pull request by number.
+	Get(ctx context.Context, number int) (*PullRequest, error)
+	// Create opens a pull request.
+	Create(ctx context.Context, pr PullRequest) (*PullRequest, error)
+	// Edit sets the title and body of a pull request.
+	Edit(ctx context.Context, pr PullRequest) (*PullRequest, error)
+}
+
+// Open opens a pull request, or updates the title and body of the pull
+// request already open from the same head into the same base.
+func Open(ctx context.Context, c Client, pr PullRequest) (*PullRequest, error) {
+	existing, err := c.FindOpen(ctx, pr.Head, pr.Base)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return c.Create(ctx, pr)
+	}
+	if existing.Title == pr.Title && existing.Body == pr.Body {
+		return existing, nil
+	}
+	pr.Number = existing.Number
+	return c.Edit(ctx, pr)
+}
+
+// Config holds the settings a client is built from.
+type Config struct {
+	// Type is one of github, gitlab or gitea.
+	Type string
+	// URL of the forge, e.g. https://gitea.example.com. Defaults to
+	// https://github.com and https://gitlab.com, required for Gitea.
+	URL string
+	// Repository is the path of the repository, e.g. getais/homelab.
+	Repository string
+	// Token authenticates API requests.
+	Token string
+	// Client used for API requests, defaults to a client with a 30s
+	// timeout.
+	Client *http.Client
+}
+
+// New returns the client described by cfg.
+func New(cfg Config) (Client, error) {
+	if strings.Count(cfg.Repository, "/") < 1 {
+		return nil, fmt.Errorf("invalid repository %q, expected owner/name", cfg.Repository)
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	server := strings.TrimSuffix(cfg.URL, "/")
+
+	switch cfg.Type {
+	case "github":
+		return NewGithub(server, cfg.Repository, cfg.Token, client)
+	case "gitlab":
+		if server == "" {
+			server = "https://gitlab.com"
+		}
+		return &Gitlab{URL: server, Project: cfg.Repository, Token: cfg.Token, Client: client}, nil
+	case "gitea":
+		if server == "" {
+			return nil, fmt.Errorf("no url configured for Gitea")
+		}
+		return &Gitea{URL: server, Repository: cfg.Repository, Token: cfg.Token, Client: client}, nil
+	}
+	return nil, fmt.Errorf("unsupported forge %q, expected one of: github, gitlab, gitea", cfg.Type)
+}
+
+// ParseRepository splits the url of a Git repository into the url of the
+// server and the path of the repository. HTTP(S), ssh:// and scp-like urls
+// such as git@github.com:getais/homelab.git are understood.
+func ParseRepository(repoURL string) (server, repository string, err error) {
+	host, p := "", ""
+	if u, perr := url.Parse(repoURL); perr == nil && u.Scheme != "" && u.Host != "" {
+		host, p = u.Hostname(), u.Path
+	} else if i := strings.Index(repoURL, ":"); i > 0 && !strings.Contains(repoURL[:i], "/") {
+		// scp-like user@host:path
+		host, p = repoURL[:i], repoURL[i+1:]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+	}
+	repository = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+	if host == "" || !strings.Contains(repository, "/") {
+		return "", "", fmt.Errorf("invalid repository url %q", repoURL)
+	}
+	return "https://" + host, repository, nil
+}
+
+// Number returns the number of a pull request out of its url, which ends
+// with the number on every forge.
+func Number(prURL string) (int, error) {
+	n, err := strconv.Atoi(path.Base(prURL))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid pull request url %q", prURL)
+	}
+	return n, nil
+}
+
+// apiError is returned for non 2xx responses.
+type apiError struct {
+	Method string
+	URL    string
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// do sends a JSON request and decodes the JSON response into out, if not
+// nil. Non 2xx responses fail with an apiError.
+func do(ctx context.Context, client *http.Client, method, u string, header http.Header, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &apiError{Method: method, URL: u, Status: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
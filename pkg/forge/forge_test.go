@@ -0,0 +1,91 @@
+package forge
+
+import (
+	"context"
+	"testing"
+)
+
+// conformance opens a pull request twice through c and checks the second
+// call updates the first pull request instead of opening another one.
+func conformance(t *testing.T, c Client) {
+	ctx := context.Background()
+	pr := PullRequest{Head: "kupdater/traefik", Base: "main", Title: "Update traefik to 17.1.0", Body: "Updates traefik from 17.0.5 to 17.1.0"}
+
+	opened, err := Open(ctx, c, pr)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	if opened.Number == 0 || opened.URL == "" || opened.State != StateOpen || opened.Head != pr.Head || opened.Base != pr.Base {
+		t.Fatalf("Open() = %+v", opened)
+	}
+
+	pr.Title, pr.Body = "Update traefik to 17.2.0", "Updates traefik from 17.0.5 to 17.2.0"
+	updated, err := Open(ctx, c, pr)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	if updated.Number != opened.Number || updated.Title != pr.Title || updated.Body != pr.Body {
+		t.Errorf("Open() = %+v, want pull request %d updated", updated, opened.Number)
+	}
+
+	got, err := c.Get(ctx, opened.Number)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if got.Title != pr.Title || got.URL != opened.URL {
+		t.Errorf("Get() = %+v", got)
+	}
+
+	other, err := c.FindOpen(ctx, "kupdater/grafana", "main")
+	if err != nil || other != nil {
+		t.Errorf("FindOpen() = %v, %v, want none", other, err)
+	}
+}
+
+func TestParseRepository(t *testing.T) {
+	tests := []struct {
+		url                string
+		server, repository string
+		wantErr            bool
+	}{
+		{url: "https://github.com/getais/homelab.git", server: "https://github.com", repository: "getais/homelab"},
+		{url: "https://gitlab.example.com/infra/clusters/homelab", server: "https://gitlab.example.com", repository: "infra/clusters/homelab"},
+		{url: "ssh://git@gitea.example.com:2222/getais/homelab.git", server: "https://gitea.example.com", repository: "getais/homelab"},
+		{url: "git@github.com:getais/homelab.git", server: "https://github.com", repository: "getais/homelab"},
+		{url: "https://github.com/getais", wantErr: true},
+		{url: "/srv/git/homelab", wantErr: true},
+	}
+	for _, tt := range tests {
+		server, repository, err := ParseRepository(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRepository(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if server != tt.server || repository != tt.repository {
+			t.Errorf("ParseRepository(%q) = %q, %q, want %q, %q", tt.url, server, repository, tt.server, tt.repository)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	for u, want := range map[string]int{
+		"https://github.com/getais/homelab/pull/12":            12,
+		"https://gitlab.com/getais/homelab/-/merge_requests/7": 7,
+		"https://gitea.example.com/getais/homelab/pulls/3":     3,
+		"https://github.com/getais/homelab/pulls":              0,
+	} {
+		n, err := Number(u)
+		if want == 0 && err == nil || want != 0 && n != want {
+			t.Errorf("Number(%q) = %d, %v, want %d", u, n, err, want)
+		}
+	}
+}
+
+func TestNewUnsupported(t *testing.T) {
+	if _, err := New(Config{Type: "bitbucket", Repository: "getais/homelab"}); err == nil {
+		t.Error("New(bitbucket) succeeded")
+	}
+	if _, err := New(Config{Type: "gitea", Repository: "getais/homelab"}); err == nil {
+		t.Error("New(gitea) without url succeeded")
+	}
+}
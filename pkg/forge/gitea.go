@@ -0,0 +1,121 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// giteaPageSize is the number of pull requests listed per request.
+const giteaPageSize = 50
+
+// Gitea manages pull requests of a Gitea or Forgejo repository.
+type Gitea struct {
+	// URL of the Gitea server.
+	URL string
+	// Repository is the path of the repository, e.g. getais/homelab.
+	Repository string
+	Token      string
+	Client     *http.Client
+}
+
+type giteaPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	// State is either open or closed.
+	State  string `json:"state"`
+	Merged bool   `json:"merged"`
+	Head   struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (p *giteaPullRequest) pullRequest() *PullRequest {
+	state := StateOpen
+	switch {
+	case p.Merged:
+		state = StateMerged
+	case p.State == "closed":
+		state = StateClosed
+	}
+	return &PullRequest{
+		Number: p.Number,
+		URL:    p.HTMLURL,
+		Title:  p.Title,
+		Body:   p.Body,
+		Head:   p.Head.Ref,
+		Base:   p.Base.Ref,
+		State:  state,
+	}
+}
+
+// endpoint returns the url of the pull requests API of the repository.
+func (g *Gitea) endpoint(suffix string) string {
+	return fmt.Sprintf("%s/api/v1/repos/%s/pulls%s", g.URL, g.Repository, suffix)
+}
+
+func (g *Gitea) do(ctx context.Context, method, u string, in interface{}) (*PullRequest, error) {
+	var pr giteaPullRequest
+	if err := do(ctx, g.Client, method, u, g.header(), in, &pr); err != nil {
+		return nil, err
+	}
+	return pr.pullRequest(), nil
+}
+
+func (g *Gitea) header() http.Header {
+	h := http.Header{}
+	if g.Token != "" {
+		h.Set("Authorization", "token "+g.Token)
+	}
+	return h
+}
+
+// FindOpen returns the open pull request from head into base, or nil.
+// Gitea can't filter pull requests by branch, open pull requests are
+// listed page by page.
+func (g *Gitea) FindOpen(ctx context.Context, head, base string) (*PullRequest, error) {
+	for page := 1; ; page++ {
+		query := url.Values{"state": {"open"}, "limit": {fmt.Sprint(giteaPageSize)}, "page": {fmt.Sprint(page)}}
+		var list []giteaPullRequest
+		if err := do(ctx, g.Client, http.MethodGet, g.endpoint("?"+query.Encode()), g.header(), nil, &list); err != nil {
+			return nil, err
+		}
+		for _, pr := range list {
+			if pr.Head.Ref == head && pr.Base.Ref == base {
+				return pr.pullRequest(), nil
+			}
+		}
+		if len(list) < giteaPageSize {
+			return nil, nil
+		}
+	}
+}
+
+// Get returns a pull request by number.
+func (g *Gitea) Get(ctx context.Context, number int) (*PullRequest, error) {
+	return g.do(ctx, http.MethodGet, g.endpoint(fmt.Sprintf("/%d", number)), nil)
+}
+
+// Create opens a pull request.
+func (g *Gitea) Create(ctx context.Context, pr PullRequest) (*PullRequest, error) {
+	return g.do(ctx, http.MethodPost, g.endpoint(""), map[string]string{
+		"head":  pr.Head,
+		"base":  pr.Base,
+		"title": pr.Title,
+		"body":  pr.Body,
+	})
+}
+
+// Edit sets the title and body of a pull request.
+func (g *Gitea) Edit(ctx context.Context, pr PullRequest) (*PullRequest, error) {
+	return g.do(ctx, http.MethodPatch, g.endpoint(fmt.Sprintf("/%d", pr.Number)), map[string]string{
+		"title": pr.Title,
+		"body":  pr.Body,
+	})
+}
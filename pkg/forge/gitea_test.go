@@ -0,0 +1,115 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitea serves the pull requests API of getais/homelab, listing pull
+// requests in pages of one.
+type fakeGitea struct {
+	mu    sync.Mutex
+	pulls []*giteaPullRequest
+	auth  string
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = r.Header.Get("Authorization")
+
+	const prefix = "/api/v1/repos/getais/homelab/pulls"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+
+	switch number := strings.TrimPrefix(r.URL.Path, prefix); {
+	case number == "" && r.Method == http.MethodGet:
+		var open []*giteaPullRequest
+		for _, pr := range f.pulls {
+			if pr.State == "open" {
+				open = append(open, pr)
+			}
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start, end := (page-1)*limit, page*limit
+		if start > len(open) {
+			start = len(open)
+		}
+		if end > len(open) {
+			end = len(open)
+		}
+		json.NewEncoder(w).Encode(open[start:end])
+	case number == "" && r.Method == http.MethodPost:
+		n := len(f.pulls) + 1
+		pr := &giteaPullRequest{
+			Number:  n,
+			HTMLURL: fmt.Sprintf("https://gitea.example.com/getais/homelab/pulls/%d", n),
+			Title:   body["title"],
+			Body:    body["body"],
+			State:   "open",
+		}
+		pr.Head.Ref, pr.Base.Ref = body["head"], body["base"]
+		f.pulls = append(f.pulls, pr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pr)
+	default:
+		var n int
+		fmt.Sscanf(number, "/%d", &n)
+		if n < 1 || n > len(f.pulls) {
+			http.NotFound(w, r)
+			return
+		}
+		pr := f.pulls[n-1]
+		if r.Method == http.MethodPatch {
+			pr.Title, pr.Body = body["title"], body["body"]
+		}
+		json.NewEncoder(w).Encode(pr)
+	}
+}
+
+func TestGitea(t *testing.T) {
+	fake := &fakeGitea{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	c, err := New(Config{Type: "gitea", URL: srv.URL, Repository: "getais/homelab", Token: "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conformance(t, c)
+	if fake.auth != "token s3cr3t" {
+		t.Errorf("Authorization = %q", fake.auth)
+	}
+	if len(fake.pulls) != 1 {
+		t.Errorf("%d pull requests opened, want 1", len(fake.pulls))
+	}
+}
+
+func TestGiteaFindOpenPages(t *testing.T) {
+	fake := &fakeGitea{}
+	for i := 1; i <= giteaPageSize+2; i++ {
+		pr := &giteaPullRequest{Number: i, State: "open"}
+		pr.Head.Ref, pr.Base.Ref = fmt.Sprintf("feature-%d", i), "main"
+		fake.pulls = append(fake.pulls, pr)
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	c, _ := New(Config{Type: "gitea", URL: srv.URL, Repository: "getais/homelab"})
+	pr, err := c.FindOpen(context.Background(), fmt.Sprintf("feature-%d", giteaPageSize+1), "main")
+	if err != nil || pr == nil || pr.Number != giteaPageSize+1 {
+		t.Errorf("FindOpen() = %+v, %v, want pull request %d", pr, err, giteaPageSize+1)
+	}
+}
@@ -0,0 +1,123 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// Github manages pull requests of a Github or Github Enterprise repository.
+type Github struct {
+	Client *github.Client
+	Owner  string
+	Repo   string
+}
+
+// NewGithub returns a client of the repository owner/name. Servers other
+// than github.com are Github Enterprise servers, reached at /api/v3.
+func NewGithub(server, repository, token string, httpClient *http.Client) (*Github, error) {
+	owner, repo, _ := strings.Cut(repository, "/")
+	if token != "" {
+		c := *httpClient
+		c.Transport = &tokenTransport{token: token, base: httpClient.Transport}
+		httpClient = &c
+	}
+
+	g := &Github{Client: github.NewClient(httpClient), Owner: owner, Repo: repo}
+	if server != "" && server != "https://github.com" {
+		client, err := github.NewEnterpriseClient(server+"/api/v3/", server+"/api/uploads/", httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Github Enterprise url %q: %w", server, err)
+		}
+		g.Client = client
+	}
+	return g, nil
+}
+
+// FindOpen returns the open pull request from head into base, or nil.
+func (g *Github) FindOpen(ctx context.Context, head, base string) (*PullRequest, error) {
+	opts := &github.PullRequestListOptions{State: "open", Head: g.Owner + ":" + head, Base: base}
+	list, _, err := g.Client.PullRequests.List(ctx, g.Owner, g.Repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range list {
+		if pr.GetHead().GetRef() == head {
+			return githubPullRequest(pr), nil
+		}
+	}
+	return nil, nil
+}
+
+// Get returns a pull request by number.
+func (g *Github) Get(ctx context.Context, number int) (*PullRequest, error) {
+	pr, _, err := g.Client.PullRequests.Get(ctx, g.Owner, g.Repo, number)
+	if err != nil {
+		return nil, err
+	}
+	return githubPullRequest(pr), nil
+}
+
+// Create opens a pull request.
+func (g *Github) Create(ctx context.Context, pr PullRequest) (*PullRequest, error) {
+	created, _, err := g.Client.PullRequests.Create(ctx, g.Owner, g.Repo, &github.NewPullRequest{
+		Title: &pr.Title,
+		Body:  &pr.Body,
+		Head:  &pr.Head,
+		Base:  &pr.Base,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return githubPullRequest(created), nil
+}
+
+// Edit sets the title and body of a pull request.
+func (g *Github) Edit(ctx context.Context, pr PullRequest) (*PullRequest, error) {
+	edited, _, err := g.Client.PullRequests.Edit(ctx, g.Owner, g.Repo, pr.Number, &github.PullRequest{
+		Title: &pr.Title,
+		Body:  &pr.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return githubPullRequest(edited), nil
+}
+
+func githubPullRequest(pr *github.PullRequest) *PullRequest {
+	state := StateOpen
+	switch {
+	case pr.GetMerged():
+		state = StateMerged
+	case pr.GetState() == "closed":
+		state = StateClosed
+	}
+	return &PullRequest{
+		Number: pr.GetNumber(),
+		URL:    pr.GetHTMLURL(),
+		Title:  pr.GetTitle(),
+		Body:   pr.GetBody(),
+		Head:   pr.GetHead().GetRef(),
+		Base:   pr.GetBase().GetRef(),
+		State:  state,
+	}
+}
+
+// tokenTransport authenticates requests with a token.
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+t.token)
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
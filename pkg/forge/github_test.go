@@ -0,0 +1,107 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGithub serves the pull requests API of getais/homelab.
+type fakeGithub struct {
+	mu    sync.Mutex
+	pulls []map[string]interface{}
+	auth  string
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = r.Header.Get("Authorization")
+
+	const prefix = "/api/v3/repos/getais/homelab/pulls"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	switch number := strings.TrimPrefix(r.URL.Path, prefix); {
+	case number == "" && r.Method == http.MethodGet:
+		list := []map[string]interface{}{}
+		for _, pr := range f.pulls {
+			head := "getais:" + pr["head"].(map[string]interface{})["ref"].(string)
+			if pr["state"] == r.URL.Query().Get("state") && head == r.URL.Query().Get("head") {
+				list = append(list, pr)
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	case number == "" && r.Method == http.MethodPost:
+		n := len(f.pulls) + 1
+		pr := map[string]interface{}{
+			"number":   n,
+			"html_url": fmt.Sprintf("https://github.example.com/getais/homelab/pull/%d", n),
+			"title":    body["title"],
+			"body":     body["body"],
+			"state":    "open",
+			"head":     map[string]interface{}{"ref": body["head"]},
+			"base":     map[string]interface{}{"ref": body["base"]},
+		}
+		f.pulls = append(f.pulls, pr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pr)
+	default:
+		var n int
+		fmt.Sscanf(number, "/%d", &n)
+		if n < 1 || n > len(f.pulls) {
+			http.NotFound(w, r)
+			return
+		}
+		pr := f.pulls[n-1]
+		if r.Method == http.MethodPatch {
+			pr["title"], pr["body"] = body["title"], body["body"]
+		}
+		json.NewEncoder(w).Encode(pr)
+	}
+}
+
+func TestGithub(t *testing.T) {
+	fake := &fakeGithub{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	c, err := New(Config{Type: "github", URL: srv.URL, Repository: "getais/homelab", Token: "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conformance(t, c)
+	if fake.auth != "token s3cr3t" {
+		t.Errorf("Authorization = %q", fake.auth)
+	}
+	if len(fake.pulls) != 1 {
+		t.Errorf("%d pull requests opened, want 1", len(fake.pulls))
+	}
+}
+
+func TestGithubMerged(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/getais/homelab/pulls/4", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number": 4, "state": "closed", "merged": true, "html_url": "https://github.example.com/getais/homelab/pull/4"}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	c, _ := New(Config{Type: "github", URL: srv.URL, Repository: "getais/homelab"})
+	pr, err := c.Get(context.Background(), 4)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if pr.State != StateMerged {
+		t.Errorf("Get() state = %s, want merged", pr.State)
+	}
+}
@@ -0,0 +1,105 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Gitlab manages merge requests of a Gitlab project.
+type Gitlab struct {
+	// URL of the Gitlab server.
+	URL string
+	// Project is the path of the project, e.g. getais/homelab.
+	Project string
+	Token   string
+	Client  *http.Client
+}
+
+type gitlabMergeRequest struct {
+	IID          int    `json:"iid"`
+	WebURL       string `json:"web_url"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	// State is one of opened, closed, locked or merged.
+	State string `json:"state"`
+}
+
+func (m *gitlabMergeRequest) pullRequest() *PullRequest {
+	state := StateOpen
+	switch m.State {
+	case "merged":
+		state = StateMerged
+	case "closed":
+		state = StateClosed
+	}
+	return &PullRequest{
+		Number: m.IID,
+		URL:    m.WebURL,
+		Title:  m.Title,
+		Body:   m.Description,
+		Head:   m.SourceBranch,
+		Base:   m.TargetBranch,
+		State:  state,
+	}
+}
+
+// endpoint returns the url of the merge requests API of the project.
+func (g *Gitlab) endpoint(suffix string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s/merge_requests%s", g.URL, url.PathEscape(g.Project), suffix)
+}
+
+func (g *Gitlab) do(ctx context.Context, method, u string, in interface{}) (*PullRequest, error) {
+	var mr gitlabMergeRequest
+	if err := do(ctx, g.Client, method, u, g.header(), in, &mr); err != nil {
+		return nil, err
+	}
+	return mr.pullRequest(), nil
+}
+
+func (g *Gitlab) header() http.Header {
+	h := http.Header{}
+	if g.Token != "" {
+		h.Set("Authorization", "Bearer "+g.Token)
+	}
+	return h
+}
+
+// FindOpen returns the open merge request from head into base, or nil.
+func (g *Gitlab) FindOpen(ctx context.Context, head, base string) (*PullRequest, error) {
+	query := url.Values{"state": {"opened"}, "source_branch": {head}, "target_branch": {base}}
+	var list []gitlabMergeRequest
+	if err := do(ctx, g.Client, http.MethodGet, g.endpoint("?"+query.Encode()), g.header(), nil, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[0].pullRequest(), nil
+}
+
+// Get returns a merge request by number.
+func (g *Gitlab) Get(ctx context.Context, number int) (*PullRequest, error) {
+	return g.do(ctx, http.MethodGet, g.endpoint(fmt.Sprintf("/%d", number)), nil)
+}
+
+// Create opens a merge request.
+func (g *Gitlab) Create(ctx context.Context, pr PullRequest) (*PullRequest, error) {
+	return g.do(ctx, http.MethodPost, g.endpoint(""), map[string]string{
+		"source_branch": pr.Head,
+		"target_branch": pr.Base,
+		"title":         pr.Title,
+		"description":   pr.Body,
+	})
+}
+
+// Edit sets the title and description of a merge request.
+func (g *Gitlab) Edit(ctx context.Context, pr PullRequest) (*PullRequest, error) {
+	return g.do(ctx, http.MethodPut, g.endpoint(fmt.Sprintf("/%d", pr.Number)), map[string]string{
+		"title":       pr.Title,
+		"description": pr.Body,
+	})
+}
@@ -0,0 +1,109 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitlab serves the merge requests API of the getais/homelab project.
+type fakeGitlab struct {
+	mu   sync.Mutex
+	mrs  []*gitlabMergeRequest
+	auth string
+}
+
+func (f *fakeGitlab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = r.Header.Get("Authorization")
+
+	// The project path must stay escaped
+	const prefix = "/api/v4/projects/getais%2Fhomelab/merge_requests"
+	if !strings.HasPrefix(r.URL.EscapedPath(), prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+
+	switch iid := strings.TrimPrefix(r.URL.EscapedPath(), prefix); {
+	case iid == "" && r.Method == http.MethodGet:
+		q := r.URL.Query()
+		list := []*gitlabMergeRequest{}
+		for _, mr := range f.mrs {
+			if mr.State == q.Get("state") && mr.SourceBranch == q.Get("source_branch") && mr.TargetBranch == q.Get("target_branch") {
+				list = append(list, mr)
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	case iid == "" && r.Method == http.MethodPost:
+		n := len(f.mrs) + 1
+		mr := &gitlabMergeRequest{
+			IID:          n,
+			WebURL:       fmt.Sprintf("https://gitlab.example.com/getais/homelab/-/merge_requests/%d", n),
+			Title:        body["title"],
+			Description:  body["description"],
+			SourceBranch: body["source_branch"],
+			TargetBranch: body["target_branch"],
+			State:        "opened",
+		}
+		f.mrs = append(f.mrs, mr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(mr)
+	default:
+		var n int
+		fmt.Sscanf(iid, "/%d", &n)
+		if n < 1 || n > len(f.mrs) {
+			http.NotFound(w, r)
+			return
+		}
+		mr := f.mrs[n-1]
+		if r.Method == http.MethodPut {
+			mr.Title, mr.Description = body["title"], body["description"]
+		}
+		json.NewEncoder(w).Encode(mr)
+	}
+}
+
+func TestGitlab(t *testing.T) {
+	fake := &fakeGitlab{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	c, err := New(Config{Type: "gitlab", URL: srv.URL, Repository: "getais/homelab", Token: "glpat-s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conformance(t, c)
+	if fake.auth != "Bearer glpat-s3cr3t" {
+		t.Errorf("Authorization = %q", fake.auth)
+	}
+	if len(fake.mrs) != 1 {
+		t.Errorf("%d merge requests opened, want 1", len(fake.mrs))
+	}
+
+	fake.mrs[0].State = "merged"
+	pr, err := c.Get(context.Background(), 1)
+	if err != nil || pr.State != StateMerged {
+		t.Errorf("Get() = %+v, %v, want merged", pr, err)
+	}
+}
+
+func TestGitlabError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, _ := New(Config{Type: "gitlab", URL: srv.URL, Repository: "getais/homelab"})
+	_, err := c.FindOpen(context.Background(), "kupdater/traefik", "main")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("FindOpen() = %v, want 401 error", err)
+	}
+}
@@ -0,0 +1,79 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Auth returns the authentication described by the data of a Secret:
+//
+//   - "username" and "password" for HTTP basic authentication
+//   - "token" for HTTP token authentication, e.g. a Github or Gitlab token
+//   - "identity" with a private SSH key, "known_hosts" with the host keys
+//     of the server and optionally "password" protecting the key
+//
+// No authentication is returned for empty credentials.
+func Auth(credentials map[string][]byte) (transport.AuthMethod, error) {
+	switch {
+	case len(credentials["identity"]) > 0:
+		keys, err := gitssh.NewPublicKeys("git", credentials["identity"], string(credentials["password"]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH identity: %w", err)
+		}
+		if len(credentials["known_hosts"]) == 0 {
+			return nil, fmt.Errorf("SSH authentication requires known_hosts")
+		}
+		keys.HostKeyCallback, err = knownHostsCallback(credentials["known_hosts"])
+		if err != nil {
+			return nil, err
+		}
+		return keys, nil
+	case len(credentials["token"]) > 0:
+		// Forges accept tokens as password of any user
+		return &http.BasicAuth{Username: "kupdater", Password: string(credentials["token"])}, nil
+	case len(credentials["username"]) > 0:
+		return &http.BasicAuth{Username: string(credentials["username"]), Password: string(credentials["password"])}, nil
+	}
+	return nil, nil
+}
+
+// knownHostsCallback accepts the host keys listed in known_hosts data.
+// Hashed host names aren't supported.
+func knownHostsCallback(data []byte) (ssh.HostKeyCallback, error) {
+	type entry struct {
+		hosts []string
+		key   ssh.PublicKey
+	}
+	var entries []entry
+	for len(bytes.TrimSpace(data)) > 0 {
+		_, hosts, key, _, rest, err := ssh.ParseKnownHosts(data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid known_hosts: %w", err)
+		}
+		entries = append(entries, entry{hosts: hosts, key: key})
+		data = rest
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		host := knownhosts.Normalize(hostname)
+		for _, e := range entries {
+			for _, h := range e.hosts {
+				if knownhosts.Normalize(h) == host && bytes.Equal(e.key.Marshal(), key.Marshal()) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("host key of %s is not in known_hosts", hostname)
+	}, nil
+}
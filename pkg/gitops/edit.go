@@ -0,0 +1,174 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the files versions are written to.
+const (
+	// HelmValues sets the value at a dotted path of a Helm values file,
+	// e.g. "image.tag".
+	HelmValues = "helm-values"
+	// ArgoApplication sets the targetRevision of an Argo CD Application
+	// manifest, at spec.source.targetRevision unless another path is given.
+	ArgoApplication = "argocd"
+	// Kustomize sets the newTag of the image with the given name in the
+	// images of a kustomization.
+	Kustomize = "kustomize"
+)
+
+// defaultArgoPath is where Argo CD Applications declare the chart version.
+const defaultArgoPath = "spec.source.targetRevision"
+
+// Edit describes a version to write to a file.
+type Edit struct {
+	// Path of the file in the repository.
+	Path string
+	// Format of the file, one of HelmValues, ArgoApplication or Kustomize.
+	Format string
+	// Key locates the version in the file: a dotted path for HelmValues
+	// and ArgoApplication, an image name for Kustomize.
+	Key string
+	// Version to write.
+	Version string
+}
+
+// Apply writes the version into the content of the file. Only the version
+// is replaced, comments and formatting of the file are kept.
+func (e Edit) Apply(content []byte) ([]byte, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(content, doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", e.Path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%s: empty document", e.Path)
+	}
+	root := doc.Content[0]
+
+	var node *yaml.Node
+	switch e.Format {
+	case HelmValues:
+		if e.Key == "" {
+			return nil, fmt.Errorf("%s: no values path configured", e.Path)
+		}
+		node = lookup(root, strings.Split(e.Key, "."))
+	case ArgoApplication:
+		path := e.Key
+		if path == "" {
+			path = defaultArgoPath
+		}
+		node = lookup(root, strings.Split(path, "."))
+	case Kustomize:
+		node = kustomizeTag(root, e.Key)
+	default:
+		return nil, fmt.Errorf("unsupported file format %q, expected one of: %s, %s, %s", e.Format, HelmValues, ArgoApplication, Kustomize)
+	}
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("%s: no %s value %q found", e.Path, e.Format, e.Key)
+	}
+	return replaceScalar(content, node, e.Version)
+}
+
+// lookup follows the keys of nested mappings.
+func lookup(node *yaml.Node, path []string) *yaml.Node {
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		node = mappingValue(node, key)
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// mappingValue returns the value of a key of a mapping node.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// kustomizeTag returns the newTag of an image of a kustomization. Images
+// without a newTag yet can't be edited without reformatting the file.
+func kustomizeTag(root *yaml.Node, image string) *yaml.Node {
+	images := lookup(root, []string{"images"})
+	if images == nil || images.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, item := range images.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		if name := mappingValue(item, "name"); name != nil && name.Value == image {
+			return mappingValue(item, "newTag")
+		}
+	}
+	return nil
+}
+
+// replaceScalar replaces the text of a scalar node in the original content,
+// keeping its quoting style.
+func replaceScalar(content []byte, node *yaml.Node, value string) ([]byte, error) {
+	var old, replacement string
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		old, replacement = `"`+node.Value+`"`, `"`+value+`"`
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		old, replacement = `'`+node.Value+`'`, `'`+value+`'`
+	case node.Style == 0:
+		old, replacement = node.Value, value
+		// Versions such as 1.10 would be read back as numbers, also when
+		// the version they replace was one, e.g. 1.9
+		if needsQuoting(value) {
+			replacement = `"` + value + `"`
+		}
+	default:
+		return nil, fmt.Errorf("can't replace %s value at line %d", styleName(node.Style), node.Line)
+	}
+
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if node.Line < 1 || node.Line > len(lines) {
+		return nil, fmt.Errorf("value %q not found at line %d", node.Value, node.Line)
+	}
+	line := lines[node.Line-1]
+	start := node.Column - 1
+	if start < 0 || !bytes.HasPrefix(line[start:], []byte(old)) {
+		return nil, fmt.Errorf("value %q not found at line %d", node.Value, node.Line)
+	}
+
+	edited := make([]byte, 0, len(line)+len(replacement)-len(old))
+	edited = append(edited, line[:start]...)
+	edited = append(edited, replacement...)
+	edited = append(edited, line[start+len(old):]...)
+	lines[node.Line-1] = edited
+	return bytes.Join(lines, nil), nil
+}
+
+// needsQuoting reports whether a plain scalar would be read as something
+// else than a string.
+func needsQuoting(value string) bool {
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(value), node); err != nil || len(node.Content) == 0 {
+		return true
+	}
+	return node.Content[0].Tag != "!!str"
+}
+
+func styleName(style yaml.Style) string {
+	switch {
+	case style&yaml.LiteralStyle != 0:
+		return "literal"
+	case style&yaml.FoldedStyle != 0:
+		return "folded"
+	}
+	return "tagged"
+}
@@ -0,0 +1,128 @@
+package gitops
+
+import "testing"
+
+func TestEditApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    Edit
+		content string
+		want    string
+	}{
+		{
+			name: "helm values keep comments",
+			edit: Edit{Format: HelmValues, Key: "image.tag", Version: "3.0.10.1567-ls161"},
+			content: `# Sonarr
+image:
+  repository: lscr.io/linuxserver/sonarr
+  tag: 3.0.9.1549-ls160 # pinned
+service:
+  port: 8989
+`,
+			want: `# Sonarr
+image:
+  repository: lscr.io/linuxserver/sonarr
+  tag: 3.0.10.1567-ls161 # pinned
+service:
+  port: 8989
+`,
+		},
+		{
+			name:    "helm values quoted",
+			edit:    Edit{Format: HelmValues, Key: "traefik.version", Version: "17.1.0"},
+			content: "traefik:\n  version: \"17.0.5\"\n",
+			want:    "traefik:\n  version: \"17.1.0\"\n",
+		},
+		{
+			name:    "plain string which would become a number",
+			edit:    Edit{Format: HelmValues, Key: "tag", Version: "2022.10"},
+			content: "tag: v2022.09\n",
+			want:    "tag: \"2022.10\"\n",
+		},
+		{
+			name:    "plain number replaced by a version which would lose its zero",
+			edit:    Edit{Format: HelmValues, Key: "image.tag", Version: "1.10"},
+			content: "image:\n  tag: 1.9\n",
+			want:    "image:\n  tag: \"1.10\"\n",
+		},
+		{
+			name:    "plain number replaced by a string",
+			edit:    Edit{Format: HelmValues, Key: "image.tag", Version: "1.10.1"},
+			content: "image:\n  tag: 1.9\n",
+			want:    "image:\n  tag: 1.10.1\n",
+		},
+		{
+			name: "argo application",
+			edit: Edit{Format: ArgoApplication, Version: "41.5.1"},
+			content: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: prometheus
+spec:
+  source:
+    chart: kube-prometheus-stack
+    repoURL: https://prometheus-community.github.io/helm-charts
+    targetRevision: 34.10.0
+`,
+			want: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: prometheus
+spec:
+  source:
+    chart: kube-prometheus-stack
+    repoURL: https://prometheus-community.github.io/helm-charts
+    targetRevision: 41.5.1
+`,
+		},
+		{
+			name: "kustomize image",
+			edit: Edit{Format: Kustomize, Key: "traefik", Version: "v2.9.4"},
+			content: `resources:
+  - deployment.yaml
+images:
+  - name: nginx
+    newTag: 1.23.2
+  - name: traefik
+    newName: docker.io/library/traefik
+    newTag: 'v2.9.1'
+`,
+			want: `resources:
+  - deployment.yaml
+images:
+  - name: nginx
+    newTag: 1.23.2
+  - name: traefik
+    newName: docker.io/library/traefik
+    newTag: 'v2.9.4'
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.edit.Apply([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("Apply() = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Apply() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditApplyMissing(t *testing.T) {
+	tests := map[string]Edit{
+		"missing key":        {Format: HelmValues, Key: "image.digest", Version: "1.0.0"},
+		"missing image":      {Format: Kustomize, Key: "redis", Version: "7.0.5"},
+		"not a scalar":       {Format: HelmValues, Key: "image", Version: "1.0.0"},
+		"unknown format":     {Format: "jsonnet", Key: "image.tag", Version: "1.0.0"},
+		"no path for values": {Format: HelmValues, Version: "1.0.0"},
+	}
+	content := []byte("image:\n  tag: 1.0.0\nimages:\n  - name: nginx\n    newTag: 1.23.2\n")
+	for name, e := range tests {
+		if _, err := e.Apply(content); err == nil {
+			t.Errorf("%s: Apply() succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package gitops writes versions back to the Git repositories applications
+// are deployed from.
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Repository is a Git repository versions are written to.
+type Repository struct {
+	// URL of the repository.
+	URL string
+	// Branch changes are based on, defaults to the default branch of the
+	// repository.
+	Branch string
+	// Auth authenticates clones and pushes, see Auth.
+	Auth transport.AuthMethod
+	// Author of the commits.
+	Author object.Signature
+}
+
+// Change is a commit writing versions to files.
+type Change struct {
+	// Branch the commit is pushed to, defaults to the base branch.
+	// Branches other than the base branch are recreated from the base
+	// branch on every change, and force pushed.
+	Branch string
+	// Message of the commit.
+	Message string
+	Edits   []Edit
+}
+
+// Result describes a pushed change.
+type Result struct {
+	// Branch the change was pushed to.
+	Branch string
+	// Base is the branch the change was based on.
+	Base string
+	// Commit is the hash of the pushed commit, empty when the files
+	// already had the versions.
+	Commit string
+}
+
+// ErrNoChanges is returned when the files already have the versions.
+var ErrNoChanges = errors.New("files already have the versions")
+
+// Push clones the repository, applies the edits on the change branch,
+// commits and pushes them. The clone is kept in memory.
+func (r *Repository) Push(ctx context.Context, change Change) (Result, error) {
+	opts := &git.CloneOptions{URL: r.URL, Auth: r.Auth}
+	if r.Branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(r.Branch)
+		opts.SingleBranch = true
+	}
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), memfs.New(), opts)
+	if err != nil {
+		return Result{}, fmt.Errorf("cloning %s: %w", r.URL, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Base: head.Name().Short(), Branch: change.Branch}
+	if res.Branch == "" {
+		res.Branch = res.Base
+	}
+
+	tree, err := repo.Worktree()
+	if err != nil {
+		return Result{}, err
+	}
+	if res.Branch != res.Base {
+		err := tree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(res.Branch), Create: true})
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	changed := false
+	for _, e := range change.Edits {
+		edited, err := editFile(tree.Filesystem, e)
+		if err != nil {
+			return Result{}, err
+		}
+		if !edited {
+			continue
+		}
+		if _, err := tree.Add(e.Path); err != nil {
+			return Result{}, err
+		}
+		changed = true
+	}
+	if !changed {
+		return res, ErrNoChanges
+	}
+
+	author := r.Author
+	author.When = time.Now()
+	commit, err := tree.Commit(change.Message, &git.CommitOptions{Author: &author})
+	if err != nil {
+		return Result{}, err
+	}
+	res.Commit = commit.String()
+
+	refspec := config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%[1]s", res.Branch))
+	if res.Branch != res.Base {
+		refspec = "+" + refspec
+	}
+	err = repo.PushContext(ctx, &git.PushOptions{Auth: r.Auth, RefSpecs: []config.RefSpec{refspec}})
+	// Pushing the same change again within a second yields the same commit
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return Result{}, fmt.Errorf("pushing to %s: %w", r.URL, err)
+	}
+	return res, nil
+}
+
+// editFile applies an edit to a file of the worktree, reporting whether it
+// changed.
+func editFile(fs billy.Filesystem, e Edit) (bool, error) {
+	f, err := fs.Open(e.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, fmt.Errorf("%s doesn't exist", e.Path)
+		}
+		return false, err
+	}
+	content, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return false, err
+	}
+
+	edited, err := e.Apply(content)
+	if err != nil {
+		return false, err
+	}
+	if string(edited) == string(content) {
+		return false, nil
+	}
+
+	f, err = fs.OpenFile(e.Path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	_, err = f.Write(edited)
+	return err == nil, err
+}
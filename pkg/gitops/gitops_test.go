@@ -0,0 +1,145 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func init() {
+	// Serve local repositories in process rather than through git binaries
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+const values = "image:\n  repository: traefik\n  tag: v2.9.1\n"
+
+// bareRepository returns the url of a bare repository with a values file
+// committed on main.
+func bareRepository(t *testing.T) string {
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, _ := repo.Worktree()
+	f, _ := tree.Filesystem.Create("apps/traefik/values.yaml")
+	f.Write([]byte(values))
+	f.Close()
+	tree.Add("apps/traefik/values.yaml")
+	_, err = tree.Commit("Add traefik", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := "file://" + dir
+	repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}})
+	err = repo.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/main"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Point HEAD of the bare repository to main
+	bare, _ := git.PlainOpen(dir)
+	bare.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main")))
+	return url
+}
+
+// readFile returns a file of a branch of the repository.
+func readFile(t *testing.T, url, branch, path string) (string, *object.Commit) {
+	repo, err := git.Clone(memory.NewStorage(), memfs.New(), &git.CloneOptions{URL: url, ReferenceName: plumbing.NewBranchReferenceName(branch)})
+	if err != nil {
+		t.Fatalf("cloning %s: %v", branch, err)
+	}
+	tree, _ := repo.Worktree()
+	f, err := tree.Filesystem.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content, _ := ioutil.ReadAll(f)
+
+	head, _ := repo.Head()
+	commit, _ := repo.CommitObject(head.Hash())
+	return string(content), commit
+}
+
+func TestPush(t *testing.T) {
+	url := bareRepository(t)
+	repo := &Repository{URL: url, Author: object.Signature{Name: "kupdater", Email: "kupdater@example.com"}}
+	change := Change{
+		Message: "Update traefik to v2.9.4",
+		Edits:   []Edit{{Path: "apps/traefik/values.yaml", Format: HelmValues, Key: "image.tag", Version: "v2.9.4"}},
+	}
+
+	t.Run("base branch", func(t *testing.T) {
+		res, err := repo.Push(context.Background(), change)
+		if err != nil {
+			t.Fatalf("Push() = %v", err)
+		}
+		if res.Branch != "main" || res.Base != "main" || res.Commit == "" {
+			t.Errorf("Push() = %+v", res)
+		}
+
+		content, commit := readFile(t, url, "main", "apps/traefik/values.yaml")
+		if want := "image:\n  repository: traefik\n  tag: v2.9.4\n"; content != want {
+			t.Errorf("values = %q, want %q", content, want)
+		}
+		if commit.Hash.String() != res.Commit || commit.Message != change.Message || commit.Author.Name != "kupdater" {
+			t.Errorf("commit = %s %q by %s", commit.Hash, commit.Message, commit.Author.Name)
+		}
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		if _, err := repo.Push(context.Background(), change); !errors.Is(err, ErrNoChanges) {
+			t.Errorf("Push() = %v, want ErrNoChanges", err)
+		}
+	})
+
+	t.Run("change branch is recreated", func(t *testing.T) {
+		for _, version := range []string{"v2.9.5", "v2.9.6"} {
+			change := Change{
+				Branch:  "kupdater/traefik",
+				Message: "Update traefik to " + version,
+				Edits:   []Edit{{Path: "apps/traefik/values.yaml", Format: HelmValues, Key: "image.tag", Version: version}},
+			}
+			if _, err := repo.Push(context.Background(), change); err != nil {
+				t.Fatalf("Push(%s) = %v", version, err)
+			}
+		}
+
+		content, commit := readFile(t, url, "kupdater/traefik", "apps/traefik/values.yaml")
+		if want := "image:\n  repository: traefik\n  tag: v2.9.6\n"; content != want {
+			t.Errorf("values = %q, want %q", content, want)
+		}
+		// The branch holds a single commit on top of main
+		parent, err := commit.Parent(0)
+		if err != nil || parent.Message != change.Message {
+			t.Errorf("parent of %q is %v, want the commit on main", commit.Message, parent)
+		}
+
+		if content, _ := readFile(t, url, "main", "apps/traefik/values.yaml"); content != "image:\n  repository: traefik\n  tag: v2.9.4\n" {
+			t.Errorf("main was changed: %q", content)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		change := Change{Message: "Update", Edits: []Edit{{Path: "apps/missing.yaml", Format: HelmValues, Key: "tag", Version: "1.0.0"}}}
+		if _, err := repo.Push(context.Background(), change); err == nil {
+			t.Error("Push() succeeded")
+		}
+	})
+}
@@ -34,11 +34,13 @@ type HelmRepo struct {
 }
 
 type HelmEntry struct {
-	Created     time.Time
-	Name        string
-	Description string
-	Digest      string
-	Version     string
+	Created     time.Time `yaml:"created"`
+	Name        string    `yaml:"name"`
+	Description string    `yaml:"description"`
+	Digest      string    `yaml:"digest"`
+	Version     string    `yaml:"version"`
+	AppVersion  string    `yaml:"appVersion"`
+	Deprecated  bool      `yaml:"deprecated"`
 }
 
 func (c *Helm) DoRequest(Req Request) (ResponseData []byte, StatusCode int, err error) {
@@ -50,7 +52,7 @@ func (c *Helm) DoRequest(Req Request) (ResponseData []byte, StatusCode int, err
 		},
 	}
 
-	apiurl := fmt.Sprintf("%s/index.yaml", c.Request.RepoUrl)
+	apiurl := fmt.Sprintf("%s/index.yaml", strings.TrimSuffix(c.Request.RepoUrl, "/"))
 	_, err = url.ParseRequestURI(apiurl)
 	if err != nil {
 		return nil, 0, errors.New("Invalid Helm repo url")
@@ -78,11 +80,17 @@ func (c *Helm) GetReleases(RepoUrl string) (HelmRepo, error) {
 	var ResponseObject HelmRepo
 
 	data, code, err := c.DoRequest(c.Request)
-	if code != http.StatusOK {
+	if err != nil {
 		return ResponseObject, err
 	}
+	if code != http.StatusOK {
+		return ResponseObject, fmt.Errorf("Helm repo %s responded with status %d", RepoUrl, code)
+	}
 
-	yaml.Unmarshal(data, &ResponseObject)
+	err = yaml.Unmarshal(data, &ResponseObject)
+	if err != nil {
+		return ResponseObject, fmt.Errorf("Invalid Helm repo index: %w", err)
+	}
 
 	return ResponseObject, nil
 }
@@ -0,0 +1,342 @@
+// Package registry is a minimal client for the Docker Registry HTTP API v2,
+// as implemented by Docker Hub, GHCR, Quay and most private registries.
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	dockerHub         = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+// Reference points to a repository inside a registry.
+type Reference struct {
+	// Scheme used to talk to the registry, https unless given explicitly.
+	Scheme string
+	// Registry host, optionally with port, e.g. ghcr.io or localhost:5000.
+	Registry string
+	// Repository path inside the registry, e.g. library/nginx.
+	Repository string
+}
+
+// String returns the reference in the usual image notation.
+func (r Reference) String() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// ParseReference parses an image reference such as nginx,
+// ghcr.io/getais/kupdater:v0.1.0 or oci://registry-1.docker.io/bitnamicharts.
+// Tags and digests are ignored.
+func ParseReference(ref string) (Reference, error) {
+	r := Reference{Scheme: "https"}
+
+	for _, scheme := range []string{"oci", "https", "http"} {
+		if strings.HasPrefix(ref, scheme+"://") {
+			ref = strings.TrimPrefix(ref, scheme+"://")
+			if scheme == "http" {
+				r.Scheme = scheme
+			}
+			break
+		}
+	}
+
+	repo, _ := SplitImage(strings.Trim(ref, "/"))
+	if repo == "" {
+		return r, fmt.Errorf("invalid image reference %q", ref)
+	}
+
+	// The first path component is a registry host if it looks like one,
+	// otherwise the image lives on Docker Hub.
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		r.Registry, r.Repository = parts[0], parts[1]
+	} else {
+		r.Registry, r.Repository = dockerHub, repo
+	}
+
+	if r.Registry == dockerHub || r.Registry == "index.docker.io" {
+		r.Registry = dockerHub
+		if !strings.Contains(r.Repository, "/") {
+			r.Repository = "library/" + r.Repository
+		}
+	}
+
+	if !validRepository.MatchString(r.Repository) {
+		return r, fmt.Errorf("invalid image repository %q", r.Repository)
+	}
+	return r, nil
+}
+
+var validRepository = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+
+// SplitImage splits a container image into repository and tag. The tag is
+// empty if the image has none or is pinned by digest only.
+func SplitImage(image string) (repository, tag string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	// A colon after the last slash separates the tag, any other colon
+	// belongs to a registry port.
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+// Client lists tags of repositories in a registry.
+type Client struct {
+	HTTPClient *http.Client
+	// Username and Password are used for registries requiring
+	// authentication. Anonymous access is used when empty.
+	Username string
+	Password string
+}
+
+// NewClient returns a Client authenticating with the given credentials.
+func NewClient(username, password string) *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Username:   username,
+		Password:   password,
+	}
+}
+
+type tagList struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// ListTags returns all tags of a repository, following pagination.
+func (c *Client) ListTags(ctx context.Context, ref Reference) ([]string, error) {
+	base := &url.URL{Scheme: ref.Scheme, Host: apiHost(ref.Registry)}
+	next := base.ResolveReference(&url.URL{Path: fmt.Sprintf("/v2/%s/tags/list", ref.Repository)})
+
+	var tags []string
+	var token string
+	for next != nil {
+		resp, err := c.get(ctx, next.String(), token)
+		if err != nil {
+			return nil, err
+		}
+
+		// Registries tell us how to authenticate on the first request
+		if resp.StatusCode == http.StatusUnauthorized && token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			token, err = c.authenticate(ctx, challenge, ref)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("registry %s responded with status %d listing tags of %s", ref.Registry, resp.StatusCode, ref.Repository)
+		}
+
+		var page tagList
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag list from %s: %w", ref.Registry, err)
+		}
+		tags = append(tags, page.Tags...)
+
+		next, err = nextPage(base, resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+func (c *Client) get(ctx context.Context, u string, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(token, "Basic "):
+		req.Header.Set("Authorization", token)
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+// authenticate answers a WWW-Authenticate challenge, returning either a
+// bearer token or a basic authorization header.
+func (c *Client) authenticate(ctx context.Context, challenge string, ref Reference) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.Username == "" {
+			return "", fmt.Errorf("registry %s requires credentials", ref.Registry)
+		}
+		return "Basic " + basicAuth(c.Username, c.Password), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("registry %s requested unsupported authentication %q", ref.Registry, challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("registry %s sent invalid token realm %q", ref.Registry, params["realm"])
+	}
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", ref.Repository)
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("registry %s token request failed with status %d: %s", ref.Registry, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", fmt.Errorf("invalid token response from %s: %w", realm.Host, err)
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	if t.Token == "" {
+		return "", errors.New("registry token response contained no token")
+	}
+	return t.Token, nil
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// parseChallenge parses a header like
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(rest, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	return scheme, params
+}
+
+// nextPage returns the url of the next page from a Link header such as
+// </v2/library/nginx/tags/list?last=1.23&n=100>; rel="next"
+func nextPage(base *url.URL, link string) (*url.URL, error) {
+	if link == "" {
+		return nil, nil
+	}
+	for _, l := range strings.Split(link, ",") {
+		target, params, _ := strings.Cut(l, ";")
+		if !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Link header %q", link)
+		}
+		return base.ResolveReference(u), nil
+	}
+	return nil, nil
+}
+
+func apiHost(registry string) string {
+	if registry == dockerHub {
+		return dockerHubRegistry
+	}
+	return registry
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+type dockerAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+// DockerConfigCredentials looks up the credentials for a registry in the
+// content of a .dockerconfigjson image pull secret.
+func DockerConfigCredentials(data []byte, registry string) (username, password string, err error) {
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", "", fmt.Errorf("invalid docker config: %w", err)
+	}
+	return registryCredentials(config.Auths, registry)
+}
+
+// DockerCfgCredentials looks up the credentials for a registry in the
+// content of a legacy .dockercfg image pull secret, which holds the auths
+// of a docker config without wrapping them.
+func DockerCfgCredentials(data []byte, registry string) (username, password string, err error) {
+	var auths map[string]dockerAuth
+	if err := json.Unmarshal(data, &auths); err != nil {
+		return "", "", fmt.Errorf("invalid docker config: %w", err)
+	}
+	return registryCredentials(auths, registry)
+}
+
+func registryCredentials(auths map[string]dockerAuth, registry string) (username, password string, err error) {
+	for host, auth := range auths {
+		if configHost(host) != registry && !(registry == dockerHub && configHost(host) == "index.docker.io") {
+			continue
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid auth for %s in docker config", host)
+			}
+			username, password, _ = strings.Cut(string(decoded), ":")
+			return username, password, nil
+		}
+		return auth.Username, auth.Password, nil
+	}
+	return "", "", nil
+}
+
+// configHost strips scheme and path from a docker config key such as
+// https://index.docker.io/v1/
+func configHost(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ := strings.Cut(key, "/")
+	return host
+}
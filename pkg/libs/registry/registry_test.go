@@ -0,0 +1,215 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref                  string
+		registry, repository string
+	}{
+		{ref: "nginx", registry: "docker.io", repository: "library/nginx"},
+		{ref: "nginx:1.23", registry: "docker.io", repository: "library/nginx"},
+		{ref: "linuxserver/sonarr:3.0.9.1549-ls160", registry: "docker.io", repository: "linuxserver/sonarr"},
+		{ref: "ghcr.io/getais/kupdater/operator:v0.1.0", registry: "ghcr.io", repository: "getais/kupdater/operator"},
+		{ref: "localhost:5000/app@sha256:abc", registry: "localhost:5000", repository: "app"},
+		{ref: "oci://registry-1.docker.io/bitnamicharts/nginx", registry: "registry-1.docker.io", repository: "bitnamicharts/nginx"},
+	}
+	for _, tt := range tests {
+		r, err := ParseReference(tt.ref)
+		if err != nil {
+			t.Errorf("ParseReference(%q) = %v", tt.ref, err)
+			continue
+		}
+		if r.Registry != tt.registry || r.Repository != tt.repository {
+			t.Errorf("ParseReference(%q) = %s/%s, want %s/%s", tt.ref, r.Registry, r.Repository, tt.registry, tt.repository)
+		}
+	}
+
+	if _, err := ParseReference("Not An Image"); err == nil {
+		t.Error("ParseReference() accepted an invalid reference")
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct{ image, repository, tag string }{
+		{"nginx", "nginx", ""},
+		{"nginx:1.23", "nginx", "1.23"},
+		{"localhost:5000/app", "localhost:5000/app", ""},
+		{"localhost:5000/app:v1@sha256:abc", "localhost:5000/app", "v1"},
+	}
+	for _, tt := range tests {
+		repository, tag := SplitImage(tt.image)
+		if repository != tt.repository || tag != tt.tag {
+			t.Errorf("SplitImage(%q) = %q, %q, want %q, %q", tt.image, repository, tag, tt.repository, tt.tag)
+		}
+	}
+}
+
+// newRegistry starts a registry serving tags two per page, following the
+// Link header of the previous page. auth answers unauthenticated requests
+// with a challenge and returns whether the Authorization header is valid.
+func newRegistry(t *testing.T, repository string, tags []string, auth func(w http.ResponseWriter, r *http.Request) bool) (*httptest.Server, Reference) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/"+repository+"/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if !auth(w, r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		start := 0
+		if last := r.URL.Query().Get("last"); last != "" {
+			for i, tag := range tags {
+				if tag == last {
+					start = i + 1
+				}
+			}
+		}
+		end := start + 2
+		if end < len(tags) {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?last=%s&n=2>; rel="next"`, repository, tags[end-1]))
+		} else {
+			end = len(tags)
+		}
+		json.NewEncoder(w).Encode(tagList{Name: repository, Tags: tags[start:end]})
+	})
+	var srv *httptest.Server
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("service") != "registry.test" || q.Get("scope") != "repository:"+repository+":pull" {
+			t.Errorf("token requested for service %q and scope %q", q.Get("service"), q.Get("scope"))
+		}
+		if user, password, ok := r.BasicAuth(); ok && (user != "robot" || password != "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"details": "invalid credentials"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "pull-token"}`)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	return srv, Reference{Scheme: "http", Registry: u.Host, Repository: repository}
+}
+
+func TestListTagsBearer(t *testing.T) {
+	var srv *httptest.Server
+	srv, ref := newRegistry(t, "getais/kupdater", []string{"v0.1.0", "v0.2.0", "v0.3.0", "v0.4.0", "v0.5.0"}, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") == "Bearer pull-token" {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test"`, srv.URL))
+		return false
+	})
+
+	tags, err := NewClient("", "").ListTags(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("ListTags() = %v", err)
+	}
+	if strings.Join(tags, ",") != "v0.1.0,v0.2.0,v0.3.0,v0.4.0,v0.5.0" {
+		t.Errorf("ListTags() = %v, want the tags of all pages", tags)
+	}
+
+	_, err = NewClient("robot", "wrong").ListTags(context.Background(), ref)
+	if err == nil || !strings.Contains(err.Error(), "invalid credentials") {
+		t.Errorf("ListTags() with wrong credentials = %v, want token request failure", err)
+	}
+}
+
+func TestListTagsBasic(t *testing.T) {
+	_, ref := newRegistry(t, "internal/app", []string{"1.0.0"}, func(w http.ResponseWriter, r *http.Request) bool {
+		if user, password, ok := r.BasicAuth(); ok && user == "robot" && password == "secret" {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+		return false
+	})
+
+	tags, err := NewClient("robot", "secret").ListTags(context.Background(), ref)
+	if err != nil || len(tags) != 1 || tags[0] != "1.0.0" {
+		t.Errorf("ListTags() = %v, %v, want [1.0.0]", tags, err)
+	}
+	if _, err := NewClient("", "").ListTags(context.Background(), ref); err == nil || !strings.Contains(err.Error(), "requires credentials") {
+		t.Errorf("ListTags() without credentials = %v, want requires credentials", err)
+	}
+}
+
+func TestListTagsUnsupportedChallenge(t *testing.T) {
+	_, ref := newRegistry(t, "internal/app", nil, func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("WWW-Authenticate", `Negotiate`)
+		return false
+	})
+	if _, err := NewClient("robot", "secret").ListTags(context.Background(), ref); err == nil || !strings.Contains(err.Error(), "unsupported authentication") {
+		t.Errorf("ListTags() = %v, want unsupported authentication", err)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	if scheme != "Bearer" || params["realm"] != "https://auth.docker.io/token" ||
+		params["service"] != "registry.docker.io" || params["scope"] != "repository:library/nginx:pull" {
+		t.Errorf("parseChallenge() = %s, %v", scheme, params)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	base, _ := url.Parse("https://ghcr.io")
+	tests := []struct{ link, want string }{
+		{``, ``},
+		{`</v2/getais/kupdater/tags/list?last=v0.2.0&n=2>; rel="next"`, `https://ghcr.io/v2/getais/kupdater/tags/list?last=v0.2.0&n=2`},
+		{`<https://mirror.test/v2/app/tags/list?last=b>; rel="next"`, `https://mirror.test/v2/app/tags/list?last=b`},
+		{`</v2/app/tags/list?last=a>; rel="prev", </v2/app/tags/list?last=c>; rel="next"`, `https://ghcr.io/v2/app/tags/list?last=c`},
+		{`</v2/app/tags/list?last=a>; rel="prev"`, ``},
+	}
+	for _, tt := range tests {
+		next, err := nextPage(base, tt.link)
+		if err != nil {
+			t.Errorf("nextPage(%q) = %v", tt.link, err)
+			continue
+		}
+		got := ""
+		if next != nil {
+			got = next.String()
+		}
+		if got != tt.want {
+			t.Errorf("nextPage(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	auth := "cm9ib3Q6c2VjcmV0" // robot:secret
+	config := `{"auths": {"https://index.docker.io/v1/": {"auth": "` + auth + `"}, "ghcr.io": {"username": "bot", "password": "token"}}}`
+	legacy := `{"https://index.docker.io/v1/": {"auth": "` + auth + `"}, "ghcr.io": {"username": "bot", "password": "token"}}`
+
+	tests := []struct {
+		parse                  func([]byte, string) (string, string, error)
+		data, registry         string
+		wantUser, wantPassword string
+	}{
+		{DockerConfigCredentials, config, dockerHub, "robot", "secret"},
+		{DockerConfigCredentials, config, "ghcr.io", "bot", "token"},
+		{DockerConfigCredentials, config, "quay.io", "", ""},
+		{DockerCfgCredentials, legacy, dockerHub, "robot", "secret"},
+		{DockerCfgCredentials, legacy, "ghcr.io", "bot", "token"},
+		{DockerCfgCredentials, legacy, "quay.io", "", ""},
+	}
+	for _, tt := range tests {
+		username, password, err := tt.parse([]byte(tt.data), tt.registry)
+		if err != nil || username != tt.wantUser || password != tt.wantPassword {
+			t.Errorf("credentials of %s in %s = %q, %q, %v, want %q, %q", tt.registry, tt.data, username, password, err, tt.wantUser, tt.wantPassword)
+		}
+	}
+	if _, _, err := DockerCfgCredentials([]byte(`{"ghcr.io": {"auth": "%%"}}`), "ghcr.io"); err == nil {
+		t.Errorf("DockerCfgCredentials() of an invalid auth succeeded")
+	}
+}
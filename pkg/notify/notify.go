@@ -0,0 +1,169 @@
+// Package notify announces Updates which became outdated, or up to date
+// again, to chat and webhook sinks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Source is the state of a single source of an Update.
+type Source struct {
+	Name           string     `json:"name"`
+	Type           string     `json:"type"`
+	Current        string     `json:"current"`
+	Latest         string     `json:"latest"`
+	LatestInPolicy string     `json:"latestInPolicy"`
+	Outdated       bool       `json:"outdated"`
+	ReleaseDate    *time.Time `json:"releaseDate,omitempty"`
+}
+
+// Event describes an Update whose UpToDate condition changed.
+type Event struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Outdated is set when the Update has newer versions available, and
+	// cleared when it became up to date again.
+	Outdated bool     `json:"outdated"`
+	Sources  []Source `json:"sources"`
+	// Message is the message of the UpToDate condition.
+	Message string `json:"message"`
+}
+
+// Digest summarises the Updates which became outdated since the last one.
+type Digest struct {
+	Updates []Event `json:"updates"`
+}
+
+// Sink delivers events to one destination.
+type Sink interface {
+	Send(ctx context.Context, ev Event) error
+	SendDigest(ctx context.Context, d Digest) error
+}
+
+// DefaultTemplate is used when a channel doesn't define its own.
+const DefaultTemplate = `{{if .Outdated}}Update available for {{.Namespace}}/{{.Name}}:` +
+	`{{range .Sources}}{{if .Outdated}} {{.Name}} {{.Current}} -> {{.LatestInPolicy}}{{end}}{{end}}` +
+	`{{else}}{{.Namespace}}/{{.Name}} is up to date{{end}}`
+
+// DefaultDigestTemplate is used for digests when a channel doesn't define
+// its own.
+const DefaultDigestTemplate = `{{len .Updates}} updates available:{{range .Updates}}` + "\n" +
+	`- {{.Namespace}}/{{.Name}}:{{range .Sources}}{{if .Outdated}} {{.Name}} {{.Current}} -> {{.LatestInPolicy}}{{end}}{{end}}{{end}}`
+
+// ParseTemplate parses a message template, falling back to fallback when
+// text is empty.
+func ParseTemplate(text, fallback string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = fallback
+	}
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Render executes the template with an event or digest.
+func Render(tmpl *template.Template, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering message: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Config holds the settings a sink is built from.
+type Config struct {
+	// Type is one of slack, mattermost or webhook.
+	Type string
+	URL  string
+	// SigningKey signs webhook payloads, if set.
+	SigningKey []byte
+	// Template of the message, DefaultTemplate when empty.
+	Template string
+	// DigestTemplate of digests, DefaultDigestTemplate when empty.
+	DigestTemplate string
+	// Client used to deliver messages, defaults to a client with a 10s
+	// timeout.
+	Client *http.Client
+}
+
+// NewSink returns the sink described by cfg.
+func NewSink(cfg Config) (Sink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("no url configured for %s sink", cfg.Type)
+	}
+	tmpl, err := ParseTemplate(cfg.Template, DefaultTemplate)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := ParseTemplate(cfg.DigestTemplate, DefaultDigestTemplate)
+	if err != nil {
+		return nil, err
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	switch cfg.Type {
+	case "slack", "mattermost":
+		return &Slack{URL: cfg.URL, Template: tmpl, DigestTemplate: digest, Client: client}, nil
+	case "webhook":
+		return &Webhook{URL: cfg.URL, SigningKey: cfg.SigningKey, Template: tmpl, DigestTemplate: digest, Client: client}, nil
+	}
+	return nil, fmt.Errorf("unsupported notification type %q, expected one of: slack, mattermost, webhook", cfg.Type)
+}
+
+// post sends a JSON body and fails on any non 2xx response.
+func post(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &RejectedError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+// RejectedError is returned when a sink answers with a non 2xx status.
+type RejectedError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("notification rejected with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Retriable reports whether sending again may succeed: when the sink
+// couldn't be reached, is unavailable or throttles notifications.
+func Retriable(err error) bool {
+	var rejected *RejectedError
+	if errors.As(err, &rejected) {
+		return rejected.StatusCode >= http.StatusInternalServerError || rejected.StatusCode == http.StatusTooManyRequests
+	}
+	return err != nil && !errors.Is(err, context.Canceled)
+}
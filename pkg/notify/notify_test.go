@@ -0,0 +1,210 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var outdated = Event{
+	Namespace: "prometheus",
+	Name:      "prometheus-operator",
+	Outdated:  true,
+	Sources: []Source{
+		{Name: "prometheus-operator", Type: "helm", Current: "34.9.1", Latest: "41.5.1", LatestInPolicy: "34.10.0", Outdated: true},
+		{Name: "grafana", Type: "helm", Current: "6.40.0", Latest: "6.40.0", LatestInPolicy: "6.40.0"},
+	},
+}
+
+// receiver records the last request sent to it.
+type receiver struct {
+	*httptest.Server
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	r := &receiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.header = req.Header
+		r.body, _ = ioutil.ReadAll(req.Body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestSlack(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      string
+		template string
+		event    Event
+		want     string
+	}{
+		{
+			name:  "default template",
+			typ:   "slack",
+			event: outdated,
+			want:  "Update available for prometheus/prometheus-operator: prometheus-operator 34.9.1 -> 34.10.0",
+		},
+		{
+			name:  "up to date",
+			typ:   "mattermost",
+			event: Event{Namespace: "velero", Name: "velero"},
+			want:  "velero/velero is up to date",
+		},
+		{
+			name:     "custom template",
+			typ:      "slack",
+			template: `{{.Name}}{{range .Sources}} {{.Name}}={{.Latest}}{{end}}`,
+			event:    outdated,
+			want:     "prometheus-operator prometheus-operator=41.5.1 grafana=6.40.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReceiver(t, http.StatusOK)
+			sink, err := NewSink(Config{Type: tt.typ, URL: r.URL, Template: tt.template})
+			if err != nil {
+				t.Fatalf("NewSink() = %v", err)
+			}
+			if err := sink.Send(context.Background(), tt.event); err != nil {
+				t.Fatalf("Send() = %v", err)
+			}
+
+			var msg slackMessage
+			if err := json.Unmarshal(r.body, &msg); err != nil {
+				t.Fatalf("invalid payload %s: %v", r.body, err)
+			}
+			if msg.Text != tt.want {
+				t.Errorf("text = %q, want %q", msg.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSigned(t *testing.T) {
+	r := newReceiver(t, http.StatusNoContent)
+	key := []byte("s3cr3t")
+	sink, err := NewSink(Config{Type: "webhook", URL: r.URL, SigningKey: key})
+	if err != nil {
+		t.Fatalf("NewSink() = %v", err)
+	}
+	if err := sink.Send(context.Background(), outdated); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+
+	if got, want := r.header.Get(SignatureHeader), Sign(key, r.body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatalf("invalid payload %s: %v", r.body, err)
+	}
+	if payload.Name != outdated.Name || len(payload.Sources) != 2 || !payload.Sources[0].Outdated {
+		t.Errorf("payload = %+v, want %+v", payload.Event, outdated)
+	}
+	if payload.Text == "" {
+		t.Error("payload has no text")
+	}
+}
+
+func TestSendRejected(t *testing.T) {
+	r := newReceiver(t, http.StatusForbidden)
+	sink, err := NewSink(Config{Type: "slack", URL: r.URL})
+	if err != nil {
+		t.Fatalf("NewSink() = %v", err)
+	}
+	err = sink.Send(context.Background(), outdated)
+	if err == nil {
+		t.Fatal("Send() succeeded on a rejected notification")
+	}
+	if Retriable(err) {
+		t.Errorf("Retriable(%v) = true, want a forbidden notification not retried", err)
+	}
+}
+
+func TestRetriable(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"delivered":   {nil, false},
+		"unreachable": {errors.New("dial tcp: connection refused"), true},
+		"canceled":    {context.Canceled, false},
+		"unavailable": {&RejectedError{StatusCode: http.StatusServiceUnavailable}, true},
+		"throttled":   {&RejectedError{StatusCode: http.StatusTooManyRequests}, true},
+		"not found":   {&RejectedError{StatusCode: http.StatusNotFound}, false},
+	}
+	for name, tt := range tests {
+		if got := Retriable(tt.err); got != tt.want {
+			t.Errorf("%s: Retriable() = %v, want %v", name, got, tt.want)
+		}
+	}
+}
+
+func TestNewSinkInvalid(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"type":     {Type: "email", URL: "http://example.com"},
+		"url":      {Type: "slack"},
+		"template": {Type: "slack", URL: "http://example.com", Template: "{{.Name"},
+	} {
+		if _, err := NewSink(cfg); err == nil {
+			t.Errorf("NewSink() accepted an invalid %s", name)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	digest := Digest{Updates: []Event{
+		outdated,
+		{Namespace: "velero", Name: "velero", Outdated: true, Sources: []Source{{Name: "velero", Current: "2.31.0", LatestInPolicy: "2.32.1", Outdated: true}}},
+	}}
+
+	t.Run("slack", func(t *testing.T) {
+		r := newReceiver(t, http.StatusOK)
+		sink, err := NewSink(Config{Type: "slack", URL: r.URL})
+		if err != nil {
+			t.Fatalf("NewSink() = %v", err)
+		}
+		if err := sink.SendDigest(context.Background(), digest); err != nil {
+			t.Fatalf("SendDigest() = %v", err)
+		}
+
+		var msg slackMessage
+		if err := json.Unmarshal(r.body, &msg); err != nil {
+			t.Fatalf("invalid payload %s: %v", r.body, err)
+		}
+		want := "2 updates available:\n" +
+			"- prometheus/prometheus-operator: prometheus-operator 34.9.1 -> 34.10.0\n" +
+			"- velero/velero: velero 2.31.0 -> 2.32.1"
+		if msg.Text != want {
+			t.Errorf("text = %q, want %q", msg.Text, want)
+		}
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		r := newReceiver(t, http.StatusOK)
+		sink, err := NewSink(Config{Type: "webhook", URL: r.URL, DigestTemplate: "{{len .Updates}}"})
+		if err != nil {
+			t.Fatalf("NewSink() = %v", err)
+		}
+		if err := sink.SendDigest(context.Background(), digest); err != nil {
+			t.Fatalf("SendDigest() = %v", err)
+		}
+
+		var payload digestPayload
+		if err := json.Unmarshal(r.body, &payload); err != nil {
+			t.Fatalf("invalid payload %s: %v", r.body, err)
+		}
+		if len(payload.Updates) != 2 || payload.Text != "2" {
+			t.Errorf("payload = %s", r.body)
+		}
+	})
+}
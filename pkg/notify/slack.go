@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"text/template"
+)
+
+// Slack posts messages to a Slack or Mattermost incoming webhook, which
+// both accept the same payload.
+type Slack struct {
+	URL            string
+	Template       *template.Template
+	DigestTemplate *template.Template
+	Client         *http.Client
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Send posts the rendered message.
+func (s *Slack) Send(ctx context.Context, ev Event) error {
+	return s.post(ctx, s.Template, ev)
+}
+
+// SendDigest posts the rendered digest.
+func (s *Slack) SendDigest(ctx context.Context, d Digest) error {
+	return s.post(ctx, s.DigestTemplate, d)
+}
+
+func (s *Slack) post(ctx context.Context, tmpl *template.Template, data interface{}) error {
+	text, err := Render(tmpl, data)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(slackMessage{Text: text})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.Client, s.URL, body, nil)
+}
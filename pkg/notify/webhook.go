@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"text/template"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of webhook payloads.
+const SignatureHeader = "X-Kupdater-Signature"
+
+// Webhook posts events as JSON, along with the rendered message.
+type Webhook struct {
+	URL string
+	// SigningKey signs the payload when set, see Sign.
+	SigningKey     []byte
+	Template       *template.Template
+	DigestTemplate *template.Template
+	Client         *http.Client
+}
+
+type webhookPayload struct {
+	Event
+	Text string `json:"text"`
+}
+
+type digestPayload struct {
+	Digest
+	Text string `json:"text"`
+}
+
+// Send posts the event.
+func (w *Webhook) Send(ctx context.Context, ev Event) error {
+	text, err := Render(w.Template, ev)
+	if err != nil {
+		return err
+	}
+	return w.post(ctx, webhookPayload{Event: ev, Text: text})
+}
+
+// SendDigest posts the digest, the payload lists the events as "updates".
+func (w *Webhook) SendDigest(ctx context.Context, d Digest) error {
+	text, err := Render(w.DigestTemplate, d)
+	if err != nil {
+		return err
+	}
+	return w.post(ctx, digestPayload{Digest: d, Text: text})
+}
+
+func (w *Webhook) post(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if len(w.SigningKey) > 0 {
+		header.Set(SignatureHeader, Sign(w.SigningKey, body))
+	}
+	return post(ctx, w.Client, w.URL, body, header)
+}
+
+// Sign returns the signature of a payload, formatted as "sha256=<hex>" like
+// the signatures of Github webhooks. Receivers verify it by signing the raw
+// request body with the shared key.
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// IsUnreachable reports whether err was caused by the source not being
+// reachable over the network, e.g. a DNS failure, a refused connection or a
+// timeout, as opposed to the source answering with an error.
+func IsUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RateLimitError is returned when the API of a source refuses requests until
+// its rate limit resets.
+type RateLimitError struct {
+	// Reset is when requests are accepted again.
+	Reset time.Time
+	Err   error
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// TruncatedError is returned along with the versions listed when a source
+// has more pages of versions than are fetched. Newer versions may be missing
+// from lists which aren't sorted newest first.
+type TruncatedError struct {
+	// Source is the url of the source.
+	Source string
+	// Pages is the number of pages listed.
+	Pages int
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("only the first %d pages of versions of %s were listed", e.Pages, e.Source)
+}
+
+// IsTruncated reports whether err only reports that the versions returned
+// along with it are truncated.
+func IsTruncated(err error) bool {
+	var truncErr *TruncatedError
+	return errors.As(err, &truncErr)
+}
+
+// RateLimitReset returns when the rate limit which caused err resets, and
+// whether err was caused by a rate limit at all.
+func RateLimitReset(err error) (time.Time, bool) {
+	var rateErr *RateLimitError
+	if errors.As(err, &rateErr) {
+		return rateErr.Reset, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsUnreachable(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, refused := http.Get(closed.URL)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+	_, timeout := (&http.Client{Timeout: 10 * time.Millisecond}).Get(slow.URL)
+
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":                {nil, false},
+		"connection refused": {refused, true},
+		"client timeout":     {timeout, true},
+		"wrapped deadline":   {fmt.Errorf("listing tags: %w", context.DeadlineExceeded), true},
+		"status error":       {errors.New("unexpected status 404 Not Found"), false},
+	}
+	for name, tt := range tests {
+		if got := IsUnreachable(tt.err); got != tt.want {
+			t.Errorf("%s: IsUnreachable(%v) = %v, want %v", name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// forgeMaxPages bounds how many pages of releases or tags are fetched from
+// GitLab and Gitea.
+const forgeMaxPages = 10
+
+// forgeAPI describes the REST API of a self-hostable forge, GitLab or
+// Gitea, which only differ in how requests are authenticated and paged.
+type forgeAPI struct {
+	// name of the forge in errors.
+	name string
+	// path of the API below the root of an instance, e.g. /api/v4.
+	path string
+	// authorize sets token on a request.
+	authorize func(req *http.Request, token string)
+	// page returns the query selecting a page, counting from 1.
+	page func(n int) url.Values
+	// more reports whether a response is followed by another page, fetched
+	// being the number of items of the pages fetched so far.
+	more func(resp *http.Response, fetched int) bool
+}
+
+// forge lists the releases and the tags of repositories of a forge API,
+// newest first.
+type forge interface {
+	releases(ctx context.Context, src Source) ([]Release, error)
+	tags(ctx context.Context, src Source) ([]Release, error)
+}
+
+// forgeList returns the releases of a repository, or its tags when it has no
+// releases, reporting which were returned.
+func forgeList(ctx context.Context, f forge, src Source) ([]Release, bool, error) {
+	releases, err := f.releases(ctx, src)
+	if err != nil || len(releases) > 0 {
+		return releases, false, err
+	}
+	tags, err := f.tags(ctx, src)
+	return tags, true, err
+}
+
+// forgeLatest returns the most recent stable release of a repository, or its
+// highest tag when it has no releases.
+func forgeLatest(ctx context.Context, f forge, src Source) (Release, error) {
+	releases, tags, err := forgeList(ctx, f, src)
+	if err != nil && !IsTruncated(err) {
+		return Release{}, err
+	}
+	if tags {
+		latest, herr := highestRelease(releases, src.URL)
+		if herr != nil {
+			return Release{}, herr
+		}
+		return latest, err
+	}
+	for _, r := range releases {
+		if !r.Prerelease {
+			return r, err
+		}
+	}
+	return Release{}, fmt.Errorf("no stable releases found in %s", src.URL)
+}
+
+// getPages fetches the pages of a list endpoint and hands each of them to
+// decode. Lists longer than forgeMaxPages pages are cut off, returning a
+// TruncatedError once their first pages were decoded.
+func (f *forgeAPI) getPages(ctx context.Context, client *http.Client, src Source, endpoint string, query url.Values, decode func([]byte) error) error {
+	fetched := 0
+	for n := 1; n <= forgeMaxPages; n++ {
+		q := f.page(n)
+		for k, v := range query {
+			q[k] = v
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if token := src.Credentials["token"]; len(token) > 0 {
+			f.authorize(req, string(token))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s %s: %s", f.name, resp.Status, forgeMessage(body))
+		}
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return fmt.Errorf("decoding %s response: %w", f.name, err)
+		}
+		if err := decode(body); err != nil {
+			return fmt.Errorf("decoding %s response: %w", f.name, err)
+		}
+		fetched += len(items)
+		if !f.more(resp, fetched) {
+			return nil
+		}
+	}
+	return &TruncatedError{Source: src.URL, Pages: forgeMaxPages}
+}
+
+// forgeMessage extracts the message of an API error, which GitLab sets
+// either as a message, possibly holding errors by field, or an error.
+func forgeMessage(body []byte) string {
+	var e struct {
+		Message interface{} `json:"message"`
+		Error   string      `json:"error"`
+	}
+	if err := json.Unmarshal(body, &e); err == nil {
+		if e.Message != nil {
+			return fmt.Sprint(e.Message)
+		}
+		if e.Error != "" {
+			return e.Error
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// repository returns the API url of the instance hosting a repository and
+// the path of the repository url below the root of the instance. Instances
+// served under a path set their API url, which is stripped from the
+// repository url.
+func (f *forgeAPI) repository(src Source) (api, path string, err error) {
+	u, err := url.Parse(src.URL)
+	if err != nil || u.Host == "" {
+		return "", "", fmt.Errorf("invalid %s repository url %q", f.name, src.URL)
+	}
+
+	api = fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, f.path)
+	path = u.Path
+	if src.APIURL != "" {
+		a, err := url.Parse(src.APIURL)
+		if err != nil || a.Host == "" {
+			return "", "", fmt.Errorf("invalid %s API url %q", f.name, src.APIURL)
+		}
+		api = strings.TrimSuffix(src.APIURL, "/")
+		path = strings.TrimPrefix(path, strings.TrimSuffix(strings.TrimSuffix(a.Path, "/"), f.path))
+	}
+	return api, strings.Trim(path, "/"), nil
+}
+
+// pageQuery returns the query selecting page n of size items, using the
+// size parameter of the forge.
+func pageQuery(size string, items int) func(n int) url.Values {
+	return func(n int) url.Values {
+		return url.Values{size: {strconv.Itoa(items)}, "page": {strconv.Itoa(n)}}
+	}
+}
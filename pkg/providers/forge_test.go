@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// forgeServer is a stand-in for the API of a GitLab or Gitea instance,
+// serving lists of JSON items by escaped path.
+type forgeServer struct {
+	// authorized reports whether a request carries the token expected.
+	authorized func(r *http.Request) bool
+	// unauthorized and notFound are the bodies of error responses.
+	unauthorized, notFound string
+	lists                  map[string][]string
+	// limit is the size of pages, unless requests set the limit parameter.
+	limit int
+	// maxLimit caps the size of pages requested, if set.
+	maxLimit int
+	// next announces the page following a page which isn't the last, if
+	// set.
+	next func(w http.ResponseWriter, r *http.Request, page int)
+	// total reports the length of lists in the X-Total-Count header.
+	total bool
+}
+
+// start serves the lists until the end of the test.
+func (f forgeServer) start(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, f.unauthorized)
+			return
+		}
+		items, ok := f.lists[r.URL.EscapedPath()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, f.notFound)
+			return
+		}
+
+		limit, page := f.limit, 1
+		if l := r.URL.Query().Get("limit"); l != "" {
+			limit, _ = strconv.Atoi(l)
+		}
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+		if f.maxLimit > 0 && limit > f.maxLimit {
+			limit = f.maxLimit
+		}
+		start, end := (page-1)*limit, page*limit
+		if start > len(items) {
+			start = len(items)
+		}
+		if end < len(items) && f.next != nil {
+			f.next(w, r, page+1)
+		}
+		if f.total {
+			w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+		}
+		if end > len(items) {
+			end = len(items)
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(items[start:end], ","))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestForgeMessage(t *testing.T) {
+	tests := map[string]string{
+		`{"message": "404 Project Not Found"}`:        "404 Project Not Found",
+		`{"message": {"name": ["is too long"]}}`:      "map[name:[is too long]]",
+		`{"error": "insufficient_scope"}`:             "insufficient_scope",
+		"<html>502 Bad Gateway</html>\n":              "<html>502 Bad Gateway</html>",
+		`{"message": "token is required", "url": ""}`: "token is required",
+	}
+	for body, want := range tests {
+		if got := forgeMessage([]byte(body)); got != want {
+			t.Errorf("forgeMessage(%s) = %q, want %q", body, got, want)
+		}
+	}
+}
+
+func TestForgePaging(t *testing.T) {
+	var tags []string
+	for i := 0; i < 45; i++ {
+		tags = append(tags, fmt.Sprintf(`{"name": "v1.%d.0"}`, 100-i))
+	}
+	gitea := func(tags []string, maxLimit int, next func(http.ResponseWriter, *http.Request, int), total bool) Source {
+		srv := forgeServer{
+			authorized: func(*http.Request) bool { return true },
+			lists: map[string][]string{
+				"/api/v1/repos/infra/backup/releases": nil,
+				"/api/v1/repos/infra/backup/tags":     tags,
+			},
+			maxLimit: maxLimit,
+			next:     next,
+			total:    total,
+		}.start(t)
+		return Source{URL: srv.URL + "/infra/backup"}
+	}
+	var long []string
+	for i := 0; i < (forgeMaxPages+1)*giteaPageSize; i++ {
+		long = append(long, fmt.Sprintf(`{"name": "v0.%d.0"}`, i))
+	}
+
+	tests := map[string]struct {
+		src       Source
+		want      int
+		truncated bool
+	}{
+		// Instances serving fewer items than requested per page
+		"capped page size linked":  {src: gitea(tags, 20, giteaNext, false), want: 45},
+		"capped page size counted": {src: gitea(tags, 20, nil, true), want: 45},
+		"single page":              {src: gitea(tags, 0, giteaNext, true), want: 45},
+		"no paging headers":        {src: gitea(tags, 20, nil, false), want: 20},
+		"page limit":               {src: gitea(long, 0, giteaNext, true), want: forgeMaxPages * giteaPageSize, truncated: true},
+	}
+	for name, tt := range tests {
+		list, err := NewGitea(nil).ListVersions(context.Background(), tt.src)
+		if err != nil && !(tt.truncated && IsTruncated(err)) {
+			t.Errorf("%s: ListVersions() = %v", name, err)
+			continue
+		}
+		if IsTruncated(err) != tt.truncated || len(list) != tt.want {
+			t.Errorf("%s: ListVersions() = %d tags, %v, want %d tags, truncated %v", name, len(list), err, tt.want, tt.truncated)
+		}
+	}
+
+	latest, err := NewGitea(nil).Latest(context.Background(), gitea(long, 0, giteaNext, true))
+	if !IsTruncated(err) || latest.Version != fmt.Sprintf("v0.%d.0", forgeMaxPages*giteaPageSize-1) {
+		t.Errorf("Latest() of a truncated list = %s, %v, want the highest tag listed and the truncation", latest.Version, err)
+	}
+}
@@ -0,0 +1,91 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/getais/kupdater/pkg/gitops"
+)
+
+// peeledSuffix marks the commit an annotated tag points to in the refs
+// advertised by a remote, e.g. refs/tags/v1.0.0^{}.
+const peeledSuffix = "^{}"
+
+// Git looks up the tags of any Git remote, the equivalent of
+// git ls-remote --tags, for projects which don't publish releases on a
+// forge. Tags carry no publication date.
+type Git struct {
+	// local allows file remotes, which would read the filesystem of the
+	// operator, for tests.
+	local bool
+}
+
+// NewGit returns a Git provider.
+func NewGit() *Git {
+	return &Git{}
+}
+
+// Validate checks the source is an HTTPS or SSH Git remote url, e.g.
+// https://git.kernel.org/pub/scm/git/git.git or git@example.com:infra/backup.git.
+func (g *Git) Validate(src Source) error {
+	ep, err := transport.NewEndpoint(src.URL)
+	if err != nil {
+		return fmt.Errorf("invalid Git repository url %q: %w", src.URL, err)
+	}
+	switch {
+	case ep.Protocol == "https", ep.Protocol == "ssh":
+	case ep.Protocol == "file" && g.local:
+	default:
+		return fmt.Errorf("unsupported Git repository url %q, expected an https, ssh or scp-like url", src.URL)
+	}
+	return nil
+}
+
+// ListVersions returns the tags of the remote. Credentials are the ones of
+// gitops.Auth: a token, username and password, or an SSH identity.
+func (g *Git) ListVersions(ctx context.Context, src Source) ([]Release, error) {
+	if err := g.Validate(src); err != nil {
+		return nil, err
+	}
+	auth, err := gitops.Auth(src.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{src.URL}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, fmt.Errorf("listing tags of %s: %w", src.URL, err)
+	}
+
+	seen := map[string]bool{}
+	releases := make([]Release, 0, len(refs))
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		// Annotated tags are advertised twice, peeled to their commit once
+		tag := strings.TrimSuffix(ref.Name().Short(), peeledSuffix)
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		releases = append(releases, Release{Version: tag})
+	}
+	return releases, nil
+}
+
+// Latest returns the highest version tagged on the remote.
+func (g *Git) Latest(ctx context.Context, src Source) (Release, error) {
+	releases, err := g.ListVersions(ctx, src)
+	if err != nil {
+		return Release{}, err
+	}
+	return highestRelease(releases, src.URL)
+}
@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func init() {
+	// Serve local repositories in process rather than through git binaries
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+// taggedRepository returns the url of a bare repository with a branch, the
+// lightweight tags and the annotated tags given.
+func taggedRepository(t *testing.T, lightweight, annotated []string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, _ := repo.Worktree()
+	tagger := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	f, _ := tree.Filesystem.Create("README.md")
+	f.Write([]byte("backup\n"))
+	f.Close()
+	tree.Add("README.md")
+	commit, err := tree.Commit("Initial commit", &git.CommitOptions{Author: tagger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range lightweight {
+		if _, err := repo.CreateTag(tag, commit, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tag := range annotated {
+		if _, err := repo.CreateTag(tag, commit, &git.CreateTagOptions{Tagger: tagger, Message: "Release " + tag}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	url := "file://" + dir
+	repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}})
+	err = repo.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/main", "refs/tags/*:refs/tags/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url
+}
+
+func TestGitListVersions(t *testing.T) {
+	url := taggedRepository(t, []string{"v1.2.0", "nightly"}, []string{"v1.10.0", "v1.9.3"})
+	src := Source{URL: url}
+
+	g := &Git{local: true}
+	if err := g.Validate(src); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	list, err := g.ListVersions(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	var tags []string
+	for _, r := range list {
+		tags = append(tags, r.Version)
+	}
+	sort.Strings(tags)
+	want := []string{"nightly", "v1.10.0", "v1.2.0", "v1.9.3"}
+	if len(tags) != len(want) {
+		t.Fatalf("ListVersions() = %v, want %v", tags, want)
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Fatalf("ListVersions() = %v, want %v", tags, want)
+		}
+	}
+
+	latest, err := g.Latest(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Latest() = %v", err)
+	}
+	if latest.Version != "v1.10.0" {
+		t.Errorf("Latest() = %s, want v1.10.0", latest.Version)
+	}
+}
+
+func TestGitErrors(t *testing.T) {
+	g := &Git{local: true}
+	if _, err := g.ListVersions(context.Background(), Source{URL: "file://" + t.TempDir()}); err == nil {
+		t.Errorf("ListVersions() of a directory without repository succeeded")
+	}
+
+	for _, url := range []string{"https://example.com/infra/backup.git", "git@example.com:infra/backup.git", "ssh://git@example.com/infra/backup.git"} {
+		if err := g.Validate(Source{URL: url}); err != nil {
+			t.Errorf("Validate(%s) = %v", url, err)
+		}
+	}
+	if err := g.Validate(Source{URL: "https://example.com:port/backup.git"}); err == nil {
+		t.Errorf("Validate() of an invalid url succeeded")
+	}
+
+	for _, url := range []string{"file:///var/run/secrets", "/var/run/secrets", "git://example.com/infra/backup.git", "http://example.com/infra/backup.git"} {
+		if err := NewGit().Validate(Source{URL: url}); err == nil {
+			t.Errorf("Validate(%s) succeeded, want only https and ssh remotes", url)
+		}
+	}
+}
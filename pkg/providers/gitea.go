@@ -0,0 +1,130 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giteaPageSize is the number of releases or tags requested per page, the
+// default maximum of Gitea.
+const giteaPageSize = 50
+
+// giteaAPI is the REST API of Gitea, which links the next page of lists and
+// reports their length. Instances may serve fewer items per page than
+// requested, down to their MAX_RESPONSE_ITEMS.
+var giteaAPI = forgeAPI{
+	name: "Gitea",
+	path: "/api/v1",
+	authorize: func(req *http.Request, token string) {
+		req.Header.Set("Authorization", "token "+token)
+	},
+	page: pageQuery("limit", giteaPageSize),
+	more: func(resp *http.Response, fetched int) bool {
+		if link := resp.Header.Get("Link"); link != "" {
+			return strings.Contains(link, `rel="next"`)
+		}
+		total, err := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+		return err == nil && fetched < total
+	},
+}
+
+// Gitea looks up releases of a repository on Gitea, Forgejo or Codeberg,
+// falling back to its tags for repositories which don't publish releases.
+type Gitea struct {
+	Client *http.Client
+}
+
+// NewGitea returns a Gitea provider using httpClient, or
+// http.DefaultClient when nil.
+func NewGitea(httpClient *http.Client) *Gitea {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Gitea{Client: httpClient}
+}
+
+// Validate checks the source is a Gitea repository url.
+func (g *Gitea) Validate(src Source) error {
+	_, _, err := parseGiteaRepo(src)
+	return err
+}
+
+// ListVersions returns the releases of the repository, without drafts.
+func (g *Gitea) ListVersions(ctx context.Context, src Source) ([]Release, error) {
+	releases, _, err := forgeList(ctx, g, src)
+	return releases, err
+}
+
+// Latest returns the most recent stable release of the repository.
+func (g *Gitea) Latest(ctx context.Context, src Source) (Release, error) {
+	return forgeLatest(ctx, g, src)
+}
+
+func (g *Gitea) releases(ctx context.Context, src Source) ([]Release, error) {
+	var list []Release
+	err := g.getPages(ctx, src, "/releases", func(body []byte) error {
+		var page []struct {
+			TagName     string    `json:"tag_name"`
+			PublishedAt time.Time `json:"published_at"`
+			Draft       bool      `json:"draft"`
+			Prerelease  bool      `json:"prerelease"`
+		}
+		err := json.Unmarshal(body, &page)
+		for _, r := range page {
+			if !r.Draft {
+				list = append(list, Release{Version: r.TagName, Published: r.PublishedAt, Prerelease: r.Prerelease})
+			}
+		}
+		return err
+	})
+	return list, err
+}
+
+func (g *Gitea) tags(ctx context.Context, src Source) ([]Release, error) {
+	var list []Release
+	err := g.getPages(ctx, src, "/tags", func(body []byte) error {
+		var page []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				Created time.Time `json:"created"`
+			} `json:"commit"`
+		}
+		err := json.Unmarshal(body, &page)
+		for _, t := range page {
+			list = append(list, Release{Version: t.Name, Published: t.Commit.Created})
+		}
+		return err
+	})
+	return list, err
+}
+
+// getPages fetches the pages of a list endpoint of the repository.
+func (g *Gitea) getPages(ctx context.Context, src Source, endpoint string, decode func([]byte) error) error {
+	api, repo, err := parseGiteaRepo(src)
+	if err != nil {
+		return err
+	}
+	return giteaAPI.getPages(ctx, g.Client, src, fmt.Sprintf("%s/repos/%s%s", api, repo, endpoint), nil, decode)
+}
+
+// parseGiteaRepo returns the API url and the owner/repo path of a
+// repository, e.g. https://codeberg.org/api/v1 and forgejo/forgejo for
+// https://codeberg.org/forgejo/forgejo.
+func parseGiteaRepo(src Source) (api, repo string, err error) {
+	api, path, err := giteaAPI.repository(src)
+	if err != nil {
+		return "", "", err
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid Gitea repository url %q, expected https://<host>/<owner>/<repo>", src.URL)
+	}
+	return api, url.PathEscape(parts[0]) + "/" + url.PathEscape(strings.TrimSuffix(parts[1], ".git")), nil
+}
@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newGitea starts a stand-in for the Gitea API serving a repository below
+// prefix. Requests must carry token, if set.
+func newGitea(t *testing.T, prefix, repo, token string, releases, tags []string) *httptest.Server {
+	t.Helper()
+	repoPath := prefix + "/api/v1/repos/" + repo
+	return forgeServer{
+		authorized: func(r *http.Request) bool {
+			return token == "" || r.Header.Get("Authorization") == "token "+token
+		},
+		unauthorized: `{"message": "token is required"}`,
+		notFound:     `{"message": "GetRepositoryByName"}`,
+		lists: map[string][]string{
+			repoPath + "/releases": releases,
+			repoPath + "/tags":     tags,
+		},
+		next: giteaNext,
+	}.start(t)
+}
+
+// giteaNext links the next page like Gitea.
+func giteaNext(w http.ResponseWriter, r *http.Request, page int) {
+	next := *r.URL
+	q := next.Query()
+	q.Set("page", fmt.Sprint(page))
+	next.RawQuery = q.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.String()))
+}
+
+func TestGiteaListVersions(t *testing.T) {
+	// A full page of older releases makes the provider fetch the next page
+	releases := []string{
+		`{"tag_name": "v1.19.0-rc1", "published_at": "2022-11-20T00:00:00Z", "prerelease": true}`,
+		`{"tag_name": "v1.19.0-dev", "draft": true}`,
+		`{"tag_name": "v1.18.0", "published_at": "2022-10-20T00:00:00Z"}`,
+	}
+	for i := 0; len(releases) <= giteaPageSize; i++ {
+		releases = append(releases, fmt.Sprintf(`{"tag_name": "v1.17.%d", "published_at": "2022-09-01T00:00:00Z"}`, 100-i))
+	}
+	srv := newGitea(t, "", "forgejo/forgejo", "", releases, nil)
+	src := Source{URL: srv.URL + "/forgejo/forgejo"}
+
+	g := NewGitea(nil)
+	if err := g.Validate(src); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	list, err := g.ListVersions(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	if len(list) != len(releases)-1 {
+		t.Fatalf("ListVersions() returned %d releases, want %d without the draft", len(list), len(releases)-1)
+	}
+	if !list[0].Prerelease || list[1].Version != "v1.18.0" {
+		t.Errorf("ListVersions() = %v, want pre-release v1.19.0-rc1 followed by v1.18.0", list[:2])
+	}
+
+	latest, err := g.Latest(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Latest() = %v", err)
+	}
+	if latest.Version != "v1.18.0" {
+		t.Errorf("Latest() = %s, want v1.18.0", latest.Version)
+	}
+}
+
+func TestGiteaFallsBackToTags(t *testing.T) {
+	tags := []string{
+		`{"name": "v2.9.0", "commit": {"created": "2022-10-01T00:00:00Z"}}`,
+		`{"name": "v2.10.1", "commit": {"created": "2022-11-01T00:00:00Z"}}`,
+	}
+	srv := newGitea(t, "/git", "infra/backup", "secret", nil, tags)
+	src := Source{
+		URL:         srv.URL + "/git/infra/backup.git",
+		APIURL:      srv.URL + "/git/api/v1",
+		Credentials: map[string][]byte{"token": []byte("secret")},
+	}
+
+	g := NewGitea(nil)
+	latest, err := g.Latest(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Latest() = %v", err)
+	}
+	if latest.Version != "v2.10.1" {
+		t.Errorf("Latest() = %s, want v2.10.1", latest.Version)
+	}
+
+	src.Credentials = nil
+	_, err = g.ListVersions(context.Background(), src)
+	if err == nil || !strings.Contains(err.Error(), "token is required") {
+		t.Errorf("ListVersions() without token = %v, want token is required", err)
+	}
+}
+
+func TestParseGiteaRepo(t *testing.T) {
+	tests := []struct {
+		src       Source
+		api, repo string
+		wantErr   bool
+	}{
+		{
+			src:  Source{URL: "https://codeberg.org/forgejo/forgejo"},
+			api:  "https://codeberg.org/api/v1",
+			repo: "forgejo/forgejo",
+		},
+		{
+			src:  Source{URL: "https://gitea.com/gitea/tea.git"},
+			api:  "https://gitea.com/api/v1",
+			repo: "gitea/tea",
+		},
+		{
+			src:  Source{URL: "https://codeberg.org/forgejo/forgejo/releases"},
+			api:  "https://codeberg.org/api/v1",
+			repo: "forgejo/forgejo",
+		},
+		{
+			src:  Source{URL: "https://example.com/git/infra/backup", APIURL: "https://example.com/git/api/v1/"},
+			api:  "https://example.com/git/api/v1",
+			repo: "infra/backup",
+		},
+		{src: Source{URL: "https://codeberg.org/forgejo"}, wantErr: true},
+		{src: Source{URL: "forgejo/forgejo"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		api, repo, err := parseGiteaRepo(tt.src)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGiteaRepo(%+v) error = %v, wantErr %v", tt.src, err, tt.wantErr)
+			continue
+		}
+		if api != tt.api || repo != tt.repo {
+			t.Errorf("parseGiteaRepo(%+v) = %s, %s, want %s, %s", tt.src, api, repo, tt.api, tt.repo)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/google/go-github/github"
+)
+
+// Modes of Github sources.
+const (
+	// GithubReleases lists every release, falling back to the tags of
+	// repositories which don't publish releases. It is the default.
+	GithubReleases = "releases"
+	// GithubTags lists every tag.
+	GithubTags = "tags"
+	// GithubLatest only considers the release Github marks as latest.
+	GithubLatest = "latest"
+)
+
+const (
+	// githubMaxPages bounds how many pages of releases or tags are fetched.
+	githubMaxPages = 10
+	// githubRateReserve is the fraction of the rate limit left unused, for
+	// other clients sharing the credentials such as pull requests.
+	githubRateReserve = 10
+	// githubClientIdle is how long clients of sources no longer looked up
+	// are kept, longer than the hour rate limits reset after.
+	githubClientIdle = 2 * time.Hour
+)
+
+// Github looks up releases or tags of a repository on github.com or a
+// Github Enterprise Server. Sources are looked up anonymously unless their
+// credentials hold either:
+//
+//   - "token", a personal access token
+//   - "githubAppID", "githubAppInstallationID" and "githubAppPrivateKey" of
+//     a Github App installed on the repository
+type Github struct {
+	// Client looks up sources without credentials on github.com.
+	Client *github.Client
+
+	httpClient *http.Client
+	mu         sync.Mutex
+	// clients of authenticated or Enterprise sources, kept so the rate limit
+	// they last reported is respected
+	clients map[string]*githubCachedClient
+}
+
+// githubCachedClient is a client along with when it was last used.
+type githubCachedClient struct {
+	client *github.Client
+	used   time.Time
+}
+
+// NewGithub returns a Github provider using httpClient, or
+// http.DefaultClient when nil.
+func NewGithub(httpClient *http.Client) *Github {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Github{
+		Client:     github.NewClient(&http.Client{Transport: &githubRateTransport{next: transport}, Timeout: httpClient.Timeout}),
+		httpClient: httpClient,
+		clients:    map[string]*githubCachedClient{},
+	}
+}
+
+// Validate checks the source is a Github repository url with a known mode.
+func (g *Github) Validate(src Source) error {
+	switch src.Mode {
+	case "", GithubReleases, GithubTags, GithubLatest:
+	default:
+		return fmt.Errorf("unsupported Github mode %q, expected one of: %s, %s, %s", src.Mode, GithubReleases, GithubTags, GithubLatest)
+	}
+	if _, _, err := parseGithubRepo(src.URL); err != nil {
+		return err
+	}
+	_, err := g.client(src)
+	return err
+}
+
+// ListVersions returns the versions of the repository according to the
+// mode of the source: all its releases or tags, or only its latest release.
+func (g *Github) ListVersions(ctx context.Context, src Source) ([]Release, error) {
+	owner, repo, err := parseGithubRepo(src.URL)
+	if err != nil {
+		return nil, err
+	}
+	c, err := g.client(src)
+	if err != nil {
+		return nil, err
+	}
+
+	switch src.Mode {
+	case GithubLatest:
+		release, err := g.Latest(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		return []Release{release}, nil
+	case GithubTags:
+		return listGithubTags(ctx, c, owner, repo, src)
+	}
+
+	releases, err := listGithubReleases(ctx, c, owner, repo, src)
+	if err != nil || len(releases) > 0 {
+		return releases, err
+	}
+	return listGithubTags(ctx, c, owner, repo, src)
+}
+
+// Latest returns the release Github marks as latest, or the highest tag in
+// tags mode and of repositories which don't publish releases.
+func (g *Github) Latest(ctx context.Context, src Source) (Release, error) {
+	owner, repo, err := parseGithubRepo(src.URL)
+	if err != nil {
+		return Release{}, err
+	}
+	c, err := g.client(src)
+	if err != nil {
+		return Release{}, err
+	}
+
+	if src.Mode != GithubTags {
+		release, resp, err := c.Repositories.GetLatestRelease(ctx, owner, repo)
+		if err == nil {
+			return githubRelease(release), nil
+		}
+		if src.Mode == GithubLatest || resp == nil || resp.StatusCode != http.StatusNotFound {
+			return Release{}, githubError(err)
+		}
+	}
+
+	tags, err := listGithubTags(ctx, c, owner, repo, src)
+	if err != nil && !IsTruncated(err) {
+		return Release{}, err
+	}
+	latest, herr := highestRelease(tags, src.URL)
+	if herr != nil {
+		return Release{}, herr
+	}
+	return latest, err
+}
+
+// client returns the client looking up a source: the anonymous client for
+// github.com, or one authenticated with the credentials of the source
+// against its API.
+func (g *Github) client(src Source) (*github.Client, error) {
+	api, err := githubAPIURL(src)
+	if err != nil {
+		return nil, err
+	}
+	creds := src.Credentials
+	token := creds["token"]
+	app := len(creds["githubAppID"]) > 0
+	if api == nil && len(token) == 0 && !app {
+		return g.Client, nil
+	}
+
+	key := githubClientKey(api, creds)
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.clients[key]; ok {
+		c.used = now
+		return c.client, nil
+	}
+	for k, c := range g.clients {
+		if now.Sub(c.used) > githubClientIdle {
+			delete(g.clients, k)
+		}
+	}
+
+	transport := g.httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	base := g.Client.BaseURL
+	if api != nil {
+		base = api
+	}
+	switch {
+	case app:
+		appID, err := strconv.ParseInt(string(creds["githubAppID"]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid githubAppID: %w", err)
+		}
+		installationID, err := strconv.ParseInt(string(creds["githubAppInstallationID"]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid githubAppInstallationID: %w", err)
+		}
+		installation, err := ghinstallation.New(transport, appID, installationID, creds["githubAppPrivateKey"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid githubAppPrivateKey: %w", err)
+		}
+		installation.BaseURL = strings.TrimSuffix(base.String(), "/")
+		transport = installation
+	default:
+		transport = &githubTokenTransport{token: string(token), next: transport}
+	}
+
+	c := github.NewClient(&http.Client{Transport: &githubRateTransport{next: transport}, Timeout: g.httpClient.Timeout})
+	c.BaseURL = base
+	g.clients[key] = &githubCachedClient{client: c, used: now}
+	return c, nil
+}
+
+// githubClientKey identifies the client of an API url and credentials
+// without keeping the credentials in memory.
+func githubClientKey(api *url.URL, creds map[string][]byte) string {
+	h := sha256.New()
+	for _, key := range []string{"token", "githubAppID", "githubAppInstallationID", "githubAppPrivateKey"} {
+		h.Write(creds[key])
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s %x", api, h.Sum(nil))
+}
+
+// githubTokenTransport authenticates requests with a personal access token.
+type githubTokenTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t *githubTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+t.token)
+	return t.next.RoundTrip(req)
+}
+
+// githubRateTransport refuses requests while less than a githubRateReserve
+// fraction of the rate limit last reported is left, instead of exhausting
+// it until Github refuses them.
+type githubRateTransport struct {
+	next http.RoundTripper
+
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func (t *githubRateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	limit, remaining, reset := t.limit, t.remaining, t.reset
+	t.mu.Unlock()
+	if remaining*githubRateReserve < limit && time.Now().Before(reset) {
+		return nil, &RateLimitError{Reset: reset, Err: fmt.Errorf("Github rate limit almost exceeded, %d of %d requests left until %s", remaining, limit, reset.Format(time.RFC3339))}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if limit, remaining, reset, ok := githubRate(resp.Header); ok {
+		t.mu.Lock()
+		t.limit, t.remaining, t.reset = limit, remaining, reset
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// githubRate parses the rate limit reported in the headers of a response,
+// if any.
+func githubRate(h http.Header) (limit, remaining int, reset time.Time, ok bool) {
+	limit, err := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return 0, 0, time.Time{}, false
+	}
+	remaining, err = strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return 0, 0, time.Time{}, false
+	}
+	epoch, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, 0, time.Time{}, false
+	}
+	return limit, remaining, time.Unix(epoch, 0), true
+}
+
+// githubAPIURL returns the API url of a source: its API url when set, the
+// one of the Enterprise Server hosting it, or nil for github.com.
+func githubAPIURL(src Source) (*url.URL, error) {
+	api := src.APIURL
+	if api == "" {
+		u, err := url.Parse(src.URL)
+		if err != nil || u.Host == "github.com" || u.Host == "www.github.com" {
+			return nil, nil
+		}
+		// Enterprise Servers serve their API below /api/v3
+		api = fmt.Sprintf("%s://%s/api/v3/", u.Scheme, u.Host)
+	}
+	u, err := url.Parse(api)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid Github API url %q", api)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
+}
+
+// githubError reports rate limit errors as RateLimitError, along with when
+// the rate limit resets.
+func githubError(err error) error {
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return &RateLimitError{Reset: rateErr.Rate.Reset.Time, Err: err}
+	}
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		retry := time.Minute
+		if abuseErr.RetryAfter != nil {
+			retry = *abuseErr.RetryAfter
+		}
+		return &RateLimitError{Reset: time.Now().Add(retry), Err: err}
+	}
+	return err
+}
+
+// listGithubReleases returns the published releases of the repository,
+// newest first, down to the page past the installed version.
+func listGithubReleases(ctx context.Context, c *github.Client, owner, repo string, src Source) ([]Release, error) {
+	return listGithubPages(src, true, func(opts *github.ListOptions) ([]Release, *github.Response, error) {
+		list, resp, err := c.Repositories.ListReleases(ctx, owner, repo, opts)
+		releases := make([]Release, 0, len(list))
+		for _, r := range list {
+			if r.GetDraft() {
+				continue
+			}
+			releases = append(releases, githubRelease(r))
+		}
+		return releases, resp, err
+	})
+}
+
+// listGithubTags returns every tag of the repository. Tags carry no
+// publication date, nor are they listed newest first.
+func listGithubTags(ctx context.Context, c *github.Client, owner, repo string, src Source) ([]Release, error) {
+	return listGithubPages(src, false, func(opts *github.ListOptions) ([]Release, *github.Response, error) {
+		list, resp, err := c.Repositories.ListTags(ctx, owner, repo, opts)
+		releases := make([]Release, 0, len(list))
+		for _, t := range list {
+			releases = append(releases, Release{Version: t.GetName()})
+		}
+		return releases, resp, err
+	})
+}
+
+// listGithubPages fetches the pages of a list until the last one. Lists
+// sorted newest first stop at the page past the installed version. Lists
+// longer than githubMaxPages pages are cut off, returning a TruncatedError
+// along with their first pages.
+func listGithubPages(src Source, newestFirst bool, list func(*github.ListOptions) ([]Release, *github.Response, error)) ([]Release, error) {
+	var releases []Release
+	opts := &github.ListOptions{PerPage: 100}
+	for i := 0; i < githubMaxPages; i++ {
+		page, resp, err := list(opts)
+		if err != nil {
+			return nil, githubError(err)
+		}
+		releases = append(releases, page...)
+		if resp.NextPage == 0 || (newestFirst && pastInstalled(src, page)) {
+			return releases, nil
+		}
+		opts.Page = resp.NextPage
+	}
+	return releases, &TruncatedError{Source: src.URL, Pages: githubMaxPages}
+}
+
+// pastInstalled reports whether a page of versions listed newest first
+// reached the installed version: it holds versions which aren't newer than
+// the installed one, and none newer.
+func pastInstalled(src Source, page []Release) bool {
+	if src.Compare == nil {
+		return false
+	}
+	reached := false
+	for _, r := range page {
+		cmp, ok := src.Compare(r.Version)
+		if !ok {
+			continue
+		}
+		if cmp > 0 {
+			return false
+		}
+		reached = true
+	}
+	return reached
+}
+
+func githubRelease(r *github.RepositoryRelease) Release {
+	return Release{
+		Version:    r.GetTagName(),
+		Published:  r.GetPublishedAt().Time,
+		Prerelease: r.GetPrerelease(),
+	}
+}
+
+// parseGithubRepo extracts owner and repository out of a project url,
+// e.g. https://github.com/argoproj/argo-cd
+func parseGithubRepo(source string) (owner, repo string, err error) {
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return "", "", fmt.Errorf("invalid Github repository url %q", source)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid Github repository url %q, expected https://github.com/<owner>/<repo>", source)
+	}
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), nil
+}
@@ -0,0 +1,386 @@
+package providers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getais/kupdater/pkg/versions"
+)
+
+func newGithub(t *testing.T, mux *http.ServeMux) *Github {
+	t.Helper()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	g := NewGithub(nil)
+	g.Client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return g
+}
+
+func TestGithubLatest(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/argoproj/argo-cd/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v2.4.2", "published_at": "2022-06-21T00:00:00Z"}`))
+	})
+	g := newGithub(t, mux)
+
+	release, err := g.Latest(context.Background(), Source{URL: "https://github.com/argoproj/argo-cd"})
+	if err != nil {
+		t.Fatalf("Latest() = %v", err)
+	}
+	if release.Version != "v2.4.2" {
+		t.Errorf("Latest() = %s, want v2.4.2", release.Version)
+	}
+}
+
+func TestGithubListVersionsSkipsDrafts(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/pi-hole/docker-pi-hole/releases", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"tag_name": "2022.11", "draft": true}, {"tag_name": "2022.10"}, {"tag_name": "2022.09.4", "prerelease": true}]`))
+	})
+	g := newGithub(t, mux)
+
+	releases, err := g.ListVersions(context.Background(), Source{URL: "https://github.com/pi-hole/docker-pi-hole"})
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	if len(releases) != 2 || releases[0].Version != "2022.10" {
+		t.Fatalf("ListVersions() = %v, want [2022.10 2022.09.4]", releases)
+	}
+	if releases[0].Prerelease || !releases[1].Prerelease {
+		t.Errorf("ListVersions() = %v, want only 2022.09.4 flagged as pre-release", releases)
+	}
+}
+
+func TestGithubListVersionsPaginates(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/argoproj/argo-cd/releases", func(w http.ResponseWriter, r *http.Request) {
+		// Backport releases are published after newer minor versions
+		if r.URL.Query().Get("page") == "2" {
+			w.Write([]byte(`[{"tag_name": "v2.4.2"}]`))
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/argoproj/argo-cd/releases?page=2>; rel="next"`, r.Host))
+		w.Write([]byte(`[{"tag_name": "v2.2.15"}, {"tag_name": "v2.5.2"}]`))
+	})
+	g := newGithub(t, mux)
+
+	releases, err := g.ListVersions(context.Background(), Source{URL: "https://github.com/argoproj/argo-cd"})
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	if len(releases) != 3 || releases[2].Version != "v2.4.2" {
+		t.Errorf("ListVersions() = %v, want releases of both pages", releases)
+	}
+}
+
+func TestGithubListVersionsPageLimit(t *testing.T) {
+	mux := http.NewServeMux()
+	// Endless lists of one version per page, newest first
+	endless := func(w http.ResponseWriter, r *http.Request, item string) {
+		page := 1
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next"`, r.Host, r.URL.Path, page+1))
+		fmt.Fprintf(w, "[%s]", fmt.Sprintf(item, 100-page))
+	}
+	mux.HandleFunc("/repos/kubernetes/kubernetes/releases", func(w http.ResponseWriter, r *http.Request) {
+		endless(w, r, `{"tag_name": "v1.%d.0"}`)
+	})
+	mux.HandleFunc("/repos/kubernetes/kubernetes/tags", func(w http.ResponseWriter, r *http.Request) {
+		endless(w, r, `{"name": "v1.%d.0"}`)
+	})
+	g := newGithub(t, mux)
+
+	tests := map[string]struct {
+		mode      string
+		installed string
+		want      int
+		truncated bool
+	}{
+		"installed":       {installed: "v1.97.0", want: 3},
+		"without prefix":  {installed: "1.97.0", want: 3},
+		"wildcard":        {installed: "1.97.*", want: 3},
+		"empty installed": {want: githubMaxPages, truncated: true},
+		"older installed": {installed: "v1.2.0", want: githubMaxPages, truncated: true},
+		// Tags aren't listed newest first
+		"tags": {mode: GithubTags, installed: "v1.97.0", want: githubMaxPages, truncated: true},
+	}
+	for name, tt := range tests {
+		var parser *versions.Parser
+		src := Source{URL: "https://github.com/kubernetes/kubernetes", Mode: tt.mode, Version: tt.installed, Compare: parser.CompareInstalled(tt.installed)}
+		list, err := g.ListVersions(context.Background(), src)
+		if err != nil && !(tt.truncated && IsTruncated(err)) {
+			t.Errorf("%s: ListVersions() = %v", name, err)
+			continue
+		}
+		if len(list) != tt.want || IsTruncated(err) != tt.truncated {
+			t.Errorf("%s: ListVersions() = %v, %v, want %d versions, truncated %v", name, list, err, tt.want, tt.truncated)
+		}
+	}
+
+	// The highest tag listed is returned along with the truncation
+	latest, err := g.Latest(context.Background(), Source{URL: "https://github.com/kubernetes/kubernetes", Mode: GithubTags})
+	if !IsTruncated(err) || latest.Version != "v1.99.0" {
+		t.Errorf("Latest() = %s, %v, want v1.99.0 and the truncation", latest.Version, err)
+	}
+}
+
+func TestGithubModes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/argoproj/argo-cd/releases", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"tag_name": "v2.5.2"}, {"tag_name": "v2.4.17"}]`))
+	})
+	mux.HandleFunc("/repos/argoproj/argo-cd/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v2.4.17"}`))
+	})
+	mux.HandleFunc("/repos/argoproj/argo-cd/tags", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "v2.6.0-rc1"}, {"name": "v2.5.2"}, {"name": "v2.4.17"}]`))
+	})
+	mux.HandleFunc("/repos/torvalds/linux/releases", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("/repos/torvalds/linux/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not Found"}`))
+	})
+	mux.HandleFunc("/repos/torvalds/linux/tags", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "v6.1-rc1"}, {"name": "v6.0"}, {"name": "v5.19"}]`))
+	})
+	g := newGithub(t, mux)
+
+	tests := []struct {
+		source, mode string
+		want         []string
+		latest       string
+	}{
+		{source: "argoproj/argo-cd", mode: "", want: []string{"v2.5.2", "v2.4.17"}, latest: "v2.4.17"},
+		{source: "argoproj/argo-cd", mode: GithubTags, want: []string{"v2.6.0-rc1", "v2.5.2", "v2.4.17"}, latest: "v2.6.0-rc1"},
+		{source: "argoproj/argo-cd", mode: GithubLatest, want: []string{"v2.4.17"}, latest: "v2.4.17"},
+		// Repositories which only tag fall back to their tags
+		{source: "torvalds/linux", mode: GithubReleases, want: []string{"v6.1-rc1", "v6.0", "v5.19"}, latest: "v6.1-rc1"},
+	}
+	for _, tt := range tests {
+		src := Source{URL: "https://github.com/" + tt.source, Mode: tt.mode}
+		if err := g.Validate(src); err != nil {
+			t.Errorf("Validate(%s, %q) = %v", tt.source, tt.mode, err)
+		}
+		releases, err := g.ListVersions(context.Background(), src)
+		if err != nil {
+			t.Errorf("ListVersions(%s, %q) = %v", tt.source, tt.mode, err)
+			continue
+		}
+		var got []string
+		for _, r := range releases {
+			got = append(got, r.Version)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ListVersions(%s, %q) = %v, want %v", tt.source, tt.mode, got, tt.want)
+		}
+
+		latest, err := g.Latest(context.Background(), src)
+		if err != nil {
+			t.Errorf("Latest(%s, %q) = %v", tt.source, tt.mode, err)
+			continue
+		}
+		if latest.Version != tt.latest {
+			t.Errorf("Latest(%s, %q) = %s, want %s", tt.source, tt.mode, latest.Version, tt.latest)
+		}
+	}
+
+	if err := g.Validate(Source{URL: "https://github.com/argoproj/argo-cd", Mode: "branches"}); err == nil {
+		t.Errorf("Validate() accepted an unknown mode")
+	}
+	if _, err := g.ListVersions(context.Background(), Source{URL: "https://github.com/torvalds/linux", Mode: GithubLatest}); err == nil {
+		t.Errorf("ListVersions() in latest mode succeeded without latest release")
+	}
+}
+
+func TestGithubEnterpriseToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/platform/backup/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token ghp_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "Bad credentials"}`))
+			return
+		}
+		w.Write([]byte(`[{"tag_name": "v1.4.0"}]`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	g := NewGithub(nil)
+
+	// The API of Enterprise Servers is derived from the repository url
+	src := Source{URL: srv.URL + "/platform/backup", Credentials: map[string][]byte{"token": []byte("ghp_secret")}}
+	releases, err := g.ListVersions(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	if len(releases) != 1 || releases[0].Version != "v1.4.0" {
+		t.Errorf("ListVersions() = %v, want [v1.4.0]", releases)
+	}
+
+	src = Source{URL: "https://github.example.com/platform/backup", APIURL: srv.URL + "/api/v3"}
+	if _, err := g.ListVersions(context.Background(), src); err == nil || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Errorf("ListVersions() without token = %v, want Bad credentials", err)
+	}
+}
+
+func TestGithubApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tokens := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			t.Errorf("installation token requested without JWT")
+		}
+		tokens++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "ghs_installation", "expires_at": "%s"}`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	})
+	mux.HandleFunc("/repos/platform/backup/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token ghs_installation" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[{"tag_name": "v1.4.0"}]`))
+	})
+	g := newGithub(t, mux)
+
+	src := Source{URL: "https://github.com/platform/backup", Credentials: map[string][]byte{
+		"githubAppID":             []byte("7"),
+		"githubAppInstallationID": []byte("42"),
+		"githubAppPrivateKey":     pemKey,
+	}}
+	for i := 0; i < 2; i++ {
+		if _, err := g.ListVersions(context.Background(), src); err != nil {
+			t.Fatalf("ListVersions() = %v", err)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("requested %d installation tokens, want 1 reused across checks", tokens)
+	}
+
+	src.Credentials["githubAppPrivateKey"] = []byte("not a key")
+	if err := g.Validate(src); err == nil {
+		t.Errorf("Validate() accepted an invalid private key")
+	}
+}
+
+func TestGithubRateLimit(t *testing.T) {
+	reset := time.Now().Add(40 * time.Minute).Truncate(time.Second)
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/argoproj/argo-cd/releases", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "API rate limit exceeded for 203.0.113.1."}`))
+	})
+	g := newGithub(t, mux)
+
+	src := Source{URL: "https://github.com/argoproj/argo-cd"}
+	for i := 0; i < 2; i++ {
+		_, err := g.ListVersions(context.Background(), src)
+		got, ok := RateLimitReset(err)
+		if !ok || !got.Equal(reset) {
+			t.Errorf("RateLimitReset(%v) = %s, %v, want %s", err, got, ok, reset)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("sent %d requests, want none until the rate limit resets", requests)
+	}
+}
+
+func TestGithubRateReserve(t *testing.T) {
+	reset := time.Now().Add(40 * time.Minute).Truncate(time.Second)
+	remaining := 30
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/argoproj/argo-cd/releases", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		remaining -= 25
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining))
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		w.Write([]byte(`[{"tag_name": "v2.5.2"}]`))
+	})
+	g := newGithub(t, mux)
+
+	// 5 requests left are less than the tenth of the limit kept unused
+	src := Source{URL: "https://github.com/argoproj/argo-cd"}
+	if _, err := g.ListVersions(context.Background(), src); err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	_, err := g.ListVersions(context.Background(), src)
+	if got, ok := RateLimitReset(err); !ok || !got.Equal(reset) {
+		t.Errorf("RateLimitReset(%v) = %s, %v, want %s", err, got, ok, reset)
+	}
+	if requests != 1 {
+		t.Errorf("sent %d requests, want none once the rate limit runs low", requests)
+	}
+}
+
+func TestGithubClientEviction(t *testing.T) {
+	g := NewGithub(nil)
+	token := func(token string) Source {
+		return Source{URL: "https://github.com/argoproj/argo-cd", Credentials: map[string][]byte{"token": []byte(token)}}
+	}
+	first, err := g.client(token("ghp_first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := g.client(token("ghp_first")); c != first {
+		t.Errorf("client() created a second client for the same credentials")
+	}
+
+	for _, c := range g.clients {
+		c.used = c.used.Add(-githubClientIdle - time.Minute)
+	}
+	if _, err := g.client(token("ghp_second")); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.clients) != 1 {
+		t.Errorf("%d clients cached, want the idle one dropped", len(g.clients))
+	}
+}
+
+func TestParseGithubRepo(t *testing.T) {
+	tests := []struct {
+		source      string
+		owner, repo string
+		wantErr     bool
+	}{
+		{source: "https://github.com/argoproj/argo-cd", owner: "argoproj", repo: "argo-cd"},
+		{source: "https://github.com/argoproj/argo-cd.git", owner: "argoproj", repo: "argo-cd"},
+		{source: "https://github.com/argoproj/argo-cd/releases", owner: "argoproj", repo: "argo-cd"},
+		{source: "https://github.com/argoproj", wantErr: true},
+		{source: "argoproj/argo-cd", wantErr: true},
+	}
+	for _, tt := range tests {
+		owner, repo, err := parseGithubRepo(tt.source)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGithubRepo(%q) error = %v, wantErr %v", tt.source, err, tt.wantErr)
+			continue
+		}
+		if owner != tt.owner || repo != tt.repo {
+			t.Errorf("parseGithubRepo(%q) = %s/%s, want %s/%s", tt.source, owner, repo, tt.owner, tt.repo)
+		}
+	}
+}
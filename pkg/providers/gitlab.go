@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// gitlabAPI is the REST API of GitLab, which pages lists while the
+// X-Next-Page header is set.
+var gitlabAPI = forgeAPI{
+	name: "GitLab",
+	path: "/api/v4",
+	authorize: func(req *http.Request, token string) {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	},
+	page: pageQuery("per_page", 100),
+	more: func(resp *http.Response, _ int) bool {
+		return resp.Header.Get("X-Next-Page") != ""
+	},
+}
+
+// Gitlab looks up releases of a project on gitlab.com or a self-hosted
+// GitLab, falling back to its tags for projects which don't publish
+// releases.
+type Gitlab struct {
+	Client *http.Client
+}
+
+// NewGitlab returns a GitLab provider using httpClient, or
+// http.DefaultClient when nil.
+func NewGitlab(httpClient *http.Client) *Gitlab {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Gitlab{Client: httpClient}
+}
+
+// Validate checks the source is a GitLab project url.
+func (g *Gitlab) Validate(src Source) error {
+	_, _, err := parseGitlabProject(src)
+	return err
+}
+
+// ListVersions returns the releases of the project, without upcoming ones.
+func (g *Gitlab) ListVersions(ctx context.Context, src Source) ([]Release, error) {
+	releases, _, err := forgeList(ctx, g, src)
+	return releases, err
+}
+
+// Latest returns the most recently released version of the project.
+func (g *Gitlab) Latest(ctx context.Context, src Source) (Release, error) {
+	return forgeLatest(ctx, g, src)
+}
+
+func (g *Gitlab) releases(ctx context.Context, src Source) ([]Release, error) {
+	var list []Release
+	query := url.Values{"order_by": {"released_at"}, "sort": {"desc"}}
+	err := g.getPages(ctx, src, "/releases", query, func(body []byte) error {
+		var page []struct {
+			TagName    string    `json:"tag_name"`
+			ReleasedAt time.Time `json:"released_at"`
+			Upcoming   bool      `json:"upcoming_release"`
+		}
+		err := json.Unmarshal(body, &page)
+		for _, r := range page {
+			if !r.Upcoming {
+				list = append(list, Release{Version: r.TagName, Published: r.ReleasedAt})
+			}
+		}
+		return err
+	})
+	return list, err
+}
+
+func (g *Gitlab) tags(ctx context.Context, src Source) ([]Release, error) {
+	var list []Release
+	err := g.getPages(ctx, src, "/repository/tags", nil, func(body []byte) error {
+		var page []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				CreatedAt time.Time `json:"created_at"`
+			} `json:"commit"`
+		}
+		err := json.Unmarshal(body, &page)
+		for _, t := range page {
+			list = append(list, Release{Version: t.Name, Published: t.Commit.CreatedAt})
+		}
+		return err
+	})
+	return list, err
+}
+
+// getPages fetches the pages of a list endpoint of the project.
+func (g *Gitlab) getPages(ctx context.Context, src Source, endpoint string, query url.Values, decode func([]byte) error) error {
+	api, project, err := parseGitlabProject(src)
+	if err != nil {
+		return err
+	}
+	endpoint = fmt.Sprintf("%s/projects/%s%s", api, url.PathEscape(project), endpoint)
+	return gitlabAPI.getPages(ctx, g.Client, src, endpoint, query, decode)
+}
+
+// parseGitlabProject returns the API url and the path of the project,
+// including nested groups, e.g. https://gitlab.com/api/v4 and
+// gitlab-org/charts/gitlab-runner for
+// https://gitlab.com/gitlab-org/charts/gitlab-runner.
+func parseGitlabProject(src Source) (api, project string, err error) {
+	api, path, err := gitlabAPI.repository(src)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Links to pages of a project are separated from its path by "/-/"
+	path, _, _ = strings.Cut(path+"/", "/-/")
+	project = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if !strings.Contains(project, "/") {
+		return "", "", fmt.Errorf("invalid GitLab project url %q, expected https://gitlab.com/<group>/<project>", src.URL)
+	}
+	return api, project, nil
+}
@@ -0,0 +1,155 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newGitlab starts a stand-in for the GitLab API serving a project below
+// prefix, with releases and tags served two per page. Requests must carry
+// token, if set.
+func newGitlab(t *testing.T, prefix, project, token string, releases, tags []string) *httptest.Server {
+	t.Helper()
+	projectPath := prefix + "/api/v4/projects/" + strings.ReplaceAll(project, "/", "%2F")
+	return forgeServer{
+		authorized: func(r *http.Request) bool {
+			return token == "" || r.Header.Get("PRIVATE-TOKEN") == token
+		},
+		unauthorized: `{"message": "401 Unauthorized"}`,
+		notFound:     `{"message": "404 Project Not Found"}`,
+		lists: map[string][]string{
+			projectPath + "/releases":        releases,
+			projectPath + "/repository/tags": tags,
+		},
+		limit: 2,
+		next: func(w http.ResponseWriter, _ *http.Request, page int) {
+			w.Header().Set("X-Next-Page", fmt.Sprint(page))
+		},
+	}.start(t)
+}
+
+func TestGitlabListVersions(t *testing.T) {
+	releases := []string{
+		`{"tag_name": "v16.5.0", "released_at": "2022-11-20T00:00:00Z", "upcoming_release": true}`,
+		`{"tag_name": "v16.4.1", "released_at": "2022-10-20T00:00:00Z"}`,
+		`{"tag_name": "v16.4.0", "released_at": "2022-10-10T00:00:00Z"}`,
+	}
+	srv := newGitlab(t, "", "gitlab-org/charts/gitlab-runner", "", releases, nil)
+	src := Source{URL: srv.URL + "/gitlab-org/charts/gitlab-runner"}
+
+	g := NewGitlab(nil)
+	if err := g.Validate(src); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	list, err := g.ListVersions(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	if len(list) != 2 || list[0].Version != "v16.4.1" || list[1].Version != "v16.4.0" {
+		t.Fatalf("ListVersions() = %v, want [v16.4.1 v16.4.0]", list)
+	}
+	if list[0].Published.IsZero() {
+		t.Errorf("ListVersions() didn't report when v16.4.1 was released")
+	}
+
+	latest, err := g.Latest(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Latest() = %v", err)
+	}
+	if latest.Version != "v16.4.1" {
+		t.Errorf("Latest() = %s, want v16.4.1", latest.Version)
+	}
+}
+
+func TestGitlabFallsBackToTags(t *testing.T) {
+	tags := []string{
+		`{"name": "1.2.0", "commit": {"created_at": "2022-10-01T00:00:00Z"}}`,
+		`{"name": "1.10.0", "commit": {"created_at": "2022-11-01T00:00:00Z"}}`,
+		`{"name": "nightly"}`,
+	}
+	srv := newGitlab(t, "/gitlab", "infra/tools/backup", "glpat-secret", nil, tags)
+	src := Source{
+		URL:         srv.URL + "/gitlab/infra/tools/backup/-/tags",
+		APIURL:      srv.URL + "/gitlab/api/v4",
+		Credentials: map[string][]byte{"token": []byte("glpat-secret")},
+	}
+
+	g := NewGitlab(nil)
+	list, err := g.ListVersions(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("ListVersions() = %v, want all 3 tags", list)
+	}
+
+	latest, err := g.Latest(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Latest() = %v", err)
+	}
+	if latest.Version != "1.10.0" {
+		t.Errorf("Latest() = %s, want 1.10.0", latest.Version)
+	}
+}
+
+func TestGitlabErrors(t *testing.T) {
+	srv := newGitlab(t, "", "infra/backup", "glpat-secret", nil, nil)
+	g := NewGitlab(nil)
+
+	_, err := g.ListVersions(context.Background(), Source{URL: srv.URL + "/infra/backup"})
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("ListVersions() without token = %v, want 401 Unauthorized", err)
+	}
+
+	creds := map[string][]byte{"token": []byte("glpat-secret")}
+	_, err = g.ListVersions(context.Background(), Source{URL: srv.URL + "/infra/restore", Credentials: creds})
+	if err == nil || !strings.Contains(err.Error(), "404 Project Not Found") {
+		t.Errorf("ListVersions() of missing project = %v, want 404 Project Not Found", err)
+	}
+}
+
+func TestParseGitlabProject(t *testing.T) {
+	tests := []struct {
+		src          Source
+		api, project string
+		wantErr      bool
+	}{
+		{
+			src:     Source{URL: "https://gitlab.com/gitlab-org/gitlab-runner"},
+			api:     "https://gitlab.com/api/v4",
+			project: "gitlab-org/gitlab-runner",
+		},
+		{
+			src:     Source{URL: "https://gitlab.com/gitlab-org/charts/gitlab-runner.git"},
+			api:     "https://gitlab.com/api/v4",
+			project: "gitlab-org/charts/gitlab-runner",
+		},
+		{
+			src:     Source{URL: "https://gitlab.com/gitlab-org/charts/gitlab-runner/-/releases"},
+			api:     "https://gitlab.com/api/v4",
+			project: "gitlab-org/charts/gitlab-runner",
+		},
+		{
+			src:     Source{URL: "https://example.com/gitlab/infra/backup", APIURL: "https://example.com/gitlab/api/v4/"},
+			api:     "https://example.com/gitlab/api/v4",
+			project: "infra/backup",
+		},
+		{src: Source{URL: "https://gitlab.com/gitlab-org"}, wantErr: true},
+		{src: Source{URL: "gitlab-org/gitlab-runner"}, wantErr: true},
+		{src: Source{URL: "https://gitlab.com/gitlab-org/gitlab-runner", APIURL: "/api/v4"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		api, project, err := parseGitlabProject(tt.src)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGitlabProject(%+v) error = %v, wantErr %v", tt.src, err, tt.wantErr)
+			continue
+		}
+		if api != tt.api || project != tt.project {
+			t.Errorf("parseGitlabProject(%+v) = %s, %s, want %s, %s", tt.src, api, project, tt.api, tt.project)
+		}
+	}
+}
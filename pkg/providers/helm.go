@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/getais/kupdater/pkg/libs/helm"
+)
+
+// Helm looks up chart versions in a Helm repository index.
+type Helm struct{}
+
+// NewHelm returns a Helm repository provider.
+func NewHelm() *Helm {
+	return &Helm{}
+}
+
+// Validate checks the source is an http(s) Helm repository url.
+func (h *Helm) Validate(src Source) error {
+	u, err := url.ParseRequestURI(src.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid Helm repository url %q", src.URL)
+	}
+	if src.Name == "" {
+		return fmt.Errorf("missing chart name for Helm repository %s", src.URL)
+	}
+	return nil
+}
+
+// ListVersions returns every version of the chart in the repository index,
+// in the order they appear in index.yaml.
+func (h *Helm) ListVersions(ctx context.Context, src Source) ([]Release, error) {
+	var Helm helm.Helm
+
+	Repo, err := Helm.GetReleases(src.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	Entries, ok := Repo.Entries[src.Name]
+	if !ok {
+		return nil, fmt.Errorf("chart %q not found in %s", src.Name, src.URL)
+	}
+
+	releases := make([]Release, 0, len(Entries))
+	for _, e := range Entries {
+		releases = append(releases, Release{Version: e.Version, Published: e.Created, Deprecated: e.Deprecated})
+	}
+	return releases, nil
+}
+
+// Latest returns the highest chart version which isn't deprecated.
+func (h *Helm) Latest(ctx context.Context, src Source) (Release, error) {
+	releases, err := h.ListVersions(ctx, src)
+	if err != nil {
+		return Release{}, err
+	}
+	return highestRelease(releases, src.URL)
+}
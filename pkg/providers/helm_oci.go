@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/getais/kupdater/pkg/libs/registry"
+)
+
+// HelmOCI looks up versions of a Helm chart published as OCI artifact, e.g.
+// oci://registry-1.docker.io/bitnamicharts/nginx.
+type HelmOCI struct{}
+
+// NewHelmOCI returns an OCI Helm chart provider.
+func NewHelmOCI() *HelmOCI {
+	return &HelmOCI{}
+}
+
+// Validate checks the source is a valid OCI chart repository.
+func (h *HelmOCI) Validate(src Source) error {
+	_, err := chartReference(src)
+	return err
+}
+
+// ListVersions returns every chart version pushed to the registry.
+func (h *HelmOCI) ListVersions(ctx context.Context, src Source) ([]Release, error) {
+	ref, err := chartReference(src)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := registryClient(src, ref)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := client.ListTags(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]Release, 0, len(tags))
+	for _, t := range tags {
+		// OCI tags can't contain "+", Helm pushes build metadata with "_"
+		releases = append(releases, Release{Version: strings.ReplaceAll(t, "_", "+")})
+	}
+	return releases, nil
+}
+
+// Latest returns the highest chart version.
+func (h *HelmOCI) Latest(ctx context.Context, src Source) (Release, error) {
+	releases, err := h.ListVersions(ctx, src)
+	if err != nil {
+		return Release{}, err
+	}
+	return highestRelease(releases, src.URL)
+}
+
+// chartReference returns the registry repository of the chart, which the
+// source url names in full, e.g. oci://registry-1.docker.io/bitnamicharts/nginx.
+// The url must not pin a tag or digest, the chart version is tracked apart.
+func chartReference(src Source) (registry.Reference, error) {
+	ref, err := registry.ParseReference(src.URL)
+	if err != nil {
+		return ref, fmt.Errorf("invalid OCI chart repository %q: %w", src.URL, err)
+	}
+	repository := src.URL
+	if _, rest, ok := strings.Cut(repository, "://"); ok {
+		repository = rest
+	}
+	if strings.Contains(repository, "@") {
+		return ref, fmt.Errorf("invalid OCI chart repository %q, expected no digest", src.URL)
+	}
+	if _, tag := registry.SplitImage(strings.TrimSuffix(repository, "/")); tag != "" {
+		return ref, fmt.Errorf("invalid OCI chart repository %q, expected no tag", src.URL)
+	}
+	return ref, nil
+}
@@ -0,0 +1,152 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const traefikIndex = `apiVersion: v1
+entries:
+  traefik:
+  - name: traefik
+    version: 17.0.5
+    created: "2022-10-10T08:00:00Z"
+  - name: traefik
+    version: 17.0.4
+    created: "2022-10-01T08:00:00Z"
+`
+
+func newHelmRepo(t *testing.T, index string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(index))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHelmLatest(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart string
+		index string
+		want  string
+	}{
+		{
+			name:  "sorted index",
+			chart: "traefik",
+			index: traefikIndex,
+			want:  "17.0.5",
+		},
+		{
+			name:  "unsorted index with v prefixes",
+			chart: "kyverno",
+			index: `apiVersion: v1
+entries:
+  kyverno:
+  - version: v2.1.10
+  - version: 2.6.1-rc1
+  - version: v2.5.3
+  - version: 2.6.0
+`,
+			want: "2.6.1-rc1",
+		},
+		{
+			name:  "deprecated and unparsable versions",
+			chart: "loki",
+			index: `apiVersion: v1
+entries:
+  loki:
+  - version: 3.0.0
+    deprecated: true
+  - version: latest
+  - version: 2.8.3
+  - version: 2.16.0
+`,
+			want: "2.16.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newHelmRepo(t, tt.index)
+			src := Source{Name: tt.chart, URL: srv.URL}
+
+			h := NewHelm()
+			if err := h.Validate(src); err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			release, err := h.Latest(context.Background(), src)
+			if err != nil {
+				t.Fatalf("Latest() = %v", err)
+			}
+			if release.Version != tt.want {
+				t.Errorf("Latest() = %s, want %s", release.Version, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelmErrors(t *testing.T) {
+	srv := newHelmRepo(t, traefikIndex)
+	h := NewHelm()
+
+	if _, err := h.ListVersions(context.Background(), Source{Name: "missing", URL: srv.URL}); err == nil {
+		t.Error("ListVersions() of a missing chart succeeded")
+	}
+	if _, err := h.ListVersions(context.Background(), Source{Name: "traefik", URL: srv.URL + "/nope"}); err == nil {
+		t.Error("ListVersions() of a missing repository succeeded")
+	}
+	if err := h.Validate(Source{Name: "traefik", URL: "oci://ghcr.io/traefik"}); err == nil {
+		t.Error("Validate() accepted a non http repository")
+	}
+}
+
+func TestHelmOCIListVersions(t *testing.T) {
+	srv := newRegistry(t, "bitnamicharts/nginx", []string{"13.2.9", "13.2.10_1", "13.2.8"})
+	src := Source{Name: "nginx", URL: srv.URL + "/bitnamicharts/nginx"}
+
+	h := NewHelmOCI()
+	releases, err := h.ListVersions(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	if got := releases[1].Version; got != "13.2.10+1" {
+		t.Errorf("ListVersions() converted tag to %s, want 13.2.10+1", got)
+	}
+
+	latest, err := h.Latest(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Latest() = %v", err)
+	}
+	if latest.Version != "13.2.10+1" {
+		t.Errorf("Latest() = %s, want 13.2.10+1", latest.Version)
+	}
+}
+
+func TestChartReference(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"oci://registry-1.docker.io/bitnamicharts/nginx", "registry-1.docker.io/bitnamicharts/nginx"},
+		{"ghcr.io/getais/charts/kupdater", "ghcr.io/getais/charts/kupdater"},
+		{"oci://localhost:5000/nginx/", "localhost:5000/nginx"},
+	}
+	for _, tt := range tests {
+		ref, err := chartReference(Source{Name: "nginx", URL: tt.url})
+		if err != nil || ref.String() != tt.want {
+			t.Errorf("chartReference(%s) = %s, %v, want %s", tt.url, ref, err, tt.want)
+		}
+	}
+
+	for _, url := range []string{"oci://ghcr.io/getais/charts/kupdater:0.3.0", "oci://ghcr.io/getais/charts/kupdater@sha256:0123", "oci://", "oci://ghcr.io/Charts"} {
+		if _, err := chartReference(Source{URL: url}); err == nil {
+			t.Errorf("chartReference(%s) succeeded", url)
+		}
+	}
+}
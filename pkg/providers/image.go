@@ -0,0 +1,93 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/getais/kupdater/pkg/libs/registry"
+	"github.com/getais/kupdater/pkg/versions"
+)
+
+// Image looks up tags of a container image in an OCI registry.
+type Image struct{}
+
+// NewImage returns a container image provider.
+func NewImage() *Image {
+	return &Image{}
+}
+
+// Validate checks the source is a valid image reference.
+func (i *Image) Validate(src Source) error {
+	_, err := registry.ParseReference(src.URL)
+	return err
+}
+
+// ListVersions returns every tag of the image.
+func (i *Image) ListVersions(ctx context.Context, src Source) ([]Release, error) {
+	ref, err := registry.ParseReference(src.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := registryClient(src, ref)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := client.ListTags(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]Release, 0, len(tags))
+	for _, t := range tags {
+		releases = append(releases, Release{Version: t})
+	}
+	return releases, nil
+}
+
+// Latest returns the highest semantic version among the image tags.
+func (i *Image) Latest(ctx context.Context, src Source) (Release, error) {
+	releases, err := i.ListVersions(ctx, src)
+	if err != nil {
+		return Release{}, err
+	}
+	return highestRelease(releases, src.URL)
+}
+
+// registryClient returns a client using the source credentials, which are
+// either a username/password pair or an image pull secret in the
+// .dockerconfigjson or legacy .dockercfg format.
+func registryClient(src Source, ref registry.Reference) (*registry.Client, error) {
+	username, password := string(src.Credentials["username"]), string(src.Credentials["password"])
+	var err error
+	if config, ok := src.Credentials[".dockerconfigjson"]; ok {
+		username, password, err = registry.DockerConfigCredentials(config, ref.Registry)
+	} else if config, ok := src.Credentials[".dockercfg"]; ok {
+		username, password, err = registry.DockerCfgCredentials(config, ref.Registry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return registry.NewClient(username, password), nil
+}
+
+// highestRelease returns the release with the highest semantic version,
+// ignoring deprecated releases and tags such as "latest" which aren't
+// versions.
+func highestRelease(releases []Release, source string) (Release, error) {
+	byVersion := make(map[string]Release, len(releases))
+	available := make([]string, 0, len(releases))
+	for _, r := range releases {
+		if r.Deprecated {
+			continue
+		}
+		byVersion[r.Version] = r
+		available = append(available, r.Version)
+	}
+
+	sorted, _ := versions.Sort(available)
+	if len(sorted) == 0 {
+		return Release{}, fmt.Errorf("no versioned releases found in %s", source)
+	}
+	return byVersion[sorted[0].Original], nil
+}
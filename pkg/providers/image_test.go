@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newRegistry starts a stand-in for a registry:2 instance requiring a
+// bearer token and serving tags two per page.
+func newRegistry(t *testing.T, repository string, tags []string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("scope"); got != "repository:"+repository+":pull" {
+			t.Errorf("token scope = %q", got)
+		}
+		fmt.Fprint(w, `{"token": "secret"}`)
+	})
+	mux.HandleFunc("/v2/"+repository+"/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		start := 0
+		if last := r.URL.Query().Get("last"); last != "" {
+			for i, tag := range tags {
+				if tag == last {
+					start = i + 1
+				}
+			}
+		}
+		end := start + 2
+		if end < len(tags) {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?last=%s&n=2>; rel="next"`, repository, tags[end-1]))
+		} else {
+			end = len(tags)
+		}
+		fmt.Fprintf(w, `{"name": %q, "tags": ["%s"]}`, repository, strings.Join(tags[start:end], `","`))
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestImageListVersions(t *testing.T) {
+	tags := []string{"1.22.0", "1.23.1", "latest", "1.23.2-alpine", "1.23.2"}
+	srv := newRegistry(t, "getais/kupdater", tags)
+	src := Source{URL: srv.URL + "/getais/kupdater"}
+
+	i := NewImage()
+	if err := i.Validate(src); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	releases, err := i.ListVersions(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ListVersions() = %v", err)
+	}
+	if len(releases) != len(tags) {
+		t.Fatalf("ListVersions() returned %d tags, want %d", len(releases), len(tags))
+	}
+
+	latest, err := i.Latest(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Latest() = %v", err)
+	}
+	if latest.Version != "1.23.2" {
+		t.Errorf("Latest() = %s, want 1.23.2", latest.Version)
+	}
+}
@@ -0,0 +1,58 @@
+// Package providers implements lookups of published versions for every
+// source type an Update can track.
+package providers
+
+import (
+	"context"
+	"time"
+)
+
+// Source describes where to look for versions of an application.
+type Source struct {
+	// Name of the application, e.g. the chart name inside a Helm repository.
+	Name string
+	// URL of the source, e.g. a Helm repository or Github project url.
+	URL string
+	// APIURL is the API endpoint of forges hosting the source, for
+	// instances the API url can't be derived from URL for.
+	APIURL string
+	// Version currently deployed.
+	Version string
+	// Compare compares a version with the installed one according to the
+	// ordering of the source, returning -1, 0 or 1 when it is older, the
+	// same or newer, and false when they can't be compared. Providers listing
+	// versions newest first stop once past the installed version, nil lists
+	// every version.
+	Compare func(version string) (int, bool)
+	// Mode selects how sources offering several ways of listing versions
+	// are looked up, e.g. the releases or the tags of a Github repository.
+	// Empty selects the default of the provider.
+	Mode string
+	// Credentials holds the data of the Secret referenced by the source,
+	// if any. Each provider documents the keys it understands.
+	Credentials map[string][]byte
+}
+
+// Release is a single version published by a source.
+type Release struct {
+	Version   string
+	Published time.Time
+	// Deprecated releases are never suggested as updates.
+	Deprecated bool
+	// Prerelease is set when the source flags the release as pre-release,
+	// independently of its version.
+	Prerelease bool
+}
+
+// Provider looks up versions published by one type of source.
+type Provider interface {
+	// Validate checks the source is one the provider is able to query.
+	Validate(src Source) error
+	// ListVersions returns every release published by the source. Sources
+	// with more releases than are listed return the ones listed along with
+	// a TruncatedError.
+	ListVersions(ctx context.Context, src Source) ([]Release, error)
+	// Latest returns the most recent release published by the source, along
+	// with a TruncatedError when it was picked out of a truncated list.
+	Latest(ctx context.Context, src Source) (Release, error)
+}
@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Registry maps source types to the provider handling them.
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{providers: map[string]Provider{}}
+}
+
+// Default returns a Registry with every built-in provider registered.
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register(NewHelm(), "helm")
+	r.Register(NewGithub(nil), "github")
+	r.Register(NewGitlab(nil), "gitlab")
+	r.Register(NewGitea(nil), "gitea", "forgejo")
+	r.Register(NewGit(), "git")
+	r.Register(NewImage(), "oci", "image")
+	r.Register(NewHelmOCI(), "helm-oci")
+	return r
+}
+
+// Register makes p handle sources of the given types. Registering a type
+// twice replaces the previous provider.
+func (r *Registry) Register(p Provider, types ...string) {
+	for _, t := range types {
+		r.providers[Normalize(t)] = p
+	}
+}
+
+// Get returns the provider for a source type.
+func (r *Registry) Get(typ string) (Provider, error) {
+	p, ok := r.providers[Normalize(typ)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported source type %q, expected one of: %s", typ, strings.Join(r.Types(), ", "))
+	}
+	return p, nil
+}
+
+// Types returns the registered source types in alphabetical order.
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.providers))
+	for t := range r.providers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Normalize returns the canonical form of a source type, so "Helm" and
+// "helm" are handled by the same provider.
+func Normalize(typ string) string {
+	return strings.ToLower(strings.TrimSpace(typ))
+}
@@ -0,0 +1,89 @@
+package versions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Release channels, from the most to the least conservative.
+const (
+	// Stable only includes final releases.
+	Stable = "stable"
+	// Beta adds betas, release candidates and previews.
+	Beta = "beta"
+	// Alpha adds every pre-release, including alphas and nightly builds.
+	Alpha = "alpha"
+)
+
+var channelRank = map[string]int{Stable: 0, Beta: 1, Alpha: 2}
+
+// Pre-release identifiers, with any numbering stripped, and the channel
+// they're published on. Other identifiers such as "ls78" or "alpine" are
+// build variants of a final release.
+var preReleaseChannels = map[string]string{
+	"alpha":        Alpha,
+	"a":            Alpha,
+	"dev":          Alpha,
+	"develop":      Alpha,
+	"snapshot":     Alpha,
+	"nightly":      Alpha,
+	"canary":       Alpha,
+	"edge":         Alpha,
+	"experimental": Alpha,
+	"beta":         Beta,
+	"b":            Beta,
+	"rc":           Beta,
+	"cr":           Beta,
+	"pre":          Beta,
+	"preview":      Beta,
+}
+
+var identifierSeparator = regexp.MustCompile(`[-._+]`)
+
+// ParseChannel validates a channel name. Including pre-releases is the same
+// as following the alpha channel, the default is stable.
+func ParseChannel(name string, includePrereleases bool) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = Stable
+	}
+	if _, ok := channelRank[name]; !ok {
+		return "", fmt.Errorf("unknown channel %q, expected one of: stable, beta, alpha", name)
+	}
+	if includePrereleases {
+		return Alpha, nil
+	}
+	return name, nil
+}
+
+// Stability returns the channel a version is published on, based on its
+// pre-release identifiers, e.g. "2.6.1-rc1" is a beta.
+func Stability(version string) string {
+	suffix := version
+	if v, err := Parse(version); err == nil {
+		suffix = v.Prerelease()
+	}
+
+	stability := Stable
+	for _, id := range identifierSeparator.Split(strings.ToLower(suffix), -1) {
+		if c, ok := preReleaseChannels[strings.TrimRight(id, "0123456789")]; ok && channelRank[c] > channelRank[stability] {
+			stability = c
+		}
+	}
+	return stability
+}
+
+// InChannel reports whether a version with the given stability is
+// published on channel.
+func InChannel(channel, stability string) bool {
+	return channelRank[stability] <= channelRank[channel]
+}
+
+// Least returns the least stable of two channels.
+func Least(a, b string) string {
+	if channelRank[a] > channelRank[b] {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,45 @@
+package versions
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// TagFilter selects the tags considered as versions.
+type TagFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// NewTagFilter returns a filter keeping tags matching include, if set, and
+// not matching exclude, if set. Nil is returned when neither is set.
+func NewTagFilter(include, exclude string) (*TagFilter, error) {
+	if include == "" && exclude == "" {
+		return nil, nil
+	}
+
+	f := &TagFilter{}
+	var err error
+	if include != "" {
+		if f.include, err = regexp.Compile(include); err != nil {
+			return nil, fmt.Errorf("invalid tag include expression: %w", err)
+		}
+	}
+	if exclude != "" {
+		if f.exclude, err = regexp.Compile(exclude); err != nil {
+			return nil, fmt.Errorf("invalid tag exclude expression: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether a tag passes the filter.
+func (f *TagFilter) Match(tag string) bool {
+	if f == nil {
+		return true
+	}
+	if f.include != nil && !f.include.MatchString(tag) {
+		return false
+	}
+	return f.exclude == nil || !f.exclude.MatchString(tag)
+}
@@ -0,0 +1,192 @@
+package versions
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// Orderings versions can be compared with.
+const (
+	// Semver compares semantic versions, e.g. 2.6.1-rc1.
+	Semver = "semver"
+	// Calver compares calendar versions, e.g. 2022.10 or 2022-10-01.
+	Calver = "calver"
+	// NumericDotted compares any number of dot separated numbers, e.g. 3.0.9.1549.
+	NumericDotted = "numeric-dotted"
+	// Lexical compares versions as plain strings.
+	Lexical = "lexical"
+)
+
+// Version is a tag parsed according to an ordering.
+type Version struct {
+	// Original is the tag the version was parsed from.
+	Original string
+
+	semver  *semver.Version
+	numbers []int64
+	text    string
+}
+
+// Semver returns the semantic version, nil unless parsed with Semver ordering.
+func (v *Version) Semver() *semver.Version {
+	return v.semver
+}
+
+// Compare returns -1, 0 or 1 when v is older, the same or newer than o.
+// Both versions have to be parsed by the same Parser.
+func (v *Version) Compare(o *Version) int {
+	switch {
+	case v.semver != nil && o.semver != nil:
+		return v.semver.Compare(o.semver)
+	case v.numbers != nil && o.numbers != nil:
+		for i := 0; i < len(v.numbers) || i < len(o.numbers); i++ {
+			a, b := segment(v.numbers, i), segment(o.numbers, i)
+			if a != b {
+				if a < b {
+					return -1
+				}
+				return 1
+			}
+		}
+		return 0
+	}
+	return strings.Compare(v.text, o.text)
+}
+
+// segment returns the i-th number of a version, e.g. 1 for the minor
+// version of 2.1.0, missing segments being 0.
+func (v *Version) segment(i int) (int64, bool) {
+	switch {
+	case v.semver != nil:
+		return []int64{v.semver.Major(), v.semver.Minor(), v.semver.Patch()}[i], true
+	case v.numbers != nil:
+		return segment(v.numbers, i), true
+	}
+	return 0, false
+}
+
+func segment(numbers []int64, i int) int64 {
+	if i < len(numbers) {
+		return numbers[i]
+	}
+	return 0
+}
+
+// Parser turns tags into comparable versions.
+type Parser struct {
+	ordering string
+	extract  *regexp.Regexp
+}
+
+var defaultParser = &Parser{ordering: Semver}
+
+// NewParser returns a Parser comparing versions with ordering, semver by
+// default. When extract is set, only the part of the tag matched by it is
+// compared: either the capture group named "version", or all named capture
+// groups joined by dots.
+func NewParser(ordering, extract string) (*Parser, error) {
+	p := &Parser{ordering: strings.ToLower(strings.TrimSpace(ordering))}
+	switch p.ordering {
+	case "":
+		p.ordering = Semver
+	case Semver, Calver, NumericDotted, Lexical:
+	default:
+		return nil, fmt.Errorf("unknown ordering %q, expected one of: semver, calver, numeric-dotted, lexical", ordering)
+	}
+
+	if extract != "" {
+		re, err := regexp.Compile(extract)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version extract expression: %w", err)
+		}
+		named := false
+		for _, n := range re.SubexpNames() {
+			named = named || n != ""
+		}
+		if !named {
+			return nil, fmt.Errorf("version extract expression %q has no named capture group", extract)
+		}
+		p.extract = re
+	}
+	return p, nil
+}
+
+// Ordering returns the ordering used to compare versions.
+func (p *Parser) Ordering() string {
+	if p == nil {
+		return Semver
+	}
+	return p.ordering
+}
+
+// Parse parses a tag into a version.
+func (p *Parser) Parse(tag string) (*Version, error) {
+	if p == nil {
+		p = defaultParser
+	}
+
+	s, err := p.extractVersion(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Version{Original: tag}
+	switch p.ordering {
+	case Semver:
+		v.semver, err = Parse(s)
+	case Calver:
+		v.numbers, err = parseNumbers(s, calverSeparator)
+	case NumericDotted:
+		v.numbers, err = parseNumbers(s, dotSeparator)
+	case Lexical:
+		v.text = s
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a %s version", tag, p.ordering)
+	}
+	return v, nil
+}
+
+func (p *Parser) extractVersion(tag string) (string, error) {
+	if p.extract == nil {
+		return tag, nil
+	}
+
+	m := p.extract.FindStringSubmatch(tag)
+	if m == nil {
+		return "", fmt.Errorf("%q does not match %s", tag, p.extract)
+	}
+	if i := p.extract.SubexpIndex("version"); i > 0 {
+		return m[i], nil
+	}
+
+	var parts []string
+	for i, name := range p.extract.SubexpNames() {
+		if name != "" && m[i] != "" {
+			parts = append(parts, m[i])
+		}
+	}
+	return strings.Join(parts, "."), nil
+}
+
+var (
+	calverSeparator = regexp.MustCompile(`[-._]`)
+	dotSeparator    = regexp.MustCompile(`\.`)
+)
+
+func parseNumbers(s string, separator *regexp.Regexp) ([]int64, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	var numbers []int64
+	for _, part := range separator.Split(s, -1) {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
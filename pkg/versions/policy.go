@@ -0,0 +1,100 @@
+package versions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// Policies which don't need a semantic version range.
+const (
+	// PatchOnly allows updates within the installed major and minor version.
+	PatchOnly = "patch-only"
+	// MinorOnly allows updates within the installed major version.
+	MinorOnly = "minor-only"
+)
+
+// Policy limits which versions an application may be updated to.
+type Policy struct {
+	expr       string
+	constraint *semver.Constraints
+}
+
+// NewPolicy parses a policy, which is either one of PatchOnly, MinorOnly or
+// a semantic version range such as "~1.2" or ">=2 <3". An empty expression
+// returns a nil Policy, allowing every version.
+func NewPolicy(expr string) (*Policy, error) {
+	expr = strings.TrimSpace(expr)
+	switch strings.ToLower(expr) {
+	case "":
+		return nil, nil
+	case PatchOnly, MinorOnly:
+		return &Policy{expr: strings.ToLower(expr)}, nil
+	}
+
+	c, err := semver.NewConstraint(normalizeConstraint(expr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %w", expr, err)
+	}
+	return &Policy{expr: expr, constraint: c}, nil
+}
+
+// String returns the policy expression.
+func (p *Policy) String() string {
+	if p == nil {
+		return ""
+	}
+	return p.expr
+}
+
+// Allows reports whether the policy permits running version v when current
+// is installed. Policies relative to the installed version allow everything
+// when it is unknown.
+func (p *Policy) Allows(current, v *Version) bool {
+	switch {
+	case p == nil:
+		return true
+	case p.constraint != nil:
+		return v.Semver() != nil && p.constraint.Check(v.Semver())
+	case current == nil:
+		return true
+	case p.expr == PatchOnly:
+		return sameSegments(current, v, 2)
+	case p.expr == MinorOnly:
+		return sameSegments(current, v, 1)
+	}
+	return true
+}
+
+// sameSegments reports whether the first n numbers of two versions match.
+func sameSegments(a, b *Version, n int) bool {
+	for i := 0; i < n; i++ {
+		x, okA := a.segment(i)
+		y, okB := b.segment(i)
+		if !okA || !okB || x != y {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	// andSeparator matches the whitespace between two comparisons of a range.
+	andSeparator = regexp.MustCompile(`([0-9xX*])\s+([<>=!~^])`)
+	// partialLessThan matches upper bounds missing minor or patch version.
+	partialLessThan = regexp.MustCompile(`<\s*v?(\d+(?:\.\d+)?)([^.\d]|$)`)
+)
+
+// normalizeConstraint rewrites ranges written as ">=2 <3" into the comma
+// separated form understood by the semver library. Partial upper bounds are
+// completed, as the library treats "<3" like "<3.x" and would allow 3.1.0.
+func normalizeConstraint(c string) string {
+	c = andSeparator.ReplaceAllString(strings.TrimSpace(c), "$1, $2")
+	return partialLessThan.ReplaceAllStringFunc(c, func(m string) string {
+		sub := partialLessThan.FindStringSubmatch(m)
+		version := sub[1] + strings.Repeat(".0", 2-strings.Count(sub[1], "."))
+		return "<" + version + sub[2]
+	})
+}
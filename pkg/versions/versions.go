@@ -0,0 +1,219 @@
+// Package versions compares versions published by sources against the
+// installed one and decides what an application can be updated to.
+package versions
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// ErrNoVersions is returned when none of the available versions could be
+// parsed.
+var ErrNoVersions = errors.New("no comparable versions available")
+
+// Result describes the versions resolved for a source.
+type Result struct {
+	// Current is the installed version, with wildcards resolved to the
+	// newest available version they match.
+	Current string
+	// Latest is the newest available version.
+	Latest string
+	// LatestInPolicy is the newest version the policy allows updating to.
+	LatestInPolicy string
+	// Outdated is set when LatestInPolicy is newer than Current.
+	Outdated bool
+	// Behind counts the available versions newer than Current which the
+	// policy allows updating to.
+	Behind int
+	// Skipped lists available versions which couldn't be parsed.
+	Skipped []string
+}
+
+// Options customise how available versions are selected and compared.
+type Options struct {
+	// Policy limits the versions which may be updated to, nil allows all.
+	Policy *Policy
+	// Filter selects the tags considered, nil considers all.
+	Filter *TagFilter
+	// Parser parses tags, nil parses them as semantic versions.
+	Parser *Parser
+	// Ceiling is the newest version which may be updated to regardless of
+	// the policy, e.g. the version promoted from a previous stage. It may
+	// be a wildcard like the installed version, empty allows all versions.
+	Ceiling string
+}
+
+// Parse parses a semantic version, tolerating a leading "v".
+func Parse(v string) (*semver.Version, error) {
+	return semver.NewVersion(strings.TrimSpace(v))
+}
+
+// Sort parses the available versions as semantic versions and returns them
+// newest first, see Parser.Sort.
+func Sort(available []string) (sorted []*Version, skipped []string) {
+	return defaultParser.Sort(available)
+}
+
+// Sort parses the available versions and returns them newest first,
+// regardless of the order they were published in. Versions which can't be
+// parsed are returned separately.
+func (p *Parser) Sort(available []string) (sorted []*Version, skipped []string) {
+	sorted = make([]*Version, 0, len(available))
+	for _, a := range available {
+		v, err := p.Parse(a)
+		if err != nil {
+			skipped = append(skipped, a)
+			continue
+		}
+		sorted = append(sorted, v)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Compare(sorted[j]) > 0
+	})
+	return sorted, skipped
+}
+
+// Resolve compares the installed version with the available ones. With
+// semver ordering the installed version may be a wildcard or range such as
+// "34.*", which is resolved to the newest available version it matches.
+func Resolve(installed string, available []string, opts Options) (Result, error) {
+	if err := opts.Policy.supports(opts.Parser.Ordering()); err != nil {
+		return Result{}, err
+	}
+
+	tags := make([]string, 0, len(available))
+	for _, a := range available {
+		if opts.Filter.Match(a) {
+			tags = append(tags, a)
+		}
+	}
+
+	sorted, skipped := opts.Parser.Sort(tags)
+	if len(sorted) == 0 {
+		return Result{Skipped: skipped}, ErrNoVersions
+	}
+
+	res := Result{
+		Current: installed,
+		Latest:  sorted[0].Original,
+		Skipped: skipped,
+	}
+
+	current := resolveInstalled(installed, sorted, opts.Parser)
+	if current != nil {
+		res.Current = current.Original
+	}
+
+	var ceiling *Version
+	if opts.Ceiling != "" {
+		if ceiling = resolveInstalled(opts.Ceiling, sorted, opts.Parser); ceiling == nil {
+			return Result{}, fmt.Errorf("can't compare versions with %s", opts.Ceiling)
+		}
+	}
+
+	for _, v := range sorted {
+		if !opts.Policy.Allows(current, v) || (ceiling != nil && v.Compare(ceiling) > 0) {
+			continue
+		}
+		if res.LatestInPolicy == "" {
+			res.LatestInPolicy = v.Original
+		}
+		if current == nil {
+			// Versions we can't parse are compared literally
+			res.Outdated = v.Original != installed
+			if res.Outdated {
+				res.Behind = 1
+			}
+			break
+		}
+		if v.Compare(current) <= 0 {
+			break
+		}
+		res.Outdated = true
+		res.Behind++
+	}
+	return res, nil
+}
+
+// CompareInstalled returns a function comparing tags with the installed
+// version: -1, 0 or 1 when a tag is older, the same or newer, and false when
+// they can't be compared. Wildcards and ranges are the same as the tags they
+// match, other tags can't be compared with them. It returns nil when the
+// installed version can't be interpreted.
+func (p *Parser) CompareInstalled(installed string) func(tag string) (int, bool) {
+	if installed == "" {
+		return nil
+	}
+	if current := resolveInstalled(installed, nil, p); current != nil {
+		return func(tag string) (int, bool) {
+			v, err := p.Parse(tag)
+			if err != nil {
+				return 0, false
+			}
+			return v.Compare(current), true
+		}
+	}
+	if p.Ordering() != Semver {
+		return nil
+	}
+	c, err := semver.NewConstraint(normalizeConstraint(installed))
+	if err != nil {
+		return nil
+	}
+	return func(tag string) (int, bool) {
+		v, err := p.Parse(tag)
+		if err != nil || !c.Check(v.Semver()) {
+			return 0, false
+		}
+		return 0, true
+	}
+}
+
+// resolveInstalled parses the installed version. Wildcards and ranges are
+// resolved to the newest version matching them, nil is returned if the
+// version can't be interpreted.
+func resolveInstalled(installed string, sorted []*Version, parser *Parser) *Version {
+	if v, err := parser.Parse(installed); err == nil {
+		return v
+	}
+	// The installed version may lack the decorations the tags are
+	// extracted from, e.g. "3.0.9.1549" instead of "3.0.9.1549-ls160"
+	if parser != nil && parser.extract != nil {
+		raw := &Parser{ordering: parser.ordering}
+		if v, err := raw.Parse(installed); err == nil {
+			return v
+		}
+	}
+	if parser.Ordering() != Semver {
+		return nil
+	}
+
+	c, err := semver.NewConstraint(normalizeConstraint(installed))
+	if err != nil {
+		return nil
+	}
+	for _, v := range sorted {
+		if c.Check(v.Semver()) {
+			return v
+		}
+	}
+	return nil
+}
+
+// supports checks the policy can be applied to versions compared with
+// ordering.
+func (p *Policy) supports(ordering string) error {
+	switch {
+	case p == nil:
+		return nil
+	case p.constraint != nil && ordering != Semver:
+		return fmt.Errorf("version constraint %q requires semver ordering", p.expr)
+	case ordering == Lexical:
+		return fmt.Errorf("policy %q can't be used with lexical ordering", p.expr)
+	}
+	return nil
+}
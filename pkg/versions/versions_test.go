@@ -0,0 +1,317 @@
+package versions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	prometheus := []string{"41.5.1", "41.5.0", "35.0.0", "34.10.0", "34.9.1"}
+	tests := []struct {
+		name       string
+		installed  string
+		available  []string
+		constraint string
+		want       Result
+	}{
+		{
+			name:      "up to date",
+			installed: "17.0.5",
+			available: []string{"17.0.5", "17.0.4"},
+			want:      Result{Current: "17.0.5", Latest: "17.0.5", LatestInPolicy: "17.0.5"},
+		},
+		{
+			name:      "outdated",
+			installed: "34.9.1",
+			available: prometheus,
+			want:      Result{Current: "34.9.1", Latest: "41.5.1", LatestInPolicy: "41.5.1", Outdated: true, Behind: 4},
+		},
+		{
+			name:      "wildcard resolved to newest match",
+			installed: "34.*",
+			available: prometheus,
+			want:      Result{Current: "34.10.0", Latest: "41.5.1", LatestInPolicy: "41.5.1", Outdated: true, Behind: 3},
+		},
+		{
+			name:      "any version is always latest",
+			installed: "*",
+			available: prometheus,
+			want:      Result{Current: "41.5.1", Latest: "41.5.1", LatestInPolicy: "41.5.1"},
+		},
+		{
+			name:       "wildcard within minor-only policy",
+			installed:  "34.*",
+			available:  prometheus,
+			constraint: "minor-only",
+			want:       Result{Current: "34.10.0", Latest: "41.5.1", LatestInPolicy: "34.10.0"},
+		},
+		{
+			name:       "patch-only",
+			installed:  "34.9.0",
+			available:  prometheus,
+			constraint: "patch-only",
+			want:       Result{Current: "34.9.0", Latest: "41.5.1", LatestInPolicy: "34.9.1", Outdated: true, Behind: 1},
+		},
+		{
+			name:       "tilde range",
+			installed:  "34.9.1",
+			available:  prometheus,
+			constraint: "~34.9",
+			want:       Result{Current: "34.9.1", Latest: "41.5.1", LatestInPolicy: "34.9.1"},
+		},
+		{
+			name:       "space separated range",
+			installed:  "34.9.1",
+			available:  prometheus,
+			constraint: ">=35 <41",
+			want:       Result{Current: "34.9.1", Latest: "41.5.1", LatestInPolicy: "35.0.0", Outdated: true, Behind: 1},
+		},
+		{
+			name:      "v prefix",
+			installed: "v2.4.2",
+			available: []string{"v2.5.0", "v2.4.2"},
+			want:      Result{Current: "v2.4.2", Latest: "v2.5.0", LatestInPolicy: "v2.5.0", Outdated: true, Behind: 1},
+		},
+		{
+			name:      "unsorted with v prefixes and invalid versions",
+			installed: "v2.1.10",
+			available: []string{"v2.1.10", "2.6.0", "v2.5.3", "latest", "2.6.0-rc1"},
+			want:      Result{Current: "v2.1.10", Latest: "2.6.0", LatestInPolicy: "2.6.0", Outdated: true, Behind: 3, Skipped: []string{"latest"}},
+		},
+		{
+			name:      "v prefix only differs",
+			installed: "v2.6.0",
+			available: []string{"2.6.0", "2.5.0"},
+			want:      Result{Current: "v2.6.0", Latest: "2.6.0", LatestInPolicy: "2.6.0"},
+		},
+		{
+			name:      "unparsable installed version compared literally",
+			installed: "latest",
+			available: []string{"1.0.0"},
+			want:      Result{Current: "latest", Latest: "1.0.0", LatestInPolicy: "1.0.0", Outdated: true, Behind: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(tt.constraint)
+			if err != nil {
+				t.Fatalf("NewPolicy(%q) = %v", tt.constraint, err)
+			}
+			got, err := Resolve(tt.installed, tt.available, Options{Policy: policy})
+			if err != nil {
+				t.Fatalf("Resolve() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCeiling(t *testing.T) {
+	prometheus := []string{"41.5.1", "41.5.0", "35.0.0", "34.10.0", "34.9.1"}
+	tests := []struct {
+		name      string
+		installed string
+		ceiling   string
+		want      Result
+	}{
+		{
+			name:      "newer versions held back",
+			installed: "34.9.1",
+			ceiling:   "35.0.0",
+			want:      Result{Current: "34.9.1", Latest: "41.5.1", LatestInPolicy: "35.0.0", Outdated: true, Behind: 2},
+		},
+		{
+			name:      "ceiling at installed version",
+			installed: "35.0.0",
+			ceiling:   "35.0.0",
+			want:      Result{Current: "35.0.0", Latest: "41.5.1", LatestInPolicy: "35.0.0"},
+		},
+		{
+			name:      "ceiling below installed version",
+			installed: "41.5.0",
+			ceiling:   "35.0.0",
+			want:      Result{Current: "41.5.0", Latest: "41.5.1", LatestInPolicy: "35.0.0"},
+		},
+		{
+			name:      "wildcard ceiling",
+			installed: "34.9.1",
+			ceiling:   "34.*",
+			want:      Result{Current: "34.9.1", Latest: "41.5.1", LatestInPolicy: "34.10.0", Outdated: true, Behind: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.installed, prometheus, Options{Ceiling: tt.ceiling})
+			if err != nil {
+				t.Fatalf("Resolve() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Resolve("34.9.1", prometheus, Options{Ceiling: "latest"}); err == nil {
+		t.Error("Resolve() accepted a ceiling which can't be compared")
+	}
+}
+
+func TestResolveNoVersions(t *testing.T) {
+	if _, err := Resolve("1.0.0", []string{"latest", "stable"}, Options{}); err != ErrNoVersions {
+		t.Errorf("Resolve() = %v, want ErrNoVersions", err)
+	}
+}
+
+func TestNewPolicyInvalid(t *testing.T) {
+	if _, err := NewPolicy("major-only-please"); err == nil {
+		t.Error("NewPolicy() accepted an invalid expression")
+	}
+}
+
+func TestStability(t *testing.T) {
+	tests := map[string]string{
+		"2.6.1":            Stable,
+		"v2.6.1-rc1":       Beta,
+		"1.0.0-beta.2":     Beta,
+		"1.0.0-alpha":      Alpha,
+		"3.0.0-rc.1-alpha": Alpha,
+		"0.20.2158-ls78":   Stable,
+		"3.00-r5-ls137":    Stable,
+		"1.23.2-alpine":    Stable,
+		"nightly":          Alpha,
+		"latest":           Stable,
+	}
+	for version, want := range tests {
+		if got := Stability(version); got != want {
+			t.Errorf("Stability(%q) = %s, want %s", version, got, want)
+		}
+	}
+}
+
+func TestResolveCustomTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed string
+		available []string
+		include   string
+		exclude   string
+		extract   string
+		ordering  string
+		want      Result
+	}{
+		{
+			name:      "linuxserver numeric-dotted with build number",
+			installed: "3.0.9.1549-ls160",
+			available: []string{"3.0.9.1549-ls160", "3.0.10.1567-ls161", "3.0.9.1549-ls159", "develop-4.0.0.200-ls12", "latest"},
+			include:   `^\d`,
+			extract:   `^(?P<app>[\d.]+)-ls(?P<build>\d+)$`,
+			ordering:  NumericDotted,
+			want:      Result{Current: "3.0.9.1549-ls160", Latest: "3.0.10.1567-ls161", LatestInPolicy: "3.0.10.1567-ls161", Outdated: true, Behind: 1},
+		},
+		{
+			name:      "linuxserver rebuild of the same version",
+			installed: "3.00-r5-ls137",
+			available: []string{"3.00-r5-ls137", "3.00-r5-ls138", "3.00-r4-ls136"},
+			extract:   `^(?P<major>\d+)\.(?P<minor>\d+)-r(?P<revision>\d+)-ls(?P<build>\d+)$`,
+			ordering:  NumericDotted,
+			want:      Result{Current: "3.00-r5-ls137", Latest: "3.00-r5-ls138", LatestInPolicy: "3.00-r5-ls138", Outdated: true, Behind: 1},
+		},
+		{
+			name:      "semver extracted from linuxserver tag",
+			installed: "v0.20.2158-ls78",
+			available: []string{"v0.20.2158-ls78", "v0.20.2170-ls79", "v0.21.1-ls80"},
+			extract:   `^(?P<version>v[\d.]+)-ls\d+$`,
+			want:      Result{Current: "v0.20.2158-ls78", Latest: "v0.21.1-ls80", LatestInPolicy: "v0.21.1-ls80", Outdated: true, Behind: 2},
+		},
+		{
+			name:      "installed version without decorations",
+			installed: "3.0.9.1549",
+			available: []string{"3.0.9.1549-ls160", "3.0.10.1567-ls161"},
+			extract:   `^(?P<version>[\d.]+)-ls\d+$`,
+			ordering:  NumericDotted,
+			want:      Result{Current: "3.0.9.1549", Latest: "3.0.10.1567-ls161", LatestInPolicy: "3.0.10.1567-ls161", Outdated: true, Behind: 1},
+		},
+		{
+			name:      "calver",
+			installed: "2022.10",
+			available: []string{"2022.09.4", "2022.10", "2022.10.1", "nightly"},
+			exclude:   `nightly`,
+			ordering:  Calver,
+			want:      Result{Current: "2022.10", Latest: "2022.10.1", LatestInPolicy: "2022.10.1", Outdated: true, Behind: 1},
+		},
+		{
+			name:      "lexical",
+			installed: "20221001-abc",
+			available: []string{"20221001-abc", "20221015-def", "20220901-123"},
+			ordering:  Lexical,
+			want:      Result{Current: "20221001-abc", Latest: "20221015-def", LatestInPolicy: "20221015-def", Outdated: true, Behind: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewParser(tt.ordering, tt.extract)
+			if err != nil {
+				t.Fatalf("NewParser() = %v", err)
+			}
+			filter, err := NewTagFilter(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("NewTagFilter() = %v", err)
+			}
+			got, err := Resolve(tt.installed, tt.available, Options{Parser: parser, Filter: filter})
+			if err != nil {
+				t.Fatalf("Resolve() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveUnsupportedPolicy(t *testing.T) {
+	policy, _ := NewPolicy("~1.2")
+	parser, _ := NewParser(Calver, "")
+	if _, err := Resolve("2022.10", []string{"2022.10"}, Options{Policy: policy, Parser: parser}); err == nil {
+		t.Error("Resolve() applied a semver range to calver versions")
+	}
+}
+
+func TestCompareInstalled(t *testing.T) {
+	tests := []struct {
+		ordering, extract string
+		installed, tag    string
+		want              int
+		ok                bool
+	}{
+		{installed: "1.2.3", tag: "v1.2.3", want: 0, ok: true},
+		{installed: "v1.2.3", tag: "v1.10.0", want: 1, ok: true},
+		{installed: "v1.2.3", tag: "v1.2.0", want: -1, ok: true},
+		{installed: "v1.2.3", tag: "nightly"},
+		{installed: "34.*", tag: "34.1.0", want: 0, ok: true},
+		{installed: "34.*", tag: "35.0.0"},
+		{ordering: NumericDotted, extract: `^(?P<version>[\d.]+)-ls\d+$`, installed: "3.0.9.1549", tag: "3.0.10.1567-ls161", want: 1, ok: true},
+		{ordering: Calver, installed: "2022.10", tag: "2022.09.4", want: -1, ok: true},
+	}
+	for _, tt := range tests {
+		parser, err := NewParser(tt.ordering, tt.extract)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compare := parser.CompareInstalled(tt.installed)
+		if compare == nil {
+			t.Errorf("CompareInstalled(%s) = nil", tt.installed)
+			continue
+		}
+		if got, ok := compare(tt.tag); got != tt.want || ok != tt.ok {
+			t.Errorf("CompareInstalled(%s)(%s) = %d, %v, want %d, %v", tt.installed, tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	for _, installed := range []string{"", "latest"} {
+		if compare := (*Parser)(nil).CompareInstalled(installed); compare != nil {
+			t.Errorf("CompareInstalled(%q) = %p, want nil", installed, compare)
+		}
+	}
+}